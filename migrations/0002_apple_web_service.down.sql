@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS apple_device_registrations;
+DROP TABLE IF EXISTS apple_pass_auth_tokens;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS apple_pass_auth_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pass_type_identifier TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    token TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (pass_type_identifier, serial_number)
+);
+
+CREATE TABLE IF NOT EXISTS apple_device_registrations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_library_identifier TEXT NOT NULL,
+    push_token TEXT NOT NULL,
+    pass_type_identifier TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (device_library_identifier, pass_type_identifier, serial_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_apple_device_registrations_serial ON apple_device_registrations (pass_type_identifier, serial_number);
@@ -0,0 +1,6 @@
+ALTER TABLE apple_device_registrations
+    DROP COLUMN IF EXISTS unregistered_at,
+    DROP COLUMN IF EXISTS push_failure_count,
+    DROP COLUMN IF EXISTS last_push_error,
+    DROP COLUMN IF EXISTS last_push_status,
+    DROP COLUMN IF EXISTS last_pushed_at;
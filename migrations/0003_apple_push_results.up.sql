@@ -0,0 +1,6 @@
+ALTER TABLE apple_device_registrations
+    ADD COLUMN IF NOT EXISTS last_pushed_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_push_status INTEGER,
+    ADD COLUMN IF NOT EXISTS last_push_error TEXT,
+    ADD COLUMN IF NOT EXISTS push_failure_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS unregistered_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+UPDATE ticket_passes SET status = 'failed' WHERE status = 'voided';
+
+ALTER TABLE ticket_passes
+    DROP COLUMN IF EXISTS voided_at;
@@ -0,0 +1,2 @@
+ALTER TABLE ticket_passes
+    ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS sync_cursors (
+    event_id TEXT PRIMARY KEY,
+    last_updated_at BIGINT NOT NULL DEFAULT 0,
+    last_ticket_id TEXT NOT NULL DEFAULT '',
+    last_full_sync_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
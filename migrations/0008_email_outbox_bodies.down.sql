@@ -0,0 +1,5 @@
+ALTER TABLE email_outbox
+    ADD COLUMN IF NOT EXISTS link_url TEXT,
+    DROP COLUMN IF EXISTS attachment_name,
+    DROP COLUMN IF EXISTS text_body,
+    DROP COLUMN IF EXISTS html_body;
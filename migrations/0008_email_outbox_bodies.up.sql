@@ -0,0 +1,5 @@
+ALTER TABLE email_outbox
+    ADD COLUMN IF NOT EXISTS html_body TEXT,
+    ADD COLUMN IF NOT EXISTS text_body TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS attachment_name TEXT,
+    DROP COLUMN IF EXISTS link_url;
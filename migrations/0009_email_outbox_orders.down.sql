@@ -0,0 +1,17 @@
+ALTER TABLE email_outbox
+    ADD COLUMN IF NOT EXISTS ticket_pass_id UUID REFERENCES ticket_passes(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS attachment_path TEXT,
+    ADD COLUMN IF NOT EXISTS attachment_name TEXT;
+
+UPDATE email_outbox e
+SET ticket_pass_id = (SELECT p.ticket_pass_id FROM email_outbox_passes p WHERE p.email_id = e.id LIMIT 1),
+    attachment_path = e.attachments->0->>'path',
+    attachment_name = e.attachments->0->>'name';
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_ticket_pass ON email_outbox (ticket_pass_id);
+
+ALTER TABLE email_outbox
+    DROP COLUMN IF EXISTS attachments,
+    DROP COLUMN IF EXISTS order_id;
+
+DROP TABLE IF EXISTS email_outbox_passes;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS email_outbox_passes (
+    email_id UUID NOT NULL REFERENCES email_outbox(id) ON DELETE CASCADE,
+    ticket_pass_id UUID NOT NULL REFERENCES ticket_passes(id) ON DELETE CASCADE,
+    PRIMARY KEY (email_id, ticket_pass_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_passes_ticket_pass ON email_outbox_passes (ticket_pass_id);
+
+ALTER TABLE email_outbox
+    ADD COLUMN IF NOT EXISTS order_id TEXT,
+    ADD COLUMN IF NOT EXISTS attachments JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+INSERT INTO email_outbox_passes (email_id, ticket_pass_id)
+SELECT id, ticket_pass_id FROM email_outbox WHERE ticket_pass_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+UPDATE email_outbox
+SET attachments = jsonb_build_array(jsonb_build_object('path', attachment_path, 'name', COALESCE(attachment_name, 'ticket.pkpass')))
+WHERE attachment_path IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_email_outbox_ticket_pass;
+
+ALTER TABLE email_outbox
+    DROP COLUMN IF EXISTS ticket_pass_id,
+    DROP COLUMN IF EXISTS attachment_path,
+    DROP COLUMN IF EXISTS attachment_name;
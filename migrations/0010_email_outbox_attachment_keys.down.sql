@@ -0,0 +1,10 @@
+-- The local TICKETS_DIR is not known here, so attachments fall back to the object key as their path.
+UPDATE email_outbox
+SET attachments = (
+    SELECT COALESCE(jsonb_agg(jsonb_build_object(
+        'path', attachment->>'key',
+        'name', attachment->>'name'
+    ) ORDER BY position), '[]'::jsonb)
+    FROM jsonb_array_elements(attachments) WITH ORDINALITY AS attached(attachment, position)
+)
+WHERE jsonb_path_exists(attachments, '$[*].key');
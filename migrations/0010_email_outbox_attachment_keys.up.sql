@@ -0,0 +1,11 @@
+-- Queued attachments point at the pass object in S3 instead of a file on the producing machine.
+-- Apple passes are the only attachments and are uploaded under ham-2026/apple-wallet/<file name>.
+UPDATE email_outbox
+SET attachments = (
+    SELECT COALESCE(jsonb_agg(jsonb_build_object(
+        'key', 'ham-2026/apple-wallet/' || regexp_replace(attachment->>'path', '^.*/', ''),
+        'name', attachment->>'name'
+    ) ORDER BY position), '[]'::jsonb)
+    FROM jsonb_array_elements(attachments) WITH ORDINALITY AS attached(attachment, position)
+)
+WHERE jsonb_path_exists(attachments, '$[*].path');
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_ticket_passes_next_retry_at;
+
+ALTER TABLE ticket_passes
+    DROP COLUMN IF EXISTS next_retry_at,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS failure_stage;
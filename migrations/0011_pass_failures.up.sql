@@ -0,0 +1,12 @@
+-- Passes that failed to be produced keep the failing stage and are retried with backoff until next_retry_at is
+-- cleared. Dead-lettered deliveries, the only failures recorded so far, are never retried.
+ALTER TABLE ticket_passes
+    ADD COLUMN IF NOT EXISTS failure_stage TEXT,
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMPTZ;
+
+UPDATE ticket_passes SET failure_stage = 'deliver' WHERE status = 'failed' AND failure_stage IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ticket_passes_next_retry_at
+    ON ticket_passes (next_retry_at)
+    WHERE status = 'failed';
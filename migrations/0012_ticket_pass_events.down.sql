@@ -0,0 +1,5 @@
+ALTER TABLE ticket_passes DROP CONSTRAINT IF EXISTS ticket_passes_status_check;
+
+UPDATE ticket_passes SET status = 'produced' WHERE status = 'uploaded';
+
+DROP TABLE IF EXISTS ticket_pass_events;
@@ -0,0 +1,19 @@
+-- Every pass status change is recorded; statuses outside the state machine in pkg/db are rejected.
+CREATE TABLE IF NOT EXISTS ticket_pass_events (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    ticket_pass_id UUID NOT NULL REFERENCES ticket_passes(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    detail TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ticket_pass_events_pass ON ticket_pass_events (ticket_pass_id, id);
+
+-- Passes have always been recorded after they were stored, so produced passes are uploaded.
+UPDATE ticket_passes SET status = 'uploaded' WHERE status = 'produced';
+
+ALTER TABLE ticket_passes
+    ADD CONSTRAINT ticket_passes_status_check
+    CHECK (status IN ('pending', 'produced', 'uploaded', 'sent', 'failed', 'voided'));
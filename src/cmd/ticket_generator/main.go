@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"os"
 	"time"
 
 	"github.com/atunbetun/hakuna-wallet/pkg"
@@ -12,7 +14,15 @@ import (
 	"go.uber.org/zap"
 )
 
+// Usage:
+//
+//	ticket_generator [-trigger cron|manual]   sync tickets and record the run
+//	ticket_generator runs [-limit N]          list recent sync runs
+//	ticket_generator runs <id>                show one sync run and its failed tickets
 func main() {
+	trigger := flag.String("trigger", batch.ManualTrigger, "what started the run, recorded in sync_runs")
+	flag.Parse()
+
 	logger.Init()
 	defer logger.Logger.Sync()
 	logger.Logger.Info("Started")
@@ -33,10 +43,29 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	err := batch.GenerateTickets(ctx, cfg)
+	if flag.Arg(0) == "runs" {
+		if err := printRuns(ctx, cfg, flag.Args()[1:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	err := batch.GenerateTickets(ctx, cfg, *trigger)
 	if err != nil {
 		panic(err)
 	}
 	logger.Logger.Info("Success")
 
 }
+
+func printRuns(ctx context.Context, cfg pkg.AppConfig, args []string) error {
+	runs := flag.NewFlagSet("runs", flag.ExitOnError)
+	limit := runs.Int("limit", 20, "how many recent runs to list")
+	if err := runs.Parse(args); err != nil {
+		return err
+	}
+	if id := runs.Arg(0); id != "" {
+		return batch.PrintSyncRun(ctx, cfg, os.Stdout, id)
+	}
+	return batch.PrintSyncRuns(ctx, cfg, os.Stdout, *limit)
+}
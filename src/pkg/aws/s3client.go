@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -82,3 +83,27 @@ func (c *S3Client) UploadBytes(ctx context.Context, bucket, key string, data []b
 	}
 	return out, nil
 }
+
+// OpenObject streams an S3 object; the caller must close the returned body.
+func (c *S3Client) OpenObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s from S3: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+// DeleteObject removes an S3 object; deleting a missing key succeeds.
+func (c *S3Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s from S3: %w", key, err)
+	}
+	return nil
+}
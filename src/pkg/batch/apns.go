@@ -0,0 +1,77 @@
+package batch
+
+import (
+	"context"
+	"errors"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet/apple"
+	"gorm.io/gorm"
+)
+
+// passNotifier tells registered devices that the passes for the given serial numbers changed.
+type passNotifier func(ctx context.Context, serialNumbers []string) error
+
+// applePushStore adapts the device registration repository to the APNs notifier.
+type applePushStore struct {
+	conn *gorm.DB
+}
+
+func (s applePushStore) PushTargets(ctx context.Context, serialNumbers []string) ([]apple.PushTarget, error) {
+	registrations, err := db.GetApplePushTargets(ctx, s.conn, serialNumbers)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]apple.PushTarget, 0, len(registrations))
+	for _, r := range registrations {
+		targets = append(targets, apple.PushTarget{
+			RegistrationID:     r.ID,
+			PassTypeIdentifier: r.PassTypeIdentifier,
+			SerialNumber:       r.SerialNumber,
+			PushToken:          r.PushToken,
+		})
+	}
+	return targets, nil
+}
+
+func (s applePushStore) RecordPushResults(ctx context.Context, results []apple.PushResult) error {
+	var errs []error
+	for _, r := range results {
+		outcome := db.ApplePushOutcome{
+			Status:       r.StatusCode,
+			Unregistered: r.Unregistered,
+			PushedAt:     r.SentAt,
+		}
+		if r.Err != nil {
+			outcome.Error = r.Err.Error()
+		}
+		if err := db.RecordApplePushOutcome(ctx, s.conn, r.Target.RegistrationID, outcome); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newAppleNotifier returns nil when passes are not updatable, since no device can be registered.
+func newAppleNotifier(cfg pkg.AppConfig, conn *gorm.DB) (passNotifier, error) {
+	if cfg.AppleWebServiceURL == "" {
+		return nil, nil
+	}
+
+	appleConfig, err := getAppleConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, err := apple.NewNotifier(appleConfig, applePushStore{conn: conn})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, serialNumbers []string) error {
+		_, err := notifier.Notify(ctx, serialNumbers)
+		return err
+	}, nil
+}
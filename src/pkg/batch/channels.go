@@ -0,0 +1,122 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet/google"
+	"gorm.io/gorm"
+)
+
+// channelGenerator binds a wallet channel to the generator that renders its passes.
+type channelGenerator struct {
+	Channel  db.PassChannel
+	Platform Platform
+	Generate passGenerator
+	Revoke   passRevoker
+	// Update refreshes a pass the holder already saved after its ticket changed; nil when re-uploading is enough.
+	Update passUpdater
+}
+
+// passUpdater pushes changed ticket details to a pass that lives on the wallet provider's side.
+type passUpdater func(ctx context.Context, ticket tickets.TTIssuedTicket) error
+
+// newChannelGenerators builds one generator per channel enabled in WALLET_CHANNELS, in configured order.
+func newChannelGenerators(cfg pkg.AppConfig, conn *gorm.DB) ([]channelGenerator, error) {
+	if len(cfg.WalletChannels) == 0 {
+		return nil, fmt.Errorf("at least one wallet channel must be enabled")
+	}
+
+	seen := make(map[db.PassChannel]bool)
+	var generators []channelGenerator
+	for _, raw := range cfg.WalletChannels {
+		channel := db.PassChannel(strings.TrimSpace(raw))
+		if channel == "" || seen[channel] {
+			continue
+		}
+		seen[channel] = true
+
+		switch channel {
+		case db.AppleWalletChannel:
+			gen, err := newAppleGenerator(cfg, EmbeddedAppleGenerator, conn)
+			if err != nil {
+				return nil, err
+			}
+			generators = append(generators, channelGenerator{Channel: channel, Platform: PlatformApple, Generate: gen})
+		case db.GoogleWalletChannel:
+			gen, err := newGoogleChannel(cfg)
+			if err != nil {
+				return nil, err
+			}
+			generators = append(generators, gen)
+		default:
+			return nil, fmt.Errorf("unknown wallet channel: %s", channel)
+		}
+	}
+	return generators, nil
+}
+
+// newGoogleChannel signs save links for new passes and manages already saved objects through the REST API.
+func newGoogleChannel(cfg pkg.AppConfig) (channelGenerator, error) {
+	googleConfig, err := getGoogleConfig(cfg)
+	if err != nil {
+		return channelGenerator{}, err
+	}
+
+	client, err := google.NewClient(googleConfig)
+	if err != nil {
+		return channelGenerator{}, err
+	}
+
+	generator, err := google.NewGenerator(googleConfig)
+	if err != nil {
+		return channelGenerator{}, err
+	}
+	return channelGenerator{
+		Channel:  db.GoogleWalletChannel,
+		Platform: PlatformGoogle,
+		Generate: func(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error) {
+			return generator.Generate(ctx, ticket)
+		},
+		Revoke: newGoogleRevoker(googleConfig, client),
+		Update: newGoogleUpdater(googleConfig, client),
+	}, nil
+}
+
+// newGoogleUpdater patches the saved object; a missing object means the new save link is all the holder needs.
+func newGoogleUpdater(googleConfig google.Config, client *google.Client) passUpdater {
+	return func(ctx context.Context, ticket tickets.TTIssuedTicket) error {
+		_, err := client.PatchObject(ctx, googleConfig.Object(ticket))
+		if errors.Is(err, google.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+}
+
+func getGoogleConfig(cfg pkg.AppConfig) (google.Config, error) {
+	if cfg.GoogleIssuerID == "" {
+		return google.Config{}, fmt.Errorf("google issuer id is required")
+	}
+	if cfg.GoogleClassSuffix == "" {
+		return google.Config{}, fmt.Errorf("google class suffix is required")
+	}
+	if cfg.GoogleServiceAccountJSON == "" {
+		return google.Config{}, fmt.Errorf("google service account json is required")
+	}
+
+	return google.Config{
+		IssuerID:           cfg.GoogleIssuerID,
+		ClassSuffix:        cfg.GoogleClassSuffix,
+		IssuerName:         cfg.GoogleIssuerName,
+		EventName:          cfg.GoogleEventName,
+		Origins:            cfg.GoogleOrigins,
+		ServiceAccountJSON: cfg.GoogleServiceAccountJSON,
+	}, nil
+}
@@ -0,0 +1,77 @@
+package batch
+
+import (
+	"context"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"go.uber.org/zap"
+)
+
+// syncWindow decides whether a run fetches every ticket or only those changed since the stored watermark.
+type syncWindow struct {
+	Full         bool
+	UpdatedSince int64
+	cursor       db.SyncCursor
+}
+
+// loadSyncWindow runs a full reconciliation on the first sync, when no watermark exists yet, or once
+// FullReconcileInterval has elapsed since the last full run; otherwise the run is incremental.
+func (g *walletTicketSyncer) loadSyncWindow(ctx context.Context, now time.Time) (syncWindow, error) {
+	cursor, found, err := db.GetSyncCursor(ctx, g.DB, g.ticketConfig.EventId)
+	if err != nil {
+		return syncWindow{}, err
+	}
+	if !found {
+		cursor = db.SyncCursor{EventID: g.ticketConfig.EventId}
+	}
+
+	window := syncWindow{cursor: cursor, UpdatedSince: cursor.LastUpdatedAt}
+	switch {
+	case !found, cursor.LastUpdatedAt == 0, cursor.LastFullSyncAt == nil:
+		window.Full = true
+	case g.FullReconcileInterval <= 0:
+		window.Full = true
+	case now.Sub(*cursor.LastFullSyncAt) >= g.FullReconcileInterval:
+		window.Full = true
+	}
+	if window.Full {
+		window.UpdatedSince = 0
+	}
+	return window, nil
+}
+
+// advanceSyncCursor moves the watermark to the newest updated_at seen in this run. Ticket Tailor timestamps are
+// used rather than the local clock, and the next incremental fetch is inclusive, so equal timestamps are not lost.
+func (g *walletTicketSyncer) advanceSyncCursor(
+	ctx context.Context,
+	window syncWindow,
+	now time.Time,
+	batches ...[]tickets.TTIssuedTicket,
+) error {
+	cursor := window.cursor
+	for _, batch := range batches {
+		for _, ticket := range batch {
+			if ticket.UpdatedAt > cursor.LastUpdatedAt {
+				cursor.LastUpdatedAt = ticket.UpdatedAt
+				cursor.LastTicketID = ticket.ID
+			}
+		}
+	}
+	if window.Full {
+		cursor.LastFullSyncAt = &now
+	}
+
+	if err := db.SaveSyncCursor(ctx, g.DB, cursor); err != nil {
+		return err
+	}
+	logger.Logger.Debug(
+		"Advanced sync cursor",
+		zap.String("event_id", cursor.EventID),
+		zap.Int64("last_updated_at", cursor.LastUpdatedAt),
+		zap.Bool("full", window.Full),
+	)
+	return nil
+}
@@ -0,0 +1,164 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/aws"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/mailer"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gorm.io/gorm"
+)
+
+// newMailDialer builds the transport selected by MAIL_TRANSPORT.
+func newMailDialer(ctx context.Context, cfg pkg.AppConfig) (mailer.MailDialer, error) {
+	switch cfg.MailTransport {
+	case mailer.TransportSMTP:
+		return mailer.NewSMTPTransport(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			TLS:      cfg.SMTPTLS,
+		})
+	case mailer.TransportSES:
+		awsConfig, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading aws config: %w", err)
+		}
+		return mailer.NewSESTransport(awsConfig, cfg.SESEndpoint), nil
+	case mailer.TransportFile:
+		return mailer.NewFileTransport(cfg.MailDir)
+	default:
+		return nil, fmt.Errorf("unsupported mail transport %q", cfg.MailTransport)
+	}
+}
+
+// groupByOrder groups artifacts of every channel by Ticket Tailor order and recipient, keeping first-seen order.
+// Tickets without an order ID are delivered on their own.
+func groupByOrder(artifacts []GeneratedArtifact) [][]GeneratedArtifact {
+	type orderKey struct {
+		orderID string
+		email   string
+	}
+
+	var keys []orderKey
+	groups := make(map[orderKey][]GeneratedArtifact)
+	for _, artifact := range artifacts {
+		key := orderKey{orderID: artifact.Ticket.OrderID, email: artifact.Email}
+		if key.orderID == "" {
+			key.orderID = "ticket:" + artifact.TicketID
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], artifact)
+	}
+
+	orders := make([][]GeneratedArtifact, len(keys))
+	for i, key := range keys {
+		orders[i] = groups[key]
+	}
+	return orders
+}
+
+// orderEmail renders the one email delivering an order: Apple passes are attached, and linked when pass links are
+// configured, Google passes are listed with their save links.
+func (g *walletTicketSyncer) orderEmail(order []GeneratedArtifact) (db.OutboxMessage, error) {
+	data := mailer.TemplateDataFromTicket(order[0].Ticket, g.AppConfig.EventName)
+
+	var attachments []db.OutboxAttachment
+	passIndex := make(map[string]int)
+	for _, artifact := range order {
+		i, ok := passIndex[artifact.TicketID]
+		if !ok {
+			i = len(data.Passes)
+			passIndex[artifact.TicketID] = i
+			data.Passes = append(data.Passes, mailer.PassLinkFromTicket(artifact.Ticket))
+		}
+
+		switch artifact.Platform {
+		case PlatformGoogle:
+			data.Passes[i].SaveURL = artifact.SaveURL
+		default:
+			link, err := passLinkURL(g.AppConfig, artifact.Channel, artifact.TicketID)
+			if err != nil {
+				return db.OutboxMessage{}, err
+			}
+			data.Passes[i].DownloadURL = link
+			data.Passes[i].Attached = true
+			attachments = append(attachments, db.OutboxAttachment{
+				Key:  artifact.Key,
+				Name: mailer.PassFileName(data.Passes[i]),
+			})
+		}
+	}
+
+	ordered := make([]tickets.TTIssuedTicket, len(order))
+	for i, artifact := range order {
+		ordered[i] = artifact.Ticket
+	}
+	email, err := g.Templates.Render(mailer.KindOrderPasses, g.emailLocale(ordered...), data)
+	if err != nil {
+		return db.OutboxMessage{}, err
+	}
+	return db.OutboxMessage{
+		Kind:        mailer.KindOrderPasses,
+		OrderID:     order[0].Ticket.OrderID,
+		Recipient:   order[0].Email,
+		Subject:     email.Subject,
+		HTMLBody:    email.HTML,
+		TextBody:    email.Text,
+		Attachments: attachments,
+	}, nil
+}
+
+// emailLocale picks the language of an email about tickets from the first answer to EMAIL_LOCALE_QUESTION among
+// them, falling back to EMAIL_LOCALE.
+func (g *walletTicketSyncer) emailLocale(ticketList ...tickets.TTIssuedTicket) string {
+	for _, ticket := range ticketList {
+		if answer := ticket.Answer(g.AppConfig.EmailLocaleQuestion); answer != "" {
+			return g.Templates.Locale(answer, g.AppConfig.EmailLocale)
+		}
+	}
+	return g.AppConfig.EmailLocale
+}
+
+// OutboxEnabled reports whether the sync queues any email, pass deliveries or void notices, for an outbox worker
+// to send.
+func OutboxEnabled(cfg pkg.AppConfig) bool {
+	return cfg.PassEmailEnabled || cfg.VoidEmailEnabled
+}
+
+// NewOutboxWorker builds the worker that sends queued pass emails, streaming their passes from the S3 bucket
+// so it does not need the machine that produced them.
+func NewOutboxWorker(ctx context.Context, cfg pkg.AppConfig, conn *gorm.DB) (*mailer.OutboxWorker, error) {
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading aws config: %w", err)
+	}
+	s3, err := aws.NewS3Client(cfg.S3Bucket, &awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, err := newMailDialer(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return mailer.NewOutboxWorker(
+		db.NewEmailOutboxStore(conn),
+		dialer,
+		cfg.MailFrom,
+		mailer.WithOutboxAttachments(func(ctx context.Context, key string) (io.ReadCloser, error) {
+			return s3.OpenObject(ctx, cfg.S3Bucket, key)
+		}),
+		mailer.WithOutboxMaxAttempts(cfg.EmailOutboxMaxAttempts),
+		mailer.WithOutboxPollInterval(cfg.EmailOutboxPollInterval),
+	)
+}
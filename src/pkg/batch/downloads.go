@@ -0,0 +1,83 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/aws"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/downloads"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"gorm.io/gorm"
+)
+
+// NewDownloadResolver builds the resolver presigning pass downloads from the S3 bucket. It needs the s3 artifact
+// sink, since that is where the presigned keys live.
+func NewDownloadResolver(ctx context.Context, cfg pkg.AppConfig, conn *gorm.DB) (*downloads.Resolver, error) {
+	if !HasArtifactSink(cfg, S3ArtifactSink) {
+		return nil, fmt.Errorf("pass downloads are served from s3, add the s3 artifact sink")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading aws config: %w", err)
+	}
+	s3, err := aws.NewS3Client(cfg.S3Bucket, &awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return downloads.NewResolver(
+		db.NewPassDownloadStore(conn),
+		func(ctx context.Context, key string, ttl time.Duration) (string, error) {
+			return s3.PresignURL(ctx, cfg.S3Bucket, key, ttl)
+		},
+		downloads.WithTTL(cfg.DownloadURLTTL),
+		downloads.WithRefreshBefore(cfg.DownloadURLRefreshBefore),
+	)
+}
+
+// Pass download modes of the /passes/{token} links.
+const (
+	RedirectPassDownloads = "redirect"
+	StreamPassDownloads   = "stream"
+)
+
+// NewPassOpener returns the S3 reader streaming passes through the server, or nil when links redirect to S3.
+func NewPassOpener(ctx context.Context, cfg pkg.AppConfig) (func(ctx context.Context, key string) (io.ReadCloser, error), error) {
+	switch cfg.PassDownloadMode {
+	case RedirectPassDownloads:
+		return nil, nil
+	case StreamPassDownloads:
+	default:
+		return nil, fmt.Errorf("unsupported pass download mode %q", cfg.PassDownloadMode)
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading aws config: %w", err)
+	}
+	s3, err := aws.NewS3Client(cfg.S3Bucket, &awsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, key string) (io.ReadCloser, error) {
+		return s3.OpenObject(ctx, cfg.S3Bucket, key)
+	}, nil
+}
+
+// passLinkURL returns the stable download link of a pass, or "" unless PASS_LINK_SECRET and PUBLIC_BASE_URL are set.
+func passLinkURL(cfg pkg.AppConfig, channel db.PassChannel, ticketTailorID string) (string, error) {
+	if cfg.PassLinkSecret == "" || cfg.PublicBaseURL == "" {
+		return "", nil
+	}
+	token, err := downloads.SignToken(cfg.PassLinkSecret, channel, ticketTailorID)
+	if err != nil {
+		return "", fmt.Errorf("signing pass link: %w", err)
+	}
+	return strings.TrimSuffix(cfg.PublicBaseURL, "/") + "/passes/" + token, nil
+}
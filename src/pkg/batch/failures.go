@@ -0,0 +1,173 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"go.uber.org/zap"
+)
+
+// SyncStage names the step of the pass pipeline a ticket failed in.
+type SyncStage string
+
+const (
+	StageGenerate SyncStage = "generate"
+	StageStore    SyncStage = "store"
+	StageRecord   SyncStage = "record"
+	StageUpdate   SyncStage = "update"
+	StageVoid     SyncStage = "void"
+)
+
+// TicketFailure is one ticket a run could not process on a channel.
+type TicketFailure struct {
+	TicketID string
+	Channel  db.PassChannel
+	Email    string
+	Stage    SyncStage
+	Err      error
+}
+
+func (f TicketFailure) Error() string {
+	return fmt.Sprintf("%s %s ticket %s: %v", f.Stage, f.Channel, f.TicketID, f.Err)
+}
+
+func (f TicketFailure) Unwrap() error {
+	return f.Err
+}
+
+// stageError tags an error with the pipeline stage it came from.
+type stageError struct {
+	stage SyncStage
+	err   error
+}
+
+func (e *stageError) Error() string { return e.err.Error() }
+func (e *stageError) Unwrap() error { return e.err }
+
+func atStage(stage SyncStage, err error) error {
+	return &stageError{stage: stage, err: err}
+}
+
+// stageOf returns the stage an error was tagged with, or fallback.
+func stageOf(err error, fallback SyncStage) SyncStage {
+	var tagged *stageError
+	if errors.As(err, &tagged) {
+		return tagged.stage
+	}
+	return fallback
+}
+
+// failureLog collects ticket failures from concurrent workers.
+type failureLog struct {
+	mu       sync.Mutex
+	failures []TicketFailure
+}
+
+func (l *failureLog) add(failure TicketFailure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures = append(l.failures, failure)
+}
+
+func (l *failureLog) list() []TicketFailure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]TicketFailure(nil), l.failures...)
+}
+
+// newRetrySchedule backs failed passes off exponentially from PASS_RETRY_BASE_DELAY and gives up after
+// PASS_RETRY_MAX_ATTEMPTS.
+func newRetrySchedule(cfg pkg.AppConfig, now func() time.Time) db.RetrySchedule {
+	return func(attempts int) (time.Time, bool) {
+		if attempts >= cfg.PassRetryMaxAttempts {
+			return time.Time{}, false
+		}
+		delay := time.Duration(float64(cfg.PassRetryBaseDelay) * math.Pow(2, float64(attempts-1)))
+		if cfg.PassRetryMaxDelay > 0 && (delay > cfg.PassRetryMaxDelay || delay <= 0) {
+			delay = cfg.PassRetryMaxDelay
+		}
+		return now().Add(delay), true
+	}
+}
+
+// recordFailures stores every failure on its ticket pass so later runs retry it on schedule.
+func (g *walletTicketSyncer) recordFailures(ctx context.Context, failures []TicketFailure) error {
+	var errs []error
+	for _, failure := range failures {
+		pass, err := db.SetPassFailed(ctx, g.DB, db.PassFailure{
+			Channel:        failure.Channel,
+			TicketTailorID: failure.TicketID,
+			Email:          failure.Email,
+			Stage:          string(failure.Stage),
+			Message:        failure.Err.Error(),
+		}, g.Now(), g.RetrySchedule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ticket %s: %w", failure.TicketID, err))
+			continue
+		}
+
+		fields := []zap.Field{
+			zap.String("ticket_id", failure.TicketID),
+			zap.String("channel", string(failure.Channel)),
+			zap.String("stage", string(failure.Stage)),
+			zap.Int("attempts", pass.Attempts),
+		}
+		if pass.NextRetryAt != nil {
+			logger.Logger.Warn("Recorded pass failure", append(fields, zap.Time("retry_at", *pass.NextRetryAt))...)
+		} else {
+			logger.Logger.Error("Giving up on pass", fields...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dueRetries looks up the tickets with a pass due for retry that the fetched batches do not already cover, so
+// incremental runs retry them even though Ticket Tailor has nothing new for them.
+func (g *walletTicketSyncer) dueRetries(
+	ctx context.Context,
+	now time.Time,
+	fetched ...[]tickets.TTIssuedTicket,
+) ([]tickets.TTIssuedTicket, []tickets.TTIssuedTicket) {
+	ticketIDs, err := db.GetPassRetries(ctx, g.DB, now)
+	if err != nil {
+		logger.Logger.Error("listing pass retries", zap.Error(err))
+		return nil, nil
+	}
+
+	covered := make(map[string]bool)
+	for _, batch := range fetched {
+		for _, ticket := range batch {
+			covered[ticket.ID] = true
+		}
+	}
+
+	var valid, voided []tickets.TTIssuedTicket
+	for _, ticketID := range ticketIDs {
+		if covered[ticketID] {
+			continue
+		}
+		ticket, err := g.TicketLookup(ctx, ticketID)
+		if err != nil {
+			logger.Logger.Error("fetching ticket to retry", zap.String("ticket_id", ticketID), zap.Error(err))
+			continue
+		}
+		switch {
+		case ticket.IsVoided():
+			voided = append(voided, ticket)
+		case ticket.Status == string(tickets.Valid):
+			valid = append(valid, ticket)
+		}
+	}
+	if len(valid)+len(voided) > 0 {
+		logger.Logger.Info("Retrying failed passes", zap.Int("valid", len(valid)), zap.Int("voided", len(voided)))
+	}
+	return valid, voided
+}
@@ -0,0 +1,67 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+)
+
+func TestFailureLogAddConcurrently(t *testing.T) {
+	log := &failureLog{}
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.add(TicketFailure{TicketID: fmt.Sprintf("it_%d", i), Stage: StageGenerate})
+		}()
+	}
+	wg.Wait()
+
+	failures := log.list()
+	if len(failures) != 100 {
+		t.Fatalf("expected 100 failures, got %d", len(failures))
+	}
+	seen := make(map[string]bool, len(failures))
+	for _, failure := range failures {
+		if seen[failure.TicketID] {
+			t.Fatalf("ticket %s logged twice", failure.TicketID)
+		}
+		seen[failure.TicketID] = true
+	}
+
+	failures[0].TicketID = "changed"
+	if log.list()[0].TicketID == "changed" {
+		t.Fatal("expected list to return a copy")
+	}
+}
+
+func TestGenerationSummaryErr(t *testing.T) {
+	if err := (GenerationSummary{}).Err(); err != nil {
+		t.Fatalf("expected no error without failures, got %v", err)
+	}
+
+	cause := errors.New("bucket unavailable")
+	summary := GenerationSummary{Failures: []TicketFailure{
+		{TicketID: "it_1", Channel: db.AppleWalletChannel, Stage: StageStore, Err: cause},
+		{TicketID: "it_2", Channel: db.GoogleWalletChannel, Stage: StageGenerate, Err: errors.New("bad credentials")},
+	}}
+	err := summary.Err()
+	if err == nil {
+		t.Fatal("expected an error for failed tickets")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected the joined error to wrap each failure cause, got %v", err)
+	}
+	var failure TicketFailure
+	if !errors.As(err, &failure) || failure.TicketID != "it_1" {
+		t.Fatalf("expected the first failure to be reachable with errors.As, got %+v", failure)
+	}
+	want := "store apple_wallet ticket it_1: bucket unavailable\ngenerate google_wallet ticket it_2: bad credentials"
+	if err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+}
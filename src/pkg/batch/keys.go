@@ -0,0 +1,114 @@
+package batch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+)
+
+// DefaultStorageKeyTemplate is the layout passes were stored under before STORAGE_KEY_TEMPLATE existed, so
+// passes stored without a recorded key stay where they are.
+const DefaultStorageKeyTemplate = "ham-2026/{{.Channel}}/{{.FileName}}"
+
+// noOrderID stands in for OrderID when a ticket was issued without an order.
+const noOrderID = "none"
+
+// StorageKeyData is what a storage key template can refer to.
+type StorageKeyData struct {
+	EventID string
+	// Channel is the pass channel with dashes, e.g. apple-wallet.
+	Channel  string
+	Platform string
+	OrderID  string
+	TicketID string
+	// Hash is a short sha256 of the artifact content, so every regenerated pass gets a new key.
+	Hash     string
+	FileName string
+}
+
+// KeyLayout renders artifact storage keys, shared by every artifact sink.
+type KeyLayout struct {
+	tmpl *template.Template
+}
+
+// NewKeyLayout parses a text/template over StorageKeyData, e.g. DefaultStorageKeyTemplate.
+func NewKeyLayout(text string) (*KeyLayout, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("storage key template is required")
+	}
+	tmpl, err := template.New("storage_key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing storage key template: %w", err)
+	}
+
+	layout := &KeyLayout{tmpl: tmpl}
+	if _, err := layout.Key(sampleKeyData); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// sampleKeyData validates templates and tells whether they depend on the artifact content.
+var sampleKeyData = StorageKeyData{
+	EventID:  "ev_1",
+	Channel:  "apple-wallet",
+	Platform: string(PlatformApple),
+	OrderID:  "or_1",
+	TicketID: "it_1",
+	Hash:     "0123456789abcdef",
+	FileName: "it_1.pkpass",
+}
+
+// UsesHash reports whether keys depend on the artifact content, so they cannot be derived without it.
+func (l *KeyLayout) UsesHash() bool {
+	other := sampleKeyData
+	other.Hash = "fedcba9876543210"
+	a, errA := l.Key(sampleKeyData)
+	b, errB := l.Key(other)
+	return errA != nil || errB != nil || a != b
+}
+
+// Key renders the key for data. Keys are relative slash-separated paths without empty or dot segments, so they
+// are valid both as S3 keys and below a local directory.
+func (l *KeyLayout) Key(data StorageKeyData) (string, error) {
+	var out bytes.Buffer
+	if err := l.tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("rendering storage key: %w", err)
+	}
+
+	key := strings.TrimSpace(out.String())
+	if key == "" {
+		return "", fmt.Errorf("storage key is empty")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("storage key %q has an empty or relative segment", key)
+		}
+	}
+	return key, nil
+}
+
+// storageKeyData describes an artifact generated for ticket on channel.
+func storageKeyData(eventID string, channel db.PassChannel, ticket tickets.TTIssuedTicket, artifact wallet.Artifact) StorageKeyData {
+	orderID := ticket.OrderID
+	if orderID == "" {
+		orderID = noOrderID
+	}
+	sum := sha256.Sum256(artifact.Data)
+	return StorageKeyData{
+		EventID:  eventID,
+		Channel:  strings.ReplaceAll(string(channel), "_", "-"),
+		Platform: artifact.Platform,
+		OrderID:  orderID,
+		TicketID: ticket.ID,
+		Hash:     hex.EncodeToString(sum[:8]),
+		FileName: artifact.FileName,
+	}
+}
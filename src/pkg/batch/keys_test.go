@@ -0,0 +1,114 @@
+package batch
+
+import (
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+)
+
+func TestNewKeyLayout(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "default", template: DefaultStorageKeyTemplate},
+		{name: "every field", template: "{{.EventID}}/{{.Channel}}/{{.Platform}}/{{.OrderID}}/{{.TicketID}}-{{.Hash}}/{{.FileName}}"},
+		{name: "empty", template: " ", wantErr: true},
+		{name: "syntax error", template: "{{.EventID}/{{.FileName}}", wantErr: true},
+		{name: "unknown field", template: "{{.Venue}}/{{.FileName}}", wantErr: true},
+		{name: "leading slash", template: "/{{.EventID}}/{{.FileName}}", wantErr: true},
+		{name: "empty segment", template: "{{.EventID}}//{{.FileName}}", wantErr: true},
+		{name: "parent segment", template: "../{{.FileName}}", wantErr: true},
+		{name: "dot segment", template: "{{.EventID}}/./{{.FileName}}", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			layout, err := NewKeyLayout(tc.template)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected %q to be rejected", tc.template)
+				}
+				return
+			}
+			if err != nil || layout == nil {
+				t.Fatalf("NewKeyLayout(%q): %v", tc.template, err)
+			}
+		})
+	}
+}
+
+func TestKeyLayoutKey(t *testing.T) {
+	ticket := tickets.TTIssuedTicket{ID: "it_1", OrderID: "or_1"}
+	artifact := wallet.Artifact{Platform: string(PlatformApple), FileName: "it_1.pkpass", Data: []byte("pass")}
+	data := storageKeyData("ev_1", db.AppleWalletChannel, ticket, artifact)
+
+	cases := []struct {
+		name     string
+		template string
+		data     StorageKeyData
+		want     string
+		wantErr  bool
+	}{
+		{name: "default", template: DefaultStorageKeyTemplate, data: data, want: "ham-2026/apple-wallet/it_1.pkpass"},
+		{name: "per event", template: "{{.EventID}}/{{.Channel}}/{{.FileName}}", data: data, want: "ev_1/apple-wallet/it_1.pkpass"},
+		{name: "order and hash", template: "{{.OrderID}}/{{.Hash}}/{{.FileName}}", data: data, want: "or_1/" + data.Hash + "/it_1.pkpass"},
+		{
+			name:     "ticket without order",
+			template: "{{.OrderID}}/{{.FileName}}",
+			data:     storageKeyData("ev_1", db.GoogleWalletChannel, tickets.TTIssuedTicket{ID: "it_2"}, wallet.Artifact{FileName: "it_2.json"}),
+			want:     "none/it_2.json",
+		},
+		{name: "empty field leaves an empty segment", template: "{{.EventID}}/{{.FileName}}", data: StorageKeyData{FileName: "it_1.pkpass"}, wantErr: true},
+		{name: "field renders a parent segment", template: "{{.EventID}}/{{.FileName}}", data: StorageKeyData{EventID: "..", FileName: "it_1.pkpass"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			layout, err := NewKeyLayout(tc.template)
+			if err != nil {
+				t.Fatalf("NewKeyLayout: %v", err)
+			}
+			key, err := layout.Key(tc.data)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Key: %v", err)
+			}
+			if key != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, key)
+			}
+		})
+	}
+}
+
+func TestStorageKeyHashFollowsContent(t *testing.T) {
+	ticket := tickets.TTIssuedTicket{ID: "it_1"}
+	first := storageKeyData("ev_1", db.AppleWalletChannel, ticket, wallet.Artifact{Data: []byte("v1")})
+	second := storageKeyData("ev_1", db.AppleWalletChannel, ticket, wallet.Artifact{Data: []byte("v2")})
+	if first.Hash == second.Hash || len(first.Hash) != 16 {
+		t.Fatalf("expected distinct 16 character hashes, got %q and %q", first.Hash, second.Hash)
+	}
+}
+
+func TestKeyLayoutUsesHash(t *testing.T) {
+	for template, want := range map[string]bool{
+		DefaultStorageKeyTemplate:              false,
+		"{{.EventID}}/{{.TicketID}}":           false,
+		"{{.Hash}}/{{.FileName}}":              true,
+		"{{.EventID}}/{{.TicketID}}-{{.Hash}}": true,
+	} {
+		layout, err := NewKeyLayout(template)
+		if err != nil {
+			t.Fatalf("NewKeyLayout(%q): %v", template, err)
+		}
+		if got := layout.UsesHash(); got != want {
+			t.Fatalf("UsesHash(%q) = %v, want %v", template, got, want)
+		}
+	}
+}
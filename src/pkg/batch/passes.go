@@ -3,15 +3,13 @@ package batch
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/atunbetun/hakuna-wallet/pkg"
-	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
 	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
 	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
 	"github.com/atunbetun/hakuna-wallet/pkg/wallet/apple"
-	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type AppleGeneratorType string
@@ -21,65 +19,46 @@ const (
 	DefaultAppleGenerator  AppleGeneratorType = "default"
 )
 
-func newAppleGenerator(cfg pkg.AppConfig, genType AppleGeneratorType) (passGenerator, error) {
-	appleConfig, err := getAppleConfig(cfg)
+func newAppleGenerator(cfg pkg.AppConfig, genType AppleGeneratorType, conn *gorm.DB) (passGenerator, error) {
+	creator, err := NewApplePassCreator(cfg, genType, conn)
 	if err != nil {
 		return nil, err
 	}
 
-	switch genType {
-	case EmbeddedAppleGenerator:
-		return func(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error) {
-			creator := apple.NewEmbeddedApplePassCreator(appleConfig)
-			return creator.Create(ctx, ticket)
-		}, nil
-	case DefaultAppleGenerator:
-		return func(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error) {
-			creator := apple.NewDefaultApplePassCreator(appleConfig)
-			return creator.Create(ctx, ticket)
-		}, nil
-	default:
-		return nil, fmt.Errorf("unknown apple generator type: %s", genType)
-	}
-
+	return func(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error) {
+		return creator.Create(ctx, ticket)
+	}, nil
 }
 
-func newFileSink(root string) (artifactSink, error) {
-	if root == "" {
-		return nil, fmt.Errorf("tickets dir cannot be empty")
-	}
-
-	if err := os.MkdirAll(root, 0o755); err != nil {
-		return nil, fmt.Errorf("creating tickets dir: %w", err)
+// NewApplePassCreator builds the Apple pass creator, backing pass auth tokens with the database.
+func NewApplePassCreator(cfg pkg.AppConfig, genType AppleGeneratorType, conn *gorm.DB) (apple.Creator, error) {
+	appleConfig, err := getAppleConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// returns file path and error
-	return func(ctx context.Context, artifact wallet.Artifact) (string, error) {
-		if artifact.FileName == "" {
-			return "", fmt.Errorf("artifact filename is required")
-		}
-
-		if ctx.Err() != nil {
-			return "", ctx.Err()
+	var tokens apple.AuthTokenSource
+	if appleConfig.WebServiceURL != "" {
+		if conn == nil {
+			return nil, fmt.Errorf("database connection is required for apple web service tokens")
 		}
-
-		dir := filepath.Join(root, artifact.Platform)
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			return "", fmt.Errorf("creating platform directory: %w", err)
+		tokens = func(ctx context.Context, passTypeIdentifier string, serialNumber string) (string, error) {
+			return db.GetOrCreateApplePassAuthToken(ctx, conn, passTypeIdentifier, serialNumber)
 		}
+	}
 
-		fullPath := filepath.Join(dir, artifact.FileName)
-		if err := os.WriteFile(fullPath, artifact.Data, 0o600); err != nil {
-			return "", fmt.Errorf("writing artifact to %s: %w", fullPath, err)
-		}
-		logger.Logger.Debug(
-			"Wrote wallet artifact to disk",
-			zap.String("platform", artifact.Platform),
-			zap.String("file_name", artifact.FileName),
-			zap.String("path", fullPath),
-		)
-		return fullPath, nil
-	}, nil
+	switch genType {
+	case EmbeddedAppleGenerator:
+		creator := apple.NewEmbeddedApplePassCreator(appleConfig)
+		creator.AuthTokens = tokens
+		return creator, nil
+	case DefaultAppleGenerator:
+		creator := apple.NewDefaultApplePassCreator(appleConfig)
+		creator.AuthTokens = tokens
+		return creator, nil
+	default:
+		return nil, fmt.Errorf("unknown apple generator type: %s", genType)
+	}
 }
 
 func getAppleConfig(cfg pkg.AppConfig) (apple.AppleConfig, error) {
@@ -102,6 +81,9 @@ func getAppleConfig(cfg pkg.AppConfig) (apple.AppleConfig, error) {
 		SigningCertificatePath:     cfg.AppleP12Path,
 		SigningCertificatePassword: cfg.AppleP12Password,
 		AppleRootCertificatePath:   cfg.AppleRootCertPath,
+		WebServiceURL:              cfg.AppleWebServiceURL,
+		APNsKeyID:                  cfg.AppleAPNsKeyID,
+		APNsKeyPath:                cfg.AppleAPNsKeyPath,
 	}
 	return appleConfig, nil
 }
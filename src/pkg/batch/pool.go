@@ -0,0 +1,35 @@
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultSyncConcurrency is used when SYNC_CONCURRENCY is not positive.
+const defaultSyncConcurrency = 4
+
+// forEach runs fn for every item on at most limit goroutines and waits for them. Items not started before ctx is
+// cancelled are skipped.
+func forEach[T any](ctx context.Context, limit int, items []T, fn func(ctx context.Context, i int, item T)) {
+	if limit < 1 {
+		limit = defaultSyncConcurrency
+	}
+
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			return
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(ctx, i, item)
+		}()
+	}
+}
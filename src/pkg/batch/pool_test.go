@@ -0,0 +1,106 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachVisitsEveryItemAtItsIndex(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f", "g"}
+	results := make([]string, len(items))
+	forEach(context.Background(), 3, items, func(_ context.Context, i int, item string) {
+		results[i] = item
+	})
+
+	for i, item := range items {
+		if results[i] != item {
+			t.Fatalf("expected %q at index %d, got %q", item, i, results[i])
+		}
+	}
+}
+
+func TestForEachLimitsConcurrency(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{name: "configured", limit: 2, want: 2},
+		{name: "zero falls back to default", limit: 0, want: defaultSyncConcurrency},
+		{name: "negative falls back to default", limit: -1, want: defaultSyncConcurrency},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			items := make([]int, tc.want*3)
+			started := make(chan int, len(items))
+			release := make(chan struct{})
+			var running, peak atomic.Int32
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				forEach(context.Background(), tc.limit, items, func(_ context.Context, i int, _ int) {
+					n := running.Add(1)
+					for {
+						p := peak.Load()
+						if n <= p || peak.CompareAndSwap(p, n) {
+							break
+						}
+					}
+					started <- i
+					<-release
+					running.Add(-1)
+				})
+			}()
+
+			for range tc.want {
+				select {
+				case <-started:
+				case <-time.After(time.Second):
+					t.Fatalf("expected %d items to start", tc.want)
+				}
+			}
+			select {
+			case i := <-started:
+				t.Fatalf("item %d started while %d were already running", i, tc.want)
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			close(release)
+			<-done
+			if got := int(peak.Load()); got != tc.want {
+				t.Fatalf("expected at most %d concurrent items, got %d", tc.want, got)
+			}
+			if len(started) != len(items)-tc.want {
+				t.Fatalf("expected every item to run, %d did not start", len(items)-tc.want-len(started))
+			}
+		})
+	}
+}
+
+func TestForEachStopsStartingItemsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	items := make([]int, 50)
+	var mu sync.Mutex
+	var ran []int
+	forEach(ctx, 1, items, func(ctx context.Context, i int, _ int) {
+		mu.Lock()
+		ran = append(ran, i)
+		mu.Unlock()
+		if i == 2 {
+			cancel()
+		}
+	})
+
+	if len(ran) < 3 || ran[0] != 0 || ran[1] != 1 || ran[2] != 2 {
+		t.Fatalf("expected the items before the cancel to run in order, got %v", ran)
+	}
+	if len(ran) == len(items) {
+		t.Fatalf("expected items after the cancel to be skipped, all %d ran", len(ran))
+	}
+}
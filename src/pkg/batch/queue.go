@@ -0,0 +1,71 @@
+package batch
+
+import (
+	"context"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// TicketQueue hands single ticket IDs from webhook deliveries to one background worker, so requests return
+// immediately and tickets are synced one at a time.
+type TicketQueue struct {
+	ticketIDs chan string
+	sync      func(ctx context.Context, ticketID string) error
+}
+
+// NewTicketQueue buffers up to size ticket IDs for sync.
+func NewTicketQueue(size int, sync func(ctx context.Context, ticketID string) error) *TicketQueue {
+	if size < 1 {
+		size = 1
+	}
+	return &TicketQueue{
+		ticketIDs: make(chan string, size),
+		sync:      sync,
+	}
+}
+
+// NewSyncerTicketQueue queues tickets for syncer.SyncTicket.
+func NewSyncerTicketQueue(size int, syncer *walletTicketSyncer) *TicketQueue {
+	return NewTicketQueue(size, func(ctx context.Context, ticketID string) error {
+		summary, err := syncer.SyncTicket(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+		if err := summary.Err(); err != nil {
+			return err
+		}
+		logger.Logger.Info(
+			"Synced webhook ticket",
+			zap.String("ticket_id", ticketID),
+			zap.Int("artifacts", len(summary.Artifacts)),
+			zap.Int("voided", len(summary.Voided)),
+		)
+		return nil
+	})
+}
+
+// Enqueue adds a ticket without blocking and reports false when the queue is full.
+func (q *TicketQueue) Enqueue(ticketID string) bool {
+	select {
+	case q.ticketIDs <- ticketID:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run syncs queued tickets until ctx is cancelled. Failures are logged; the periodic batch run picks the
+// ticket up again.
+func (q *TicketQueue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ticketID := <-q.ticketIDs:
+			if err := q.sync(ctx, ticketID); err != nil {
+				logger.Logger.Error("syncing webhook ticket", zap.String("ticket_id", ticketID), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// CronTrigger marks runs started by the container schedule.
+	CronTrigger = "cron"
+	// ManualTrigger marks runs started by hand.
+	ManualTrigger = "manual"
+
+	defaultSyncRunsLimit = 20
+)
+
+// finishSyncRun stores the outcome of a run in sync_runs. It still writes when ctx was cancelled, so runs that time
+// out are not left running.
+func finishSyncRun(ctx context.Context, conn *gorm.DB, run db.SyncRun, summary GenerationSummary, runErr error) {
+	ctx = context.WithoutCancel(ctx)
+
+	failures := make([]db.SyncFailure, len(summary.Failures))
+	for i, failure := range summary.Failures {
+		failures[i] = db.SyncFailure{
+			TicketTailorID: failure.TicketID,
+			Channel:        string(failure.Channel),
+			Stage:          string(failure.Stage),
+			Error:          failure.Err.Error(),
+		}
+	}
+
+	result := db.SyncRunResult{
+		Fetched:  summary.Fetched,
+		New:      summary.New,
+		Produced: len(summary.Artifacts),
+		Voided:   len(summary.Voided),
+		Sent:     summary.Sent,
+		Failures: failures,
+		Err:      runErr,
+	}
+	if err := db.FinishSyncRun(ctx, conn, run.ID, result, time.Now()); err != nil {
+		logger.Logger.Error("finishing sync run", zap.String("sync_run_id", run.ID), zap.Error(err))
+	}
+}
+
+// PrintSyncRuns writes the latest sync runs as a table, newest first.
+func PrintSyncRuns(ctx context.Context, cfg pkg.AppConfig, w io.Writer, limit int) error {
+	if limit < 1 {
+		limit = defaultSyncRunsLimit
+	}
+	return withDatabase(ctx, cfg, func(conn *gorm.DB) error {
+		runs, err := db.ListSyncRuns(ctx, conn, limit)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tTRIGGER\tSTATUS\tFETCHED\tNEW\tPRODUCED\tVOIDED\tFAILED\tSENT")
+		for _, run := range runs {
+			fmt.Fprintf(
+				tw,
+				"%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+				run.ID,
+				run.StartedAt.UTC().Format(time.RFC3339),
+				runDuration(run),
+				run.Trigger,
+				run.Status,
+				run.Fetched,
+				run.New,
+				run.Produced,
+				run.Voided,
+				run.Failed,
+				run.Sent,
+			)
+		}
+		return tw.Flush()
+	})
+}
+
+// PrintSyncRun writes one sync run with its error and every ticket that failed in it.
+func PrintSyncRun(ctx context.Context, cfg pkg.AppConfig, w io.Writer, id string) error {
+	return withDatabase(ctx, cfg, func(conn *gorm.DB) error {
+		run, err := db.GetSyncRun(ctx, conn, id)
+		if err != nil {
+			return fmt.Errorf("sync run %s: %w", id, err)
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID\t%s\n", run.ID)
+		fmt.Fprintf(tw, "Event\t%s\n", run.EventID)
+		fmt.Fprintf(tw, "Trigger\t%s\n", run.Trigger)
+		fmt.Fprintf(tw, "Status\t%s\n", run.Status)
+		fmt.Fprintf(tw, "Started\t%s\n", run.StartedAt.UTC().Format(time.RFC3339))
+		if run.FinishedAt != nil {
+			fmt.Fprintf(tw, "Finished\t%s\n", run.FinishedAt.UTC().Format(time.RFC3339))
+		}
+		fmt.Fprintf(tw, "Duration\t%s\n", runDuration(run))
+		fmt.Fprintf(tw, "Fetched\t%d\n", run.Fetched)
+		fmt.Fprintf(tw, "New\t%d\n", run.New)
+		fmt.Fprintf(tw, "Produced\t%d\n", run.Produced)
+		fmt.Fprintf(tw, "Voided\t%d\n", run.Voided)
+		fmt.Fprintf(tw, "Failed\t%d\n", run.Failed)
+		fmt.Fprintf(tw, "Sent\t%d\n", run.Sent)
+		if run.Error != nil {
+			fmt.Fprintf(tw, "Error\t%s\n", *run.Error)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if len(run.Failures) == 0 {
+			return nil
+		}
+
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TICKET\tCHANNEL\tSTAGE\tERROR")
+		for _, failure := range run.Failures {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", failure.TicketTailorID, failure.Channel, failure.Stage, failure.Error)
+		}
+		return tw.Flush()
+	})
+}
+
+// runDuration is how long a finished run took, or "-" while it is still running.
+func runDuration(run db.SyncRun) string {
+	if run.FinishedAt == nil {
+		return "-"
+	}
+	return run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond).String()
+}
+
+func withDatabase(ctx context.Context, cfg pkg.AppConfig, fn func(conn *gorm.DB) error) error {
+	databaseCfg, err := db.FromAppConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Open(ctx, databaseCfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(conn); err != nil {
+			logger.Logger.Error("closing database", zap.Error(err))
+		}
+	}()
+	return fn(conn)
+}
@@ -0,0 +1,170 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/aws"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+	"go.uber.org/zap"
+)
+
+// Artifact sinks selectable with ARTIFACT_SINKS.
+const (
+	FileArtifactSink = "file"
+	S3ArtifactSink   = "s3"
+)
+
+// ArtifactSink persists a generated wallet artifact under its storage key, see KeyLayout.
+type ArtifactSink interface {
+	Put(ctx context.Context, key string, artifact wallet.Artifact) error
+}
+
+// FileSink writes artifacts to <root>/<key>.
+type FileSink struct {
+	Root string
+}
+
+// NewFileSink creates root when missing.
+func NewFileSink(root string) (*FileSink, error) {
+	if root == "" {
+		return nil, fmt.Errorf("tickets dir cannot be empty")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("creating tickets dir: %w", err)
+	}
+	return &FileSink{Root: root}, nil
+}
+
+// Put writes the artifact, replacing an earlier version of the same file.
+func (s *FileSink) Put(ctx context.Context, key string, artifact wallet.Artifact) error {
+	if key == "" {
+		return fmt.Errorf("storage key is required")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	fullPath := s.Path(key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return fmt.Errorf("creating artifact directory: %w", err)
+	}
+	if err := os.WriteFile(fullPath, artifact.Data, 0o600); err != nil {
+		return fmt.Errorf("writing artifact to %s: %w", fullPath, err)
+	}
+	logger.Logger.Debug(
+		"Wrote wallet artifact to disk",
+		zap.String("platform", artifact.Platform),
+		zap.String("file_name", artifact.FileName),
+		zap.String("path", fullPath),
+	)
+	return nil
+}
+
+// Path is where the artifact stored under key lives on disk.
+func (s *FileSink) Path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(key))
+}
+
+// S3Sink uploads artifacts straight from memory.
+type S3Sink struct {
+	Client *aws.S3Client
+	Bucket string
+}
+
+// NewS3Sink uploads into bucket.
+func NewS3Sink(client *aws.S3Client, bucket string) (*S3Sink, error) {
+	if client == nil {
+		return nil, fmt.Errorf("s3 client is required")
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	return &S3Sink{Client: client, Bucket: bucket}, nil
+}
+
+// Put uploads the artifact bytes with the artifact's content type.
+func (s *S3Sink) Put(ctx context.Context, key string, artifact wallet.Artifact) error {
+	if key == "" {
+		return fmt.Errorf("storage key is required")
+	}
+
+	logger.Logger.Debug(
+		"Uploading to s3",
+		zap.String("bucket", s.Bucket),
+		zap.String("key", key),
+	)
+	if _, err := s.Client.UploadBytes(ctx, s.Bucket, key, artifact.Data, artifact.ContentType); err != nil {
+		return fmt.Errorf("uploading %s: %w", key, err)
+	}
+	return nil
+}
+
+// FanOutSink puts every artifact into each sink in order, stopping at the first failure.
+type FanOutSink []ArtifactSink
+
+// Put stores the artifact in every sink.
+func (s FanOutSink) Put(ctx context.Context, key string, artifact wallet.Artifact) error {
+	for _, sink := range s {
+		if err := sink.Put(ctx, key, artifact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newArtifactSink builds the sinks listed in ARTIFACT_SINKS, fanning out when there is more than one. Blank entries are
+// ignored and a sink listed twice is rejected.
+func newArtifactSink(cfg pkg.AppConfig, s3 *aws.S3Client) (ArtifactSink, error) {
+	var sinks FanOutSink
+	seen := make(map[string]bool, len(cfg.ArtifactSinks))
+	for _, name := range cfg.ArtifactSinks {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("artifact sink listed twice: %s", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case FileArtifactSink:
+			sink, err := NewFileSink(cfg.TicketsDir)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case S3ArtifactSink:
+			sink, err := NewS3Sink(s3, cfg.S3Bucket)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown artifact sink: %s", name)
+		}
+	}
+
+	switch len(sinks) {
+	case 0:
+		return nil, fmt.Errorf("at least one artifact sink is required")
+	case 1:
+		return sinks[0], nil
+	default:
+		return sinks, nil
+	}
+}
+
+// HasArtifactSink reports whether ARTIFACT_SINKS lists name.
+func HasArtifactSink(cfg pkg.AppConfig, name string) bool {
+	return slices.ContainsFunc(cfg.ArtifactSinks, func(s string) bool {
+		return strings.TrimSpace(s) == name
+	})
+}
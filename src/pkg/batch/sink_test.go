@@ -0,0 +1,142 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/aws"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+)
+
+// recordingSink records the keys it was asked to store and fails with err when set.
+type recordingSink struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (s recordingSink) Put(_ context.Context, key string, _ wallet.Artifact) error {
+	*s.calls = append(*s.calls, s.name+":"+key)
+	return s.err
+}
+
+func TestNewArtifactSink(t *testing.T) {
+	cases := []struct {
+		name    string
+		sinks   []string
+		want    any
+		wantErr bool
+	}{
+		{name: "file", sinks: []string{"file"}, want: &FileSink{}},
+		{name: "s3", sinks: []string{" s3 "}, want: &S3Sink{}},
+		{name: "both fan out", sinks: []string{"file", "s3"}, want: FanOutSink{}},
+		{name: "blank entries are ignored", sinks: []string{"", "file", " "}, want: &FileSink{}},
+		{name: "unknown", sinks: []string{"file", "gcs"}, wantErr: true},
+		{name: "duplicate", sinks: []string{"s3", " s3"}, wantErr: true},
+		{name: "only blank entries", sinks: []string{" ", ""}, wantErr: true},
+		{name: "empty", sinks: nil, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := pkg.AppConfig{ArtifactSinks: tc.sinks, TicketsDir: t.TempDir(), S3Bucket: "passes"}
+			sink, err := newArtifactSink(cfg, &aws.S3Client{})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %T", sink)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newArtifactSink: %v", err)
+			}
+			if reflect.TypeOf(sink) != reflect.TypeOf(tc.want) {
+				t.Fatalf("expected a %T, got %T", tc.want, sink)
+			}
+			if fanOut, ok := sink.(FanOutSink); ok && len(fanOut) != 2 {
+				t.Fatalf("expected two sinks, got %d", len(fanOut))
+			}
+		})
+	}
+}
+
+func TestFanOutSinkStopsAtFirstError(t *testing.T) {
+	var calls []string
+	cause := errors.New("bucket unavailable")
+	sink := FanOutSink{
+		recordingSink{name: "first", calls: &calls},
+		recordingSink{name: "second", calls: &calls, err: cause},
+		recordingSink{name: "third", calls: &calls},
+	}
+
+	err := sink.Put(context.Background(), "ev_1/apple/pass.pkpass", wallet.Artifact{})
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected the second sink's error, got %v", err)
+	}
+	want := []string{"first:ev_1/apple/pass.pkpass", "second:ev_1/apple/pass.pkpass"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected %v, got %v", want, calls)
+	}
+}
+
+func TestFileSinkPut(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "tickets")
+	sink, err := NewFileSink(root)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Fatalf("expected the root to be created: %v", err)
+	}
+
+	key := "ev_1/apple/it_1.pkpass"
+	for _, data := range []string{"first", "second"} {
+		if err := sink.Put(context.Background(), key, wallet.Artifact{Data: []byte(data)}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	path := sink.Path(key)
+	if want := filepath.Join(root, "ev_1", "apple", "it_1.pkpass"); path != want {
+		t.Fatalf("expected %s, got %s", want, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading artifact: %v", err)
+	}
+	if string(data) != "second" {
+		t.Fatalf("expected the artifact to be replaced, got %q", data)
+	}
+
+	if err := sink.Put(context.Background(), "", wallet.Artifact{}); err == nil {
+		t.Fatal("expected an error without a storage key")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sink.Put(ctx, "ev_1/apple/it_2.pkpass", wallet.Artifact{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled context's error, got %v", err)
+	}
+	if _, err := NewFileSink(""); err == nil {
+		t.Fatal("expected an error without a root")
+	}
+}
+
+func TestNewS3Sink(t *testing.T) {
+	if _, err := NewS3Sink(nil, "passes"); err == nil {
+		t.Fatal("expected an error without a client")
+	}
+	if _, err := NewS3Sink(&aws.S3Client{}, ""); err == nil {
+		t.Fatal("expected an error without a bucket")
+	}
+	sink, err := NewS3Sink(&aws.S3Client{}, "passes")
+	if err != nil {
+		t.Fatalf("NewS3Sink: %v", err)
+	}
+	if err := sink.Put(context.Background(), "", wallet.Artifact{}); err == nil {
+		t.Fatal("expected an error without a storage key")
+	}
+}
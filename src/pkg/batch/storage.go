@@ -0,0 +1,302 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/aws"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/mailer"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// storedObjects reads and deletes stored passes in the s3 sink.
+type storedObjects interface {
+	OpenObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// storageKeyStore records the key a pass is stored under on the pass and its pending email attachments.
+type storageKeyStore interface {
+	SetPassStorageKey(ctx context.Context, channel db.PassChannel, ticketTailorID string, key string) error
+	RenameOutboxAttachmentKey(ctx context.Context, from string, to string) error
+}
+
+// dbStorageKeys records storage keys in the database.
+type dbStorageKeys struct {
+	conn *gorm.DB
+}
+
+func (s dbStorageKeys) SetPassStorageKey(ctx context.Context, channel db.PassChannel, ticketTailorID string, key string) error {
+	return db.SetPassMetadata(ctx, s.conn, channel, ticketTailorID, map[string]any{db.PassStorageKeyKey: key})
+}
+
+func (s dbStorageKeys) RenameOutboxAttachmentKey(ctx context.Context, from string, to string) error {
+	_, err := db.RenameOutboxAttachmentKey(ctx, s.conn, from, to)
+	return err
+}
+
+// storageMover relocates the stored passes of one event from one key layout to another.
+type storageMover struct {
+	eventID string
+	from    *KeyLayout
+	to      *KeyLayout
+	sink    ArtifactSink
+	s3      storedObjects
+	bucket  string
+	files   *FileSink
+	keys    storageKeyStore
+	dryRun  bool
+}
+
+// MigrateStorage moves every stored pass of the event from the from key layout to STORAGE_KEY_TEMPLATE in each
+// sink listed in ARTIFACT_SINKS, then records the new keys on the passes and on pending email attachments.
+// Passes stored before keys were recorded are located with the from layout. With dryRun nothing is changed.
+func MigrateStorage(ctx context.Context, cfg pkg.AppConfig, from string, dryRun bool) error {
+	fromLayout, err := NewKeyLayout(from)
+	if err != nil {
+		return fmt.Errorf("from layout: %w", err)
+	}
+	toLayout, err := NewKeyLayout(cfg.StorageKeyTemplate)
+	if err != nil {
+		return fmt.Errorf("to layout: %w", err)
+	}
+
+	databaseCfg, err := db.FromAppConfig(cfg)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Open(ctx, databaseCfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(conn); err != nil {
+			logger.Logger.Error("closing database", zap.Error(err))
+		}
+	}()
+
+	ticketCfg, err := tickets.NewTicketTailorConfig(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := tickets.NewClient(ticketCfg)
+	if err != nil {
+		return err
+	}
+	issued, err := client.FetchAllIssuedTickets(ctx, "")
+	if err != nil {
+		return fmt.Errorf("fetching issued tickets: %w", err)
+	}
+	byID := make(map[string]tickets.TTIssuedTicket, len(issued))
+	for _, ticket := range issued {
+		byID[ticket.ID] = ticket
+	}
+
+	mover := &storageMover{
+		eventID: ticketCfg.EventId,
+		from:    fromLayout,
+		to:      toLayout,
+		bucket:  cfg.S3Bucket,
+		keys:    dbStorageKeys{conn: conn},
+		dryRun:  dryRun,
+	}
+	var s3Client *aws.S3Client
+	if HasArtifactSink(cfg, S3ArtifactSink) {
+		awsConfig, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("loading aws config: %w", err)
+		}
+		if s3Client, err = aws.NewS3Client(cfg.S3Bucket, &awsConfig); err != nil {
+			return err
+		}
+		mover.s3 = s3Client
+	}
+	if HasArtifactSink(cfg, FileArtifactSink) {
+		if mover.files, err = NewFileSink(cfg.TicketsDir); err != nil {
+			return err
+		}
+	}
+	if mover.sink, err = newArtifactSink(cfg, s3Client); err != nil {
+		return err
+	}
+
+	var moved, failed int
+	for _, raw := range cfg.WalletChannels {
+		channel := db.PassChannel(strings.TrimSpace(raw))
+		keys, err := db.GetPassStorageKeys(ctx, conn, channel)
+		if err != nil {
+			return err
+		}
+
+		for ticketID, stored := range keys {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			ticket, ok := byID[ticketID]
+			if !ok {
+				logger.Logger.Warn("stored pass has no issued ticket, skipping", zap.String("ticket_id", ticketID))
+				continue
+			}
+
+			changed, err := mover.move(ctx, channel, ticket, stored)
+			if err != nil {
+				failed++
+				logger.Logger.Error(
+					"moving stored pass",
+					zap.String("ticket_id", ticketID),
+					zap.String("channel", string(channel)),
+					zap.Error(err),
+				)
+				continue
+			}
+			if changed {
+				moved++
+			}
+		}
+	}
+
+	logger.Logger.Info("Storage migration finished", zap.Int("moved", moved), zap.Int("failed", failed), zap.Bool("dry_run", dryRun))
+	if failed > 0 {
+		return fmt.Errorf("%d stored passes could not be moved", failed)
+	}
+	return nil
+}
+
+// move relocates one pass and reports whether its key changed.
+func (m *storageMover) move(ctx context.Context, channel db.PassChannel, ticket tickets.TTIssuedTicket, stored string) (bool, error) {
+	artifact, err := storedArtifact(channel, ticket.ID)
+	if err != nil {
+		return false, err
+	}
+
+	oldKey := stored
+	if oldKey == "" {
+		// The content is not known yet, so a from layout using Hash cannot locate unrecorded passes.
+		if m.from.UsesHash() {
+			return false, fmt.Errorf("pass has no recorded storage key and the from layout uses Hash")
+		}
+		if oldKey, err = m.from.Key(storageKeyData(m.eventID, channel, ticket, artifact)); err != nil {
+			return false, err
+		}
+	}
+
+	artifact.Data, err = m.read(ctx, oldKey, artifact)
+	if err != nil {
+		return false, err
+	}
+	newKey, err := m.to.Key(storageKeyData(m.eventID, channel, ticket, artifact))
+	if err != nil {
+		return false, err
+	}
+
+	fields := []zap.Field{zap.String("ticket_id", ticket.ID), zap.String("from", oldKey), zap.String("to", newKey)}
+	if m.dryRun {
+		logger.Logger.Info("Would move stored pass", fields...)
+		return newKey != oldKey, nil
+	}
+
+	if newKey != oldKey {
+		if err := m.sink.Put(ctx, newKey, artifact); err != nil {
+			return false, err
+		}
+	}
+	if err := m.keys.SetPassStorageKey(ctx, channel, ticket.ID, newKey); err != nil {
+		return false, fmt.Errorf("recording storage key: %w", err)
+	}
+	if newKey == oldKey {
+		return false, nil
+	}
+	if err := m.keys.RenameOutboxAttachmentKey(ctx, oldKey, newKey); err != nil {
+		return false, err
+	}
+	if err := m.remove(ctx, oldKey, newKey, artifact); err != nil {
+		return false, err
+	}
+
+	logger.Logger.Info("Moved stored pass", fields...)
+	return true, nil
+}
+
+// read loads the pass from S3 when that sink is enabled, otherwise from disk.
+func (m *storageMover) read(ctx context.Context, key string, artifact wallet.Artifact) ([]byte, error) {
+	if m.s3 != nil {
+		body, err := m.s3.OpenObject(ctx, m.bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	for _, path := range m.filePaths(key, artifact) {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("no stored file for %s", key)
+}
+
+// remove deletes the pass from its old location in every sink, keeping the file just written under newKey.
+func (m *storageMover) remove(ctx context.Context, key string, newKey string, artifact wallet.Artifact) error {
+	if m.s3 != nil {
+		if err := m.s3.DeleteObject(ctx, m.bucket, key); err != nil {
+			return err
+		}
+	}
+	for _, path := range m.filePaths(key, artifact) {
+		if path == m.files.Path(newKey) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// filePaths lists where the file sink may hold the pass: under its key, or under <platform>/<file name> as
+// written before the file sink used storage keys.
+func (m *storageMover) filePaths(key string, artifact wallet.Artifact) []string {
+	if m.files == nil {
+		return nil
+	}
+	return []string{m.files.Path(key), filepath.Join(m.files.Root, artifact.Platform, artifact.FileName)}
+}
+
+// storedArtifact describes the artifact the channel's generator stores for a ticket, without its content.
+func storedArtifact(channel db.PassChannel, ticketID string) (wallet.Artifact, error) {
+	switch channel {
+	case db.AppleWalletChannel:
+		return wallet.Artifact{
+			Platform:    string(PlatformApple),
+			FileName:    ticketID + ".pkpass",
+			ContentType: mailer.PassContentType,
+		}, nil
+	case db.GoogleWalletChannel:
+		return wallet.Artifact{
+			Platform:    string(PlatformGoogle),
+			FileName:    ticketID + ".json",
+			ContentType: "application/json",
+		}, nil
+	default:
+		return wallet.Artifact{}, fmt.Errorf("unknown wallet channel: %s", channel)
+	}
+}
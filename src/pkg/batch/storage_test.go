@@ -0,0 +1,198 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
+)
+
+// memoryObjects is an s3 bucket in memory; it is also the sink the mover copies into.
+type memoryObjects struct {
+	objects map[string][]byte
+	deleted []string
+}
+
+func (o *memoryObjects) Put(_ context.Context, key string, artifact wallet.Artifact) error {
+	o.objects[key] = artifact.Data
+	return nil
+}
+
+func (o *memoryObjects) OpenObject(_ context.Context, _ string, key string) (io.ReadCloser, error) {
+	data, ok := o.objects[key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (o *memoryObjects) DeleteObject(_ context.Context, _ string, key string) error {
+	o.deleted = append(o.deleted, key)
+	delete(o.objects, key)
+	return nil
+}
+
+// recordedKeys remembers the storage keys the mover records.
+type recordedKeys struct {
+	passes  map[string]string
+	renamed map[string]string
+}
+
+func newRecordedKeys() *recordedKeys {
+	return &recordedKeys{passes: map[string]string{}, renamed: map[string]string{}}
+}
+
+func (k *recordedKeys) SetPassStorageKey(_ context.Context, channel db.PassChannel, ticketTailorID string, key string) error {
+	k.passes[string(channel)+"/"+ticketTailorID] = key
+	return nil
+}
+
+func (k *recordedKeys) RenameOutboxAttachmentKey(_ context.Context, from string, to string) error {
+	k.renamed[from] = to
+	return nil
+}
+
+func newTestMover(t *testing.T, from string, to string, objects *memoryObjects, keys *recordedKeys) *storageMover {
+	t.Helper()
+	fromLayout, err := NewKeyLayout(from)
+	if err != nil {
+		t.Fatalf("from layout: %v", err)
+	}
+	toLayout, err := NewKeyLayout(to)
+	if err != nil {
+		t.Fatalf("to layout: %v", err)
+	}
+	return &storageMover{
+		eventID: "ev_1",
+		from:    fromLayout,
+		to:      toLayout,
+		sink:    objects,
+		s3:      objects,
+		bucket:  "passes",
+		keys:    keys,
+	}
+}
+
+func TestStorageMoverMove(t *testing.T) {
+	ticket := tickets.TTIssuedTicket{ID: "it_1", OrderID: "or_1"}
+	const perEvent = "{{.EventID}}/{{.Channel}}/{{.FileName}}"
+
+	t.Run("recorded key is copied, recorded, repointed and deleted", func(t *testing.T) {
+		objects := &memoryObjects{objects: map[string][]byte{"old/it_1.pkpass": []byte("pass")}}
+		keys := newRecordedKeys()
+		mover := newTestMover(t, "{{.Hash}}/{{.FileName}}", perEvent, objects, keys)
+
+		moved, err := mover.move(context.Background(), db.AppleWalletChannel, ticket, "old/it_1.pkpass")
+		if err != nil || !moved {
+			t.Fatalf("expected the pass to move, got %v, %v", moved, err)
+		}
+		if want := map[string][]byte{"ev_1/apple-wallet/it_1.pkpass": []byte("pass")}; !reflect.DeepEqual(objects.objects, want) {
+			t.Fatalf("expected only the new object, got %v", objects.objects)
+		}
+		if keys.passes["apple_wallet/it_1"] != "ev_1/apple-wallet/it_1.pkpass" {
+			t.Fatalf("expected the new key to be recorded, got %v", keys.passes)
+		}
+		if keys.renamed["old/it_1.pkpass"] != "ev_1/apple-wallet/it_1.pkpass" {
+			t.Fatalf("expected pending attachments to be repointed, got %v", keys.renamed)
+		}
+	})
+
+	t.Run("unrecorded key is found with the from layout", func(t *testing.T) {
+		objects := &memoryObjects{objects: map[string][]byte{"ham-2026/google-wallet/it_1.json": []byte("{}")}}
+		keys := newRecordedKeys()
+		mover := newTestMover(t, DefaultStorageKeyTemplate, "{{.EventID}}/{{.Hash}}/{{.FileName}}", objects, keys)
+
+		moved, err := mover.move(context.Background(), db.GoogleWalletChannel, ticket, "")
+		if err != nil || !moved {
+			t.Fatalf("expected the pass to move, got %v, %v", moved, err)
+		}
+		newKey := keys.passes["google_wallet/it_1"]
+		if _, ok := objects.objects[newKey]; !ok || len(objects.objects) != 1 {
+			t.Fatalf("expected the pass under %q only, got %v", newKey, objects.objects)
+		}
+		if !reflect.DeepEqual(objects.deleted, []string{"ham-2026/google-wallet/it_1.json"}) {
+			t.Fatalf("expected the old object to be deleted, got %v", objects.deleted)
+		}
+	})
+
+	t.Run("unrecorded key cannot be found with a from layout using Hash", func(t *testing.T) {
+		objects := &memoryObjects{objects: map[string][]byte{}}
+		keys := newRecordedKeys()
+		mover := newTestMover(t, "{{.Hash}}/{{.FileName}}", perEvent, objects, keys)
+
+		if _, err := mover.move(context.Background(), db.AppleWalletChannel, ticket, ""); err == nil {
+			t.Fatal("expected an error for a from layout using Hash")
+		}
+		if len(keys.passes) != 0 || len(objects.deleted) != 0 {
+			t.Fatalf("expected nothing to change, got keys %v and deletes %v", keys.passes, objects.deleted)
+		}
+	})
+
+	t.Run("unchanged key is only recorded", func(t *testing.T) {
+		objects := &memoryObjects{objects: map[string][]byte{"ev_1/apple-wallet/it_1.pkpass": []byte("pass")}}
+		keys := newRecordedKeys()
+		mover := newTestMover(t, DefaultStorageKeyTemplate, perEvent, objects, keys)
+
+		moved, err := mover.move(context.Background(), db.AppleWalletChannel, ticket, "ev_1/apple-wallet/it_1.pkpass")
+		if err != nil || moved {
+			t.Fatalf("expected the pass to stay, got %v, %v", moved, err)
+		}
+		if keys.passes["apple_wallet/it_1"] != "ev_1/apple-wallet/it_1.pkpass" || len(keys.renamed) != 0 || len(objects.deleted) != 0 {
+			t.Fatalf("expected only the key to be recorded, got keys %v, renames %v, deletes %v", keys.passes, keys.renamed, objects.deleted)
+		}
+	})
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		objects := &memoryObjects{objects: map[string][]byte{"ham-2026/apple-wallet/it_1.pkpass": []byte("pass")}}
+		keys := newRecordedKeys()
+		mover := newTestMover(t, DefaultStorageKeyTemplate, perEvent, objects, keys)
+		mover.dryRun = true
+
+		moved, err := mover.move(context.Background(), db.AppleWalletChannel, ticket, "")
+		if err != nil || !moved {
+			t.Fatalf("expected the move to be reported, got %v, %v", moved, err)
+		}
+		if len(objects.objects) != 1 || len(objects.deleted) != 0 || len(keys.passes) != 0 || len(keys.renamed) != 0 {
+			t.Fatalf("expected nothing to change, got objects %v, keys %v, renames %v", objects.objects, keys.passes, keys.renamed)
+		}
+	})
+}
+
+func TestStorageMoverMoveFiles(t *testing.T) {
+	files, err := NewFileSink(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	// Written under <platform>/<file name> before the file sink used storage keys.
+	oldPath := filepath.Join(files.Root, "apple", "it_1.pkpass")
+	if err := os.MkdirAll(filepath.Dir(oldPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldPath, []byte("pass"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := newRecordedKeys()
+	mover := newTestMover(t, DefaultStorageKeyTemplate, "{{.EventID}}/{{.Channel}}/{{.FileName}}", &memoryObjects{}, keys)
+	mover.sink, mover.s3, mover.files = files, nil, files
+
+	moved, err := mover.move(context.Background(), db.AppleWalletChannel, tickets.TTIssuedTicket{ID: "it_1"}, "")
+	if err != nil || !moved {
+		t.Fatalf("expected the pass to move, got %v, %v", moved, err)
+	}
+	data, err := os.ReadFile(files.Path("ev_1/apple-wallet/it_1.pkpass"))
+	if err != nil || string(data) != "pass" {
+		t.Fatalf("expected the pass at its new key, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the old file to be removed, got %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package batch
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/atunbetun/hakuna-wallet/pkg"
 	"github.com/atunbetun/hakuna-wallet/pkg/db"
@@ -16,12 +18,12 @@ func PurgeTickets(ctx context.Context, cfg pkg.AppConfig) error {
 	if err != nil {
 		return err
 	}
-	err = ticketTailorConfig.Validate()
+	client, err := tickets.NewClient(ticketTailorConfig)
 	if err != nil {
 		return err
 	}
 
-	tick, err := tickets.FetchAllIssuedTickets(ctx, ticketTailorConfig, "")
+	tick, err := client.FetchAllIssuedTickets(ctx, "")
 	if err != nil {
 		return err
 	}
@@ -31,12 +33,16 @@ func PurgeTickets(ctx context.Context, cfg pkg.AppConfig) error {
 		var check tickets.CheckAction
 		check = tickets.CheckIn
 		logger.Logger.Debug(fmt.Sprintf("%s ticket, loop: %d", check, i), zap.Any("ticketId", v.ID))
-		tickets.CheckInTicket(ctx, ticketTailorConfig, v.ID, check)
+		if _, err := client.CheckInTicket(ctx, v.ID, check); err != nil {
+			logger.Logger.Error("checking in ticket", zap.String("ticket_id", v.ID), zap.Error(err))
+		}
 	}
 	return nil
 }
 
-func GenerateTickets(ctx context.Context, cfg pkg.AppConfig) error {
+// GenerateTickets runs one batch sync and records it in sync_runs under trigger (e.g. cron or manual). Runs of an
+// event hold its sync lock; a run started while another is in progress returns without doing anything.
+func GenerateTickets(ctx context.Context, cfg pkg.AppConfig, trigger string) (err error) {
 	databaseCfg, err := db.FromAppConfig(cfg)
 	if err != nil {
 		return err
@@ -52,15 +58,75 @@ func GenerateTickets(ctx context.Context, cfg pkg.AppConfig) error {
 		}
 	}()
 
+	lock, err := db.AcquireSyncLock(ctx, conn, cfg.TicketTailorEventId)
+	if errors.Is(err, db.ErrSyncLocked) {
+		logger.Logger.Info("Another sync run is in progress, skipping", zap.String("event_id", cfg.TicketTailorEventId))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("acquiring sync lock: %w", err)
+	}
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			logger.Logger.Error("releasing sync lock", zap.Error(err))
+		}
+	}()
+
+	run, err := db.StartSyncRun(ctx, conn, cfg.TicketTailorEventId, trigger, time.Now())
+	if err != nil {
+		return fmt.Errorf("starting sync run: %w", err)
+	}
+	logger.Logger.Info("Started sync run", zap.String("sync_run_id", run.ID), zap.String("trigger", trigger))
+	var summary GenerationSummary
+	defer func() {
+		finishSyncRun(ctx, conn, run, summary, err)
+	}()
+
 	ticketGenerator, err := NewWalletTicketSyncer(ctx, cfg, conn)
 	if err != nil {
 		return err
 	}
 	logger.Logger.Info("Syncing tickets")
-	_, err = ticketGenerator.SyncTickets(ctx)
+	summary, err = ticketGenerator.SyncTickets(ctx)
 	if err != nil {
 		return err
 	}
-	logger.Logger.Info("Finished Apple Wallet Tickets")
+	for _, failure := range summary.Failures {
+		logger.Logger.Error(
+			"ticket failed",
+			zap.String("ticket_id", failure.TicketID),
+			zap.String("channel", string(failure.Channel)),
+			zap.String("stage", string(failure.Stage)),
+			zap.Error(failure.Err),
+		)
+	}
+
+	if OutboxEnabled(cfg) {
+		outbox, err := NewOutboxWorker(ctx, cfg, conn)
+		if err != nil {
+			return err
+		}
+		logger.Logger.Info("Sending queued emails")
+		summary.Sent, err = outbox.Drain(ctx)
+		if err != nil {
+			return fmt.Errorf("draining email outbox: %w", err)
+		}
+	}
+	if HasArtifactSink(cfg, S3ArtifactSink) {
+		resolver, err := NewDownloadResolver(ctx, cfg, conn)
+		if err != nil {
+			return err
+		}
+		logger.Logger.Info("Refreshing pass download urls")
+		refreshed, err := resolver.Refresh(ctx)
+		if err != nil {
+			return fmt.Errorf("refreshing pass download urls: %w", err)
+		}
+		logger.Logger.Info("Refreshed pass download urls", zap.Int("count", refreshed))
+	}
+	if len(summary.Failures) > 0 {
+		return fmt.Errorf("%d ticket passes failed: %w", len(summary.Failures), summary.Err())
+	}
+	logger.Logger.Info("Finished wallet tickets", zap.String("sync_run_id", run.ID))
 	return nil
 }
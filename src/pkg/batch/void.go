@@ -0,0 +1,166 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/mailer"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet/google"
+	"go.uber.org/zap"
+)
+
+// passRevoker invalidates an already delivered pass in place. Channels without one are revoked by regenerating
+// the pass from the voided ticket and re-uploading it.
+type passRevoker func(ctx context.Context, ticket tickets.TTIssuedTicket) error
+
+// newGoogleRevoker expires the Google Wallet object; a missing object means the holder never saved the pass.
+func newGoogleRevoker(googleConfig google.Config, client *google.Client) passRevoker {
+	return func(ctx context.Context, ticket tickets.TTIssuedTicket) error {
+		_, err := client.ExpireObject(ctx, googleConfig.ObjectID(ticket.ID))
+		if errors.Is(err, google.ErrNotFound) {
+			logger.Logger.Debug("google wallet object was never saved", zap.String("ticket_id", ticket.ID))
+			return nil
+		}
+		return err
+	}
+}
+
+// voidEmail renders the notice telling the holder of a voided ticket that their pass no longer works.
+func (g *walletTicketSyncer) voidEmail(ticket tickets.TTIssuedTicket) (db.OutboxMessage, error) {
+	data := mailer.TemplateDataFromTicket(ticket, g.AppConfig.EventName)
+	email, err := g.Templates.Render(mailer.KindPassVoided, g.emailLocale(ticket), data)
+	if err != nil {
+		return db.OutboxMessage{}, fmt.Errorf("rendering pass voided email: %w", err)
+	}
+	return db.OutboxMessage{
+		Kind:      mailer.KindPassVoided,
+		OrderID:   ticket.OrderID,
+		Recipient: ticket.Email,
+		Subject:   email.Subject,
+		HTMLBody:  email.HTML,
+		TextBody:  email.Text,
+	}, nil
+}
+
+// voidTickets revokes every produced pass that belongs to a voided ticket, across all enabled channels.
+// Failures are collected per ticket so one bad pass does not keep the rest valid. With void emails enabled, the
+// first pass voided for a ticket queues its notice in the outbox.
+func (g *walletTicketSyncer) voidTickets(
+	ctx context.Context,
+	voidedTickets []tickets.TTIssuedTicket,
+	failures *failureLog,
+) ([]GeneratedArtifact, error) {
+	if len(voidedTickets) == 0 {
+		return nil, nil
+	}
+
+	var voided []GeneratedArtifact
+	notified := make(map[string]bool)
+	for _, generator := range g.Generators {
+		produced, err := db.GetProducedPasses(ctx, g.DB, generator.Channel)
+		if err != nil {
+			return nil, fmt.Errorf("getting produced %s passes: %w", generator.Channel, err)
+		}
+
+		for _, ticket := range voidedTickets {
+			if record, ok := produced[ticket.ID]; !ok || record.Status == string(db.Voided) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			artifact, err := g.revokePass(ctx, generator, ticket)
+			if err != nil {
+				logger.Logger.Error(
+					"revoking pass",
+					zap.String("ticket_id", ticket.ID),
+					zap.String("channel", string(generator.Channel)),
+					zap.Error(err),
+				)
+				failures.add(TicketFailure{
+					TicketID: ticket.ID,
+					Channel:  generator.Channel,
+					Email:    ticket.Email,
+					Stage:    StageVoid,
+					Err:      err,
+				})
+				continue
+			}
+
+			changed, err := g.voidPass(ctx, generator.Channel, ticket, !notified[ticket.ID])
+			if err != nil {
+				logger.Logger.Error("marking pass voided", zap.String("ticket_id", ticket.ID), zap.Error(err))
+				failures.add(TicketFailure{
+					TicketID: ticket.ID,
+					Channel:  generator.Channel,
+					Email:    ticket.Email,
+					Stage:    StageRecord,
+					Err:      err,
+				})
+				continue
+			}
+			if !changed {
+				continue
+			}
+			notified[ticket.ID] = true
+			if artifact.Key != "" {
+				err := db.SetPassMetadata(ctx, g.DB, generator.Channel, ticket.ID, map[string]any{db.PassStorageKeyKey: artifact.Key})
+				if err != nil {
+					logger.Logger.Error("recording voided pass storage key", zap.String("ticket_id", ticket.ID), zap.Error(err))
+				}
+			}
+
+			logger.Logger.Info(
+				"Voided wallet pass",
+				zap.String("ticket_id", ticket.ID),
+				zap.String("channel", string(generator.Channel)),
+			)
+			voided = append(voided, artifact)
+		}
+	}
+
+	return voided, nil
+}
+
+// voidPass marks a pass voided. With notify, and void emails enabled, the holder's notice is queued in the same
+// transaction.
+func (g *walletTicketSyncer) voidPass(
+	ctx context.Context,
+	channel db.PassChannel,
+	ticket tickets.TTIssuedTicket,
+	notify bool,
+) (bool, error) {
+	if !notify || !g.AppConfig.VoidEmailEnabled || ticket.Email == "" {
+		return db.SetPassVoided(ctx, g.DB, channel, ticket.ID, g.Now())
+	}
+	message, err := g.voidEmail(ticket)
+	if err != nil {
+		return false, err
+	}
+	return db.SetPassVoidedAndEnqueue(ctx, g.DB, channel, ticket.ID, g.Now(), message)
+}
+
+func (g *walletTicketSyncer) revokePass(
+	ctx context.Context,
+	generator channelGenerator,
+	ticket tickets.TTIssuedTicket,
+) (GeneratedArtifact, error) {
+	if generator.Revoke != nil {
+		if err := generator.Revoke(ctx, ticket); err != nil {
+			return GeneratedArtifact{}, err
+		}
+		return GeneratedArtifact{
+			TicketID: ticket.ID,
+			Channel:  generator.Channel,
+			Platform: generator.Platform,
+			Email:    ticket.Email,
+		}, nil
+	}
+
+	return g.generateAndPersist(ctx, generator, ticket)
+}
@@ -2,14 +2,16 @@ package batch
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"slices"
 	"time"
 
 	"github.com/atunbetun/hakuna-wallet/pkg"
 	"github.com/atunbetun/hakuna-wallet/pkg/aws"
 	"github.com/atunbetun/hakuna-wallet/pkg/db"
 	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/mailer"
 	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
 	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -18,19 +20,25 @@ import (
 	"gorm.io/gorm"
 )
 
-type ticketFetcher func(ctx context.Context, cfg tickets.TicketTailorConfig) ([]tickets.TTIssuedTicket, error)
+// ticketFetcher lists tickets with the given status; a non-zero updatedSince restricts it to recently changed ones.
+type ticketFetcher func(
+	ctx context.Context,
+	status tickets.TicketStatus,
+	updatedSince int64,
+) ([]tickets.TTIssuedTicket, error)
 
-type passGenerator func(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error)
+// ticketLookup fetches a single issued ticket by ID.
+type ticketLookup func(ctx context.Context, ticketID string) (tickets.TTIssuedTicket, error)
 
-// returns file path and error
-type artifactSink func(ctx context.Context, artifact wallet.Artifact) (string, error)
+type passGenerator func(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error)
 
 type Platform string
 
 // TODO: this probably does nothing
 const defaultTicketStatus = "issued"
 const (
-	PlatformApple Platform = "apple"
+	PlatformApple  Platform = "apple"
+	PlatformGoogle Platform = "google"
 )
 
 // TicketGenerator produces wallet artifacts from Ticket Tailor issued tickets.
@@ -40,28 +48,69 @@ type TicketGenerator interface {
 
 // GenerationSummary provides metadata about the artifacts created during a run.
 type GenerationSummary struct {
+	// Fetched counts the valid and voided tickets the run looked at, including those retried.
+	Fetched int
+	// New counts the tickets that had no pass yet, once per channel.
+	New       int
 	Artifacts []GeneratedArtifact
+	// Voided lists the passes revoked because their ticket was voided.
+	Voided []GeneratedArtifact
+	// Failures lists the tickets that could not be processed; the rest of the run still completes.
+	Failures []TicketFailure
+	// Sent counts the emails the run's own outbox drain delivered.
+	Sent int
+}
+
+// Err joins the ticket failures of the run, or returns nil when every ticket went through.
+func (s GenerationSummary) Err() error {
+	errs := make([]error, len(s.Failures))
+	for i, failure := range s.Failures {
+		errs[i] = failure
+	}
+	return errors.Join(errs...)
 }
 
 // GeneratedArtifact captures the origin of a created wallet artifact.
 type GeneratedArtifact struct {
-	TicketID         string
-	Platform         Platform
-	FileName         string
-	Email            string
-	FullArtifactPath string
+	TicketID string
+	Channel  db.PassChannel
+	Platform Platform
+	FileName string
+	// Key is where every sink stored the artifact.
+	Key     string
+	Email   string
+	SaveURL string
+	// Fingerprint is the ticket content hash the artifact was rendered from.
+	Fingerprint string
+	// Reissue marks an artifact rendered again for a changed ticket whose pass was already produced.
+	Reissue bool
+	// Refresh marks an artifact whose provider-side pass is updated once it is recorded; its fingerprint is only
+	// saved after that update succeeded.
+	Refresh bool
+	// Ticket is the ticket the artifact was rendered from.
+	Ticket tickets.TTIssuedTicket
 }
 
 // walletTicketSyncer orchestrates fetching tickets, generating wallet passes, and persisting artifacts.
 type walletTicketSyncer struct {
-	ticketConfig   tickets.TicketTailorConfig `validate:"required"`
-	TicketFetcher  ticketFetcher              `validate:"required"`
-	AppleGenerator passGenerator              `validate:"required"`
-	ArtifactSink   artifactSink               `validate:"required"`
-	TicketStatus   string                     `validate:"required"`
-	AppConfig      pkg.AppConfig              `validate:"required"`
-	DB             *gorm.DB                   `validate:"-"`
-	S3Client       *aws.S3Client              `validate:"required"`
+	ticketConfig  tickets.TicketTailorConfig `validate:"required"`
+	TicketFetcher ticketFetcher              `validate:"required"`
+	TicketLookup  ticketLookup               `validate:"required"`
+	Generators    []channelGenerator         `validate:"required,min=1"`
+	ArtifactSink  ArtifactSink               `validate:"required"`
+	Keys          *KeyLayout                 `validate:"required"`
+	TicketStatus  string                     `validate:"required"`
+	AppConfig     pkg.AppConfig              `validate:"required"`
+	DB            *gorm.DB                   `validate:"-"`
+	Notifier      passNotifier               `validate:"-"`
+	Templates     *mailer.Templates          `validate:"required"`
+	// FullReconcileInterval is how often a full fetch replaces the incremental one; zero always fetches everything.
+	FullReconcileInterval time.Duration `validate:"-"`
+	// Concurrency bounds how many tickets are generated, stored and recorded at once.
+	Concurrency int `validate:"-"`
+	// RetrySchedule decides when a failed pass is tried again.
+	RetrySchedule db.RetrySchedule `validate:"required"`
+	Now           func() time.Time `validate:"required"`
 }
 
 var validate = validator.New(validator.WithRequiredStructEnabled())
@@ -73,35 +122,73 @@ func NewWalletTicketSyncer(ctx context.Context, cfg pkg.AppConfig, conn *gorm.DB
 		return nil, err
 	}
 
-	sink, err := newFileSink(cfg.TicketsDir)
+	ticketClient, err := tickets.NewClient(ticketCfg)
 	if err != nil {
 		return nil, err
 	}
 
-	appleGen, err := newAppleGenerator(cfg, EmbeddedAppleGenerator)
+	generators, err := newChannelGenerators(cfg, conn)
 	if err != nil {
 		return nil, err
 	}
 
-	awsConfig, err := config.LoadDefaultConfig(context.TODO())
+	notifier, err := newAppleNotifier(cfg, conn)
 	if err != nil {
-		return &walletTicketSyncer{}, err
+		return nil, err
 	}
 
-	s3, err := aws.NewS3Client(cfg.S3Bucket, &awsConfig)
+	templates, err := mailer.NewTemplates(cfg.EmailTemplatesDir)
 	if err != nil {
-		return &walletTicketSyncer{}, err
+		return nil, err
+	}
+
+	if cfg.VoidEmailEnabled && cfg.MailFrom == "" {
+		return nil, fmt.Errorf("MAIL_FROM is required to send void emails")
+	}
+	if cfg.PassEmailEnabled && !HasArtifactSink(cfg, S3ArtifactSink) {
+		return nil, fmt.Errorf("pass emails attach passes from s3, add the s3 artifact sink or disable PASS_EMAIL_ENABLED")
+	}
+
+	var s3 *aws.S3Client
+	if HasArtifactSink(cfg, S3ArtifactSink) {
+		awsConfig, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return &walletTicketSyncer{}, err
+		}
+
+		s3, err = aws.NewS3Client(cfg.S3Bucket, &awsConfig)
+		if err != nil {
+			return &walletTicketSyncer{}, err
+		}
+	}
+
+	sink, err := newArtifactSink(cfg, s3)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := NewKeyLayout(cfg.StorageKeyTemplate)
+	if err != nil {
+		return nil, err
 	}
 
 	out := &walletTicketSyncer{
-		ticketConfig:   ticketCfg,
-		TicketFetcher:  newTicketTailorTicketFetcher(), // TODO: remove this
-		AppleGenerator: appleGen,
-		ArtifactSink:   sink,
-		TicketStatus:   defaultTicketStatus,
-		DB:             conn,
-		AppConfig:      cfg,
-		S3Client:       s3,
+		ticketConfig:  ticketCfg,
+		TicketFetcher: newTicketTailorTicketFetcher(ticketClient),
+		TicketLookup:  ticketClient.FetchIssuedTicket,
+		Generators:    generators,
+		ArtifactSink:  sink,
+		Keys:          keys,
+		TicketStatus:  defaultTicketStatus,
+		DB:            conn,
+		AppConfig:     cfg,
+		Notifier:      notifier,
+		Templates:     templates,
+
+		FullReconcileInterval: cfg.SyncFullReconcileInterval,
+		Concurrency:           cfg.SyncConcurrency,
+		RetrySchedule:         newRetrySchedule(cfg, time.Now),
+		Now:                   time.Now,
 	}
 	err = validate.Struct(out)
 	if err != nil {
@@ -119,11 +206,19 @@ func (g *walletTicketSyncer) SyncTickets(ctx context.Context) (GenerationSummary
 		return GenerationSummary{}, fmt.Errorf("artifact sink is not configured")
 	}
 
+	startedAt := g.Now()
+	window, err := g.loadSyncWindow(ctx, startedAt)
+	if err != nil {
+		return GenerationSummary{}, fmt.Errorf("loading sync cursor: %w", err)
+	}
+
 	logger.Logger.Debug(
 		"Fetching issued tickets",
 		zap.String("event_id", g.ticketConfig.EventId),
+		zap.Bool("full", window.Full),
+		zap.Int64("updated_since", window.UpdatedSince),
 	)
-	ticketsBatch, err := g.TicketFetcher(ctx, g.ticketConfig)
+	ticketsBatch, err := g.TicketFetcher(ctx, tickets.Valid, window.UpdatedSince)
 	if err != nil {
 		return GenerationSummary{}, fmt.Errorf("fetching ticket tailor issued tickets: %w", err)
 	}
@@ -133,138 +228,429 @@ func (g *walletTicketSyncer) SyncTickets(ctx context.Context) (GenerationSummary
 		zap.Int("count", len(ticketsBatch)),
 	)
 
-	currentTickets, err := db.GetProducedPasses(ctx, g.DB, db.AppleWalletChannel)
+	voidedTickets, err := g.TicketFetcher(ctx, tickets.Void, window.UpdatedSince)
 	if err != nil {
-		return GenerationSummary{}, fmt.Errorf("getting produced passes: %w", err)
+		return GenerationSummary{}, fmt.Errorf("fetching ticket tailor voided tickets: %w", err)
 	}
 
-	tickets := ticketsForSync(ticketsBatch, currentTickets)
-	logger.Logger.Info(
-		"Generating tickets",
-		zap.Int("count", len(tickets)),
-	)
-	created, err := g.generateTickets(ctx, tickets)
+	retryTickets, retryVoided := g.dueRetries(ctx, startedAt, ticketsBatch, voidedTickets)
+	summary, err := g.syncTicketBatch(ctx, append(ticketsBatch, retryTickets...), append(voidedTickets, retryVoided...))
 	if err != nil {
-		return GenerationSummary{}, fmt.Errorf("generating tickets: %w", err)
+		return GenerationSummary{}, err
 	}
+	summary.Fetched = len(ticketsBatch) + len(voidedTickets) + len(retryTickets) + len(retryVoided)
 
-	// _ = mailer.NewAppleMailDialer(
-	// 	"smtp.mail.me.com",
-	// 	587,
-	// 	"adesaintmalo@icloud.com",
-	// 	g.AppConfig.ApplePassword,
-	// )
+	if err := g.advanceSyncCursor(ctx, window, startedAt, ticketsBatch, voidedTickets); err != nil {
+		return GenerationSummary{}, fmt.Errorf("saving sync cursor: %w", err)
+	}
 
-	// TODO: this should be from the created, not from the tickets
+	return summary, nil
+}
 
-	logger.Logger.Debug(
-		"Fetched tickets batch",
-		zap.String("event_id", g.ticketConfig.EventId),
-		zap.Int("count", len(ticketsBatch)),
-	)
-	var wg sync.WaitGroup
-	for _, v := range created {
-		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
-			err = g.processTicket(ctx, v)
-			if err != nil {
-				logger.Logger.Error("processing tickeet", zap.Error(err))
-			}
-		}(&wg)
+// SyncTicket runs a single ticket through the same generation path as SyncTickets. The ticket is looked up again
+// rather than trusted from the caller, so stale or forged payloads cannot produce a pass.
+func (g *walletTicketSyncer) SyncTicket(ctx context.Context, ticketID string) (GenerationSummary, error) {
+	if g.TicketLookup == nil {
+		return GenerationSummary{}, fmt.Errorf("ticket lookup is not configured")
+	}
+	if g.ArtifactSink == nil {
+		return GenerationSummary{}, fmt.Errorf("artifact sink is not configured")
+	}
+
+	ticket, err := g.TicketLookup(ctx, ticketID)
+	if err != nil {
+		return GenerationSummary{}, fmt.Errorf("fetching ticket tailor ticket %s: %w", ticketID, err)
+	}
+	if ticket.EventID != "" && ticket.EventID != g.ticketConfig.EventId {
+		logger.Logger.Debug(
+			"Skipping ticket from another event",
+			zap.String("ticket_id", ticket.ID),
+			zap.String("event_id", ticket.EventID),
+		)
+		return GenerationSummary{}, nil
 	}
-	wg.Wait()
-	// TODO: add error handling from the parallel piece
 
-	return GenerationSummary{Artifacts: created}, nil
+	if ticket.IsVoided() {
+		return g.syncTicketBatch(ctx, nil, []tickets.TTIssuedTicket{ticket})
+	}
+	if ticket.Status != string(tickets.Valid) {
+		return GenerationSummary{}, nil
+	}
+	return g.syncTicketBatch(ctx, []tickets.TTIssuedTicket{ticket}, nil)
 }
 
-func (g *walletTicketSyncer) processTicket(
+// syncTicketBatch generates passes for new or changed valid tickets on every channel, revokes voided ones and
+// notifies devices holding affected Apple passes. Tickets that fail are reported in the summary rather than
+// stopping the batch.
+func (g *walletTicketSyncer) syncTicketBatch(
 	ctx context.Context,
-	artifact GeneratedArtifact,
-) error {
-	logger.Logger.Debug(
-		"Marking ticket as ticket created",
-	)
-	err := db.SetPassProduced(
-		ctx,
-		g.DB,
-		db.AppleWalletChannel,
-		artifact.TicketID,
-		artifact.Email,
-		time.Now(),
-	)
+	ticketsBatch []tickets.TTIssuedTicket,
+	voidedTickets []tickets.TTIssuedTicket,
+) (GenerationSummary, error) {
+	failures := &failureLog{}
+	var created []GeneratedArtifact
+	missing := 0
+	for _, generator := range g.Generators {
+		currentTickets, err := db.GetProducedPasses(ctx, g.DB, generator.Channel)
+		if err != nil {
+			return GenerationSummary{}, fmt.Errorf("getting produced %s passes: %w", generator.Channel, err)
+		}
+
+		plan := ticketsForSync(ticketsBatch, currentTickets, g.Now())
+		g.backfillFingerprints(ctx, generator.Channel, plan.Unfingerprinted)
+		logger.Logger.Info(
+			"Generating tickets",
+			zap.String("channel", string(generator.Channel)),
+			zap.Int("count", len(plan.Missing)),
+			zap.Int("changed", len(plan.Changed)),
+			zap.Int("retried", len(plan.Retried)),
+			zap.Int("reinstated", len(plan.Reinstated)),
+		)
+		missing += len(plan.Missing)
+		pending := slices.Concat(plan.Missing, plan.Changed, plan.Retried, plan.Reinstated)
+		channelCreated, err := g.generateTickets(ctx, generator, pending, failures)
+		if err != nil {
+			return GenerationSummary{}, fmt.Errorf("generating %s tickets: %w", generator.Channel, err)
+		}
+		markReissued(channelCreated, plan.Changed)
+		if generator.Update != nil {
+			markRefreshed(channelCreated, plan.Refreshed)
+		}
+		created = append(created, channelCreated...)
+	}
+
+	voided, err := g.voidTickets(ctx, voidedTickets, failures)
 	if err != nil {
-		logger.Logger.Fatal("setting pass produced: %w", zap.Any("", err))
-		return err
+		return GenerationSummary{}, fmt.Errorf("voiding tickets: %w", err)
 	}
 
-	logger.Logger.Debug(
-		"Uploading to s3",
-		zap.String("event_id", g.ticketConfig.EventId),
-	)
-	_, err = g.S3Client.UploadFile(
-		ctx,
-		g.AppConfig.S3Bucket,
-		ticketKey(artifact.FileName),
-		artifact.FullArtifactPath,
-	)
+	recorded, err := g.recordOrders(ctx, created, failures)
 	if err != nil {
-		logger.Logger.Fatal("uploading file: %w", zap.Any("", err))
-		return err
+		return GenerationSummary{}, err
 	}
-	_, err = g.S3Client.PresignURLDefault(
-		ctx,
-		g.AppConfig.S3Bucket,
-		ticketKey(artifact.FileName),
-	)
+	recorded, err = g.refreshPasses(ctx, recorded, failures)
 	if err != nil {
-		logger.Logger.Fatal("presign url: %w", zap.Any("", err))
-		return err
+		return GenerationSummary{}, err
+	}
+
+	g.notifyUpdatedPasses(ctx, append(recorded, voided...))
+
+	failed := failures.list()
+	if err := g.recordFailures(ctx, failed); err != nil {
+		return GenerationSummary{}, fmt.Errorf("recording pass failures: %w", err)
+	}
+
+	return GenerationSummary{
+		Fetched:   len(ticketsBatch) + len(voidedTickets),
+		New:       missing,
+		Artifacts: recorded,
+		Voided:    voided,
+		Failures:  failed,
+	}, nil
+}
+
+// recordOrders marks the stored passes of every order produced on the worker pool and returns the artifacts that
+// were recorded. A failed order is reported for each of its tickets; an order another worker already produced is
+// skipped.
+func (g *walletTicketSyncer) recordOrders(
+	ctx context.Context,
+	created []GeneratedArtifact,
+	failures *failureLog,
+) ([]GeneratedArtifact, error) {
+	orders := groupByOrder(created)
+	recorded := make([]bool, len(orders))
+	forEach(ctx, g.Concurrency, orders, func(ctx context.Context, i int, order []GeneratedArtifact) {
+		err := g.processOrder(ctx, order)
+		if errors.Is(err, db.ErrPassClaimed) {
+			logger.Logger.Info("Order already produced by another worker", zap.String("order_id", order[0].Ticket.OrderID))
+			return
+		}
+		if err != nil {
+			logger.Logger.Error("processing order", zap.String("order_id", order[0].Ticket.OrderID), zap.Error(err))
+			for _, artifact := range order {
+				failures.add(TicketFailure{
+					TicketID: artifact.TicketID,
+					Channel:  artifact.Channel,
+					Email:    artifact.Email,
+					Stage:    StageRecord,
+					Err:      err,
+				})
+			}
+			return
+		}
+		recorded[i] = true
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var out []GeneratedArtifact
+	for i, order := range orders {
+		if recorded[i] {
+			out = append(out, order...)
+		}
+	}
+	return out, nil
+}
+
+// notifyUpdatedPasses pushes to devices holding any of the regenerated Apple passes.
+func (g *walletTicketSyncer) notifyUpdatedPasses(ctx context.Context, created []GeneratedArtifact) {
+	if g.Notifier == nil {
+		return
+	}
+
+	var serials []string
+	for _, artifact := range created {
+		if artifact.Platform == PlatformApple {
+			serials = append(serials, artifact.TicketID)
+		}
+	}
+	if len(serials) == 0 {
+		return
+	}
+
+	if err := g.Notifier(ctx, serials); err != nil {
+		logger.Logger.Error("notifying apple devices", zap.Error(err))
+	}
+}
+
+// processOrder records the already stored passes of an order as uploaded, with their storage key and, unless a
+// provider update is still due, their fingerprint, together with the one email delivering them. The email only ever
+// points at stored passes and the order is recorded completely or not at all.
+func (g *walletTicketSyncer) processOrder(
+	ctx context.Context,
+	order []GeneratedArtifact,
+) error {
+	logger.Logger.Debug(
+		"Marking order tickets as created",
+		zap.String("order_id", order[0].Ticket.OrderID),
+		zap.Int("count", len(order)),
+	)
+	producedAt := g.Now()
+	passes := make([]db.ProducedPass, len(order))
+	for i, artifact := range order {
+		passes[i] = db.ProducedPass{
+			Channel:        artifact.Channel,
+			TicketTailorID: artifact.TicketID,
+			Email:          artifact.Email,
+			Reissue:        artifact.Reissue,
+			Metadata:       map[string]any{db.PassStorageKeyKey: artifact.Key},
+		}
+		if !artifact.Refresh {
+			passes[i].Metadata[db.PassFingerprintKey] = artifact.Fingerprint
+		}
+	}
+	if g.AppConfig.PassEmailEnabled {
+		message, err := g.orderEmail(order)
+		if err != nil {
+			return fmt.Errorf("rendering order email: %w", err)
+		}
+		if err := db.SetPassesProducedAndEnqueue(ctx, g.DB, passes, producedAt, message); err != nil {
+			return fmt.Errorf("setting passes produced: %w", err)
+		}
+	} else {
+		if err := db.SetPassesProduced(ctx, g.DB, passes, producedAt); err != nil {
+			return fmt.Errorf("setting passes produced: %w", err)
+		}
 	}
 	return nil
 }
-func ticketKey(ticketName string) string {
-	key := "ham-2026/apple-wallet/" + ticketName
-	return key
+
+// syncPlan splits a ticket batch by what a channel has to do with each ticket.
+type syncPlan struct {
+	// Missing tickets have no produced pass yet.
+	Missing []tickets.TTIssuedTicket
+	// Changed tickets have a pass rendered from different ticket content and must be re-issued.
+	Changed []tickets.TTIssuedTicket
+	// Unfingerprinted tickets were produced before fingerprints were recorded; their current fingerprint is
+	// stored as-is rather than re-issuing every existing pass.
+	Unfingerprinted []tickets.TTIssuedTicket
+	// Retried tickets failed to be produced before and are due for another attempt.
+	Retried []tickets.TTIssuedTicket
+	// Reinstated tickets are valid again after their pass was voided; the pass is produced again and, on channels
+	// that update passes in place, reactivated.
+	Reinstated []tickets.TTIssuedTicket
+	// Refreshed tickets need their provider-side pass updated once recorded: changed and reinstated tickets, and
+	// retries of a failed update.
+	Refreshed []tickets.TTIssuedTicket
 }
 
+// ticketsForSync plans a channel's work for a batch. Passes that failed to be produced wait for their next retry
+// and are left alone once retries gave up; dead-lettered deliveries count as produced. A valid ticket whose pass
+// was voided has been reinstated in Ticket Tailor.
 func ticketsForSync(
 	ticketsBatch []tickets.TTIssuedTicket,
 	currentTickets map[string]db.PassRecord,
-) []tickets.TTIssuedTicket {
+	now time.Time,
+) syncPlan {
+
+	var plan syncPlan
+	for _, ticket := range ticketsBatch {
+		record, exists := currentTickets[ticket.ID]
+		switch {
+		case !exists:
+			plan.Missing = append(plan.Missing, ticket)
+		case record.Status == string(db.Voided):
+			plan.Reinstated = append(plan.Reinstated, ticket)
+			plan.Refreshed = append(plan.Refreshed, ticket)
+		case record.Status == string(db.Failed) && record.FailureStage != db.PassStageDeliver:
+			if record.Retryable(now) {
+				plan.Retried = append(plan.Retried, ticket)
+				if record.FailureStage == string(StageUpdate) {
+					plan.Refreshed = append(plan.Refreshed, ticket)
+				}
+			}
+		case record.Fingerprint == "":
+			plan.Unfingerprinted = append(plan.Unfingerprinted, ticket)
+		case record.Fingerprint != ticket.Fingerprint():
+			plan.Changed = append(plan.Changed, ticket)
+			plan.Refreshed = append(plan.Refreshed, ticket)
+		}
+	}
+	return plan
+}
 
-	var missing []tickets.TTIssuedTicket
+func (g *walletTicketSyncer) backfillFingerprints(
+	ctx context.Context,
+	channel db.PassChannel,
+	ticketsBatch []tickets.TTIssuedTicket,
+) {
 	for _, ticket := range ticketsBatch {
-		if _, exists := currentTickets[ticket.ID]; !exists {
-			missing = append(missing, ticket)
+		err := db.SetPassMetadata(ctx, g.DB, channel, ticket.ID, map[string]any{db.PassFingerprintKey: ticket.Fingerprint()})
+		if err != nil {
+			logger.Logger.Error("backfilling pass fingerprint", zap.String("ticket_id", ticket.ID), zap.Error(err))
 		}
 	}
-	return missing
 }
 
+// markReissued flags the artifacts rendered for changed tickets, whose passes are re-issued rather than produced.
+func markReissued(created []GeneratedArtifact, changed []tickets.TTIssuedTicket) {
+	reissued := make(map[string]bool, len(changed))
+	for _, ticket := range changed {
+		reissued[ticket.ID] = true
+	}
+	for i := range created {
+		created[i].Reissue = reissued[created[i].TicketID]
+	}
+}
+
+// markRefreshed flags the artifacts whose provider-side pass must be updated after they are recorded.
+func markRefreshed(created []GeneratedArtifact, refreshed []tickets.TTIssuedTicket) {
+	refresh := make(map[string]bool, len(refreshed))
+	for _, ticket := range refreshed {
+		refresh[ticket.ID] = true
+	}
+	for i := range created {
+		created[i].Refresh = refresh[created[i].TicketID]
+	}
+}
+
+// refreshPasses updates the provider-side pass of every recorded artifact flagged for it and only then saves its
+// fingerprint. A failed update is added to failures, so the pass is retried, and left out of the returned
+// artifacts.
+func (g *walletTicketSyncer) refreshPasses(
+	ctx context.Context,
+	recorded []GeneratedArtifact,
+	failures *failureLog,
+) ([]GeneratedArtifact, error) {
+	updaters := make(map[db.PassChannel]passUpdater, len(g.Generators))
+	for _, generator := range g.Generators {
+		updaters[generator.Channel] = generator.Update
+	}
+
+	refreshed := make([]bool, len(recorded))
+	forEach(ctx, g.Concurrency, recorded, func(ctx context.Context, i int, artifact GeneratedArtifact) {
+		update := updaters[artifact.Channel]
+		if !artifact.Refresh || update == nil {
+			refreshed[i] = true
+			return
+		}
+
+		stage := StageUpdate
+		err := update(ctx, artifact.Ticket)
+		if err == nil {
+			stage = StageRecord
+			err = db.SetPassMetadata(ctx, g.DB, artifact.Channel, artifact.TicketID, map[string]any{
+				db.PassFingerprintKey: artifact.Fingerprint,
+			})
+		}
+		if err != nil {
+			logger.Logger.Error(
+				"updating changed pass",
+				zap.String("ticket_id", artifact.TicketID),
+				zap.String("channel", string(artifact.Channel)),
+				zap.String("stage", string(stage)),
+				zap.Error(err),
+			)
+			failures.add(TicketFailure{
+				TicketID: artifact.TicketID,
+				Channel:  artifact.Channel,
+				Email:    artifact.Email,
+				Stage:    stage,
+				Err:      err,
+			})
+			return
+		}
+		refreshed[i] = true
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var out []GeneratedArtifact
+	for i, artifact := range recorded {
+		if refreshed[i] {
+			out = append(out, artifact)
+		}
+	}
+	return out, nil
+}
+
+// generateTickets renders and stores the tickets' passes on the worker pool, keeping batch order. Failed tickets
+// are logged and added to failures; only cancellation fails the whole batch.
 func (g *walletTicketSyncer) generateTickets(
 	ctx context.Context,
+	generator channelGenerator,
 	ticketsBatch []tickets.TTIssuedTicket,
+	failures *failureLog,
 ) (
 	[]GeneratedArtifact,
 	error,
 ) {
-	var created []GeneratedArtifact
-	for _, tt := range ticketsBatch {
+	results := make([]GeneratedArtifact, len(ticketsBatch))
+	generated := make([]bool, len(ticketsBatch))
+	forEach(ctx, g.Concurrency, ticketsBatch, func(ctx context.Context, i int, tt tickets.TTIssuedTicket) {
 		logger.Logger.Debug(
 			"Generating wallet passes for ticket",
 			zap.Any("ticket", tt),
 		)
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+		info, err := g.generateAndPersist(ctx, generator, tt)
+		if err != nil {
+			logger.Logger.Error(
+				"generating wallet pass",
+				zap.String("ticket_id", tt.ID),
+				zap.String("channel", string(generator.Channel)),
+				zap.Error(err),
+			)
+			failures.add(TicketFailure{
+				TicketID: tt.ID,
+				Channel:  generator.Channel,
+				Email:    tt.Email,
+				Stage:    stageOf(err, StageGenerate),
+				Err:      err,
+			})
+			return
 		}
+		results[i] = info
+		generated[i] = true
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 
-		if g.AppleGenerator != nil {
-			info, err := g.generateAndPersist(ctx, g.AppleGenerator, tt, PlatformApple)
-			if err != nil {
-				return nil, err
-			}
+	var created []GeneratedArtifact
+	for i, info := range results {
+		if generated[i] {
 			created = append(created, info)
 		}
 	}
@@ -273,23 +659,27 @@ func (g *walletTicketSyncer) generateTickets(
 
 func (g *walletTicketSyncer) generateAndPersist(
 	ctx context.Context,
-	generator passGenerator,
+	generator channelGenerator,
 	ticket tickets.TTIssuedTicket,
-	platform Platform,
 ) (GeneratedArtifact, error) {
+	platform := generator.Platform
 	logger.Logger.Debug(
 		"Starting wallet artifact generation",
 		zap.String("ticket_id", ticket.ID),
 		zap.String("platform", string(platform)),
 	)
-	artifact, err := generator(ctx, ticket)
+	artifact, err := generator.Generate(ctx, ticket)
 	if err != nil {
-		return GeneratedArtifact{}, fmt.Errorf("generating %s wallet pass for ticket %s: %w", platform, ticket.ID, err)
+		return GeneratedArtifact{}, atStage(StageGenerate, fmt.Errorf("generating %s wallet pass for ticket %s: %w", platform, ticket.ID, err))
 	}
 
-	fullPath, err := g.ArtifactSink(ctx, artifact)
+	key, err := g.Keys.Key(storageKeyData(g.ticketConfig.EventId, generator.Channel, ticket, artifact))
 	if err != nil {
-		return GeneratedArtifact{}, fmt.Errorf("persisting %s wallet artifact for ticket %s: %w", platform, ticket.ID, err)
+		return GeneratedArtifact{}, atStage(StageStore, fmt.Errorf("storage key for %s wallet artifact of ticket %s: %w", platform, ticket.ID, err))
+	}
+
+	if err := g.ArtifactSink.Put(ctx, key, artifact); err != nil {
+		return GeneratedArtifact{}, atStage(StageStore, fmt.Errorf("persisting %s wallet artifact for ticket %s: %w", platform, ticket.ID, err))
 	}
 
 	logger.Logger.Debug(
@@ -299,17 +689,24 @@ func (g *walletTicketSyncer) generateAndPersist(
 		zap.String("file_name", artifact.FileName),
 	)
 	return GeneratedArtifact{
-		TicketID:         ticket.ID,
-		Platform:         platform,
-		FileName:         artifact.FileName,
-		Email:            ticket.Email,
-		FullArtifactPath: fullPath,
+		TicketID:    ticket.ID,
+		Channel:     generator.Channel,
+		Platform:    platform,
+		FileName:    artifact.FileName,
+		Key:         key,
+		Email:       ticket.Email,
+		SaveURL:     artifact.SaveURL,
+		Fingerprint: ticket.Fingerprint(),
+		Ticket:      ticket,
 	}, nil
 }
 
-// TODO: remove this
-func newTicketTailorTicketFetcher() ticketFetcher {
-	return func(ctx context.Context, cfg tickets.TicketTailorConfig) ([]tickets.TTIssuedTicket, error) {
-		return tickets.FetchAllIssuedTickets(ctx, cfg, tickets.Valid)
+func newTicketTailorTicketFetcher(client *tickets.Client) ticketFetcher {
+	return func(
+		ctx context.Context,
+		status tickets.TicketStatus,
+		updatedSince int64,
+	) ([]tickets.TTIssuedTicket, error) {
+		return client.FetchAllIssuedTicketsUpdatedSince(ctx, status, updatedSince)
 	}
 }
@@ -0,0 +1,171 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// failingUpdater records the tickets it was asked to update and fails every update.
+func failingUpdater(updated *[]string) passUpdater {
+	return func(_ context.Context, ticket tickets.TTIssuedTicket) error {
+		*updated = append(*updated, ticket.ID)
+		return errors.New("google wallet unavailable")
+	}
+}
+
+func TestRefreshPassesFailsTicketOnUpdateError(t *testing.T) {
+	var updated []string
+	syncer := &walletTicketSyncer{
+		Concurrency: 1,
+		Generators: []channelGenerator{
+			{Channel: db.AppleWalletChannel, Platform: PlatformApple},
+			{Channel: db.GoogleWalletChannel, Platform: PlatformGoogle, Update: failingUpdater(&updated)},
+		},
+	}
+	changed := tickets.TTIssuedTicket{ID: "it_changed", Email: "buyer@example.com"}
+	recorded := []GeneratedArtifact{
+		{TicketID: "it_new", Channel: db.GoogleWalletChannel, Platform: PlatformGoogle},
+		{TicketID: "it_changed", Channel: db.AppleWalletChannel, Platform: PlatformApple, Refresh: true, Ticket: changed},
+		{TicketID: "it_changed", Channel: db.GoogleWalletChannel, Platform: PlatformGoogle, Email: changed.Email, Refresh: true, Ticket: changed},
+	}
+
+	failures := &failureLog{}
+	out, err := syncer.refreshPasses(context.Background(), recorded, failures)
+	if err != nil {
+		t.Fatalf("refreshPasses: %v", err)
+	}
+
+	if len(updated) != 1 || updated[0] != "it_changed" {
+		t.Fatalf("expected only the flagged google pass to be updated, got %v", updated)
+	}
+	if len(out) != 2 || out[0].TicketID != "it_new" || out[1].Channel != db.AppleWalletChannel {
+		t.Fatalf("expected the failed update to be left out, got %+v", out)
+	}
+	failed := failures.list()
+	if len(failed) != 1 {
+		t.Fatalf("expected one failure, got %+v", failed)
+	}
+	if failed[0].TicketID != "it_changed" || failed[0].Channel != db.GoogleWalletChannel || failed[0].Stage != StageUpdate {
+		t.Fatalf("expected an update failure for the google pass, got %+v", failed[0])
+	}
+}
+
+func TestReinstatedPassFailsOnUpdateError(t *testing.T) {
+	reinstated := tickets.TTIssuedTicket{ID: "it_reinstated", Email: "buyer@example.com", Status: string(tickets.Valid)}
+	plan := ticketsForSync(
+		[]tickets.TTIssuedTicket{reinstated},
+		map[string]db.PassRecord{"it_reinstated": {TicketTailorID: "it_reinstated", Status: string(db.Voided), Fingerprint: reinstated.Fingerprint()}},
+		time.Now(),
+	)
+	if len(plan.Reinstated) != 1 || len(plan.Refreshed) != 1 {
+		t.Fatalf("expected the voided pass to be reinstated and reactivated, got %+v", plan)
+	}
+
+	var updated []string
+	syncer := &walletTicketSyncer{
+		Concurrency: 1,
+		Generators:  []channelGenerator{{Channel: db.GoogleWalletChannel, Platform: PlatformGoogle, Update: failingUpdater(&updated)}},
+	}
+	created := []GeneratedArtifact{{TicketID: reinstated.ID, Channel: db.GoogleWalletChannel, Platform: PlatformGoogle, Email: reinstated.Email, Ticket: reinstated}}
+	markRefreshed(created, plan.Refreshed)
+
+	failures := &failureLog{}
+	out, err := syncer.refreshPasses(context.Background(), created, failures)
+	if err != nil {
+		t.Fatalf("refreshPasses: %v", err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("a reinstated pass whose object stays inactive must not be reported as produced, got %+v", out)
+	}
+	failed := failures.list()
+	if len(failed) != 1 || failed[0].TicketID != reinstated.ID || failed[0].Stage != StageUpdate {
+		t.Fatalf("expected the reinstatement to fail the ticket at the update stage, got %+v", failed)
+	}
+}
+
+func TestTicketsForSync(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	ticket := tickets.TTIssuedTicket{ID: "it_1", FullName: "Nala", Barcode: "123"}
+	current := ticket.Fingerprint()
+	stale := tickets.TTIssuedTicket{ID: "it_1", FullName: "Simba", Barcode: "123"}.Fingerprint()
+
+	cases := []struct {
+		name   string
+		record *db.PassRecord
+		want   []string
+	}{
+		{name: "missing", want: []string{"missing"}},
+		{name: "produced and unchanged", record: &db.PassRecord{Status: string(db.Sent), Fingerprint: current}},
+		{name: "unfingerprinted", record: &db.PassRecord{Status: string(db.Sent)}, want: []string{"unfingerprinted"}},
+		{name: "changed", record: &db.PassRecord{Status: string(db.Uploaded), Fingerprint: stale}, want: []string{"changed", "refreshed"}},
+		{name: "voided is reinstated", record: &db.PassRecord{Status: string(db.Voided), Fingerprint: current}, want: []string{"reinstated", "refreshed"}},
+		{
+			name:   "failed and due is retried",
+			record: &db.PassRecord{Status: string(db.Failed), FailureStage: string(StageStore), NextRetryAt: &past},
+			want:   []string{"retried"},
+		},
+		{
+			name:   "failed update is retried and refreshed",
+			record: &db.PassRecord{Status: string(db.Failed), FailureStage: string(StageUpdate), Fingerprint: stale, NextRetryAt: &past},
+			want:   []string{"retried", "refreshed"},
+		},
+		{name: "failed and not yet due", record: &db.PassRecord{Status: string(db.Failed), FailureStage: string(StageGenerate), NextRetryAt: &future}},
+		{name: "failed and given up", record: &db.PassRecord{Status: string(db.Failed), FailureStage: string(StageGenerate)}},
+		{
+			name:   "dead-lettered delivery counts as produced",
+			record: &db.PassRecord{Status: string(db.Failed), FailureStage: db.PassStageDeliver, Fingerprint: current, NextRetryAt: &past},
+		},
+		{
+			name:   "dead-lettered delivery of a changed ticket is re-issued",
+			record: &db.PassRecord{Status: string(db.Failed), FailureStage: db.PassStageDeliver, Fingerprint: stale},
+			want:   []string{"changed", "refreshed"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			records := map[string]db.PassRecord{}
+			if tc.record != nil {
+				record := *tc.record
+				record.TicketTailorID = ticket.ID
+				records[ticket.ID] = record
+			}
+
+			plan := ticketsForSync([]tickets.TTIssuedTicket{ticket}, records, now)
+			var got []string
+			for name, list := range map[string][]tickets.TTIssuedTicket{
+				"missing":         plan.Missing,
+				"changed":         plan.Changed,
+				"unfingerprinted": plan.Unfingerprinted,
+				"retried":         plan.Retried,
+				"reinstated":      plan.Reinstated,
+				"refreshed":       plan.Refreshed,
+			} {
+				if len(list) == 1 && list[0].ID == ticket.ID {
+					got = append(got, name)
+				} else if len(list) != 0 {
+					t.Fatalf("unexpected %s tickets %+v", name, list)
+				}
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tc.want))
+			if !slices.Equal(got, want) {
+				t.Fatalf("expected the ticket in %v, got %v", want, got)
+			}
+		})
+	}
+}
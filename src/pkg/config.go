@@ -12,6 +12,8 @@ type AppConfig struct {
 	TicketTailorAPIKey  string `env:"TICKETTAILOR_API_KEY,required"`
 	TicketTailorEventId string `env:"TT_EVENT_ID,required"`
 	TicketTailorBaseUrl string `env:"TT_BASE_URL,required"`
+	// TicketTailorWebhookSecret enables the webhook receiver and verifies its signatures.
+	TicketTailorWebhookSecret string `env:"TICKETTAILOR_WEBHOOK_SECRET"`
 
 	// Apple Pass
 	AppleP12Path     string `env:"APPLE_P12_PATH"`
@@ -24,9 +26,90 @@ type AppConfig struct {
 	ApplePassTypeID string `env:"APPLE_PASS_TYPE_IDENTIFIER,required"`
 	AppleTeamID     string `env:"APPLE_TEAM_IDENTIFIER,required"`
 
-	ApplePassword string `env:"APPLE_PASSWORD,required"`
+	// Mail transport: smtp, ses or file.
+	MailTransport string `env:"MAIL_TRANSPORT" envDefault:"smtp"`
+	MailFrom      string `env:"MAIL_FROM"`
+	SMTPHost      string `env:"SMTP_HOST"`
+	SMTPPort      int    `env:"SMTP_PORT" envDefault:"587"`
+	// SMTPUsername defaults to MAIL_FROM.
+	SMTPUsername string `env:"SMTP_USERNAME,expand" envDefault:"${MAIL_FROM}"`
+	// SMTPPassword falls back to the APPLE_PASSWORD used by earlier iCloud-only deployments.
+	SMTPPassword string `env:"SMTP_PASSWORD,expand" envDefault:"${APPLE_PASSWORD}"`
+	// SMTPTLS is starttls or tls (implicit TLS, usually port 465).
+	SMTPTLS string `env:"SMTP_TLS" envDefault:"starttls"`
+	// SESEndpoint overrides the SES API endpoint, e.g. for a local stand-in.
+	SESEndpoint string `env:"SES_ENDPOINT"`
+	// MailDir receives .eml files when MAIL_TRANSPORT=file.
+	MailDir string `env:"MAIL_DIR" envDefault:"mail"`
 
-	TicketsDir string `env:"TICKETS_DIR" envDefault:"tickets"`
+	// PassEmailEnabled emails each newly produced pass to the ticket holder.
+	PassEmailEnabled bool `env:"PASS_EMAIL_ENABLED" envDefault:"true"`
+	// EmailLocale selects the email templates (en, es); regional variants such as es-MX fall back to their language.
+	EmailLocale string `env:"EMAIL_LOCALE" envDefault:"es"`
+	// EmailLocaleQuestion is the Ticket Tailor checkout question whose answer picks each order's email language;
+	// EmailLocale applies when it is unset or unanswered.
+	EmailLocaleQuestion string `env:"EMAIL_LOCALE_QUESTION"`
+	// EmailTemplatesDir optionally overrides the embedded email templates file by file.
+	EmailTemplatesDir string `env:"EMAIL_TEMPLATES_DIR"`
+	// EventName is shown in ticket emails.
+	EventName string `env:"EVENT_NAME"`
+	// EmailOutboxMaxAttempts is how many sends a queued email gets before it is dead-lettered.
+	EmailOutboxMaxAttempts int `env:"EMAIL_OUTBOX_MAX_ATTEMPTS" envDefault:"8"`
+	// EmailOutboxPollInterval is how often the server looks for due emails.
+	EmailOutboxPollInterval time.Duration `env:"EMAIL_OUTBOX_POLL_INTERVAL" envDefault:"10s"`
+
+	// VoidEmailEnabled emails holders when their ticket is voided and the pass revoked.
+	VoidEmailEnabled bool `env:"VOID_EMAIL_ENABLED" envDefault:"false"`
+
+	// AppleWebServiceURL is embedded in passes so Wallet can register for updates (e.g. https://host/apple).
+	AppleWebServiceURL string `env:"APPLE_WEB_SERVICE_URL"`
+	// Optional APNs auth key (.p8); without it pushes authenticate with the pass signing certificate.
+	AppleAPNsKeyID   string `env:"APPLE_APNS_KEY_ID"`
+	AppleAPNsKeyPath string `env:"APPLE_APNS_KEY_PATH"`
+
+	// Google Wallet
+	GoogleIssuerID           string   `env:"GOOGLE_ISSUER_ID"`
+	GoogleClassSuffix        string   `env:"GOOGLE_CLASS_SUFFIX"`
+	GoogleIssuerName         string   `env:"GOOGLE_ISSUER_NAME" envDefault:"Hakuna Wallet"`
+	GoogleEventName          string   `env:"GOOGLE_EVENT_NAME"`
+	GoogleOrigins            []string `env:"GOOGLE_ORIGINS"`
+	GoogleServiceAccountJSON string   `env:"GOOGLE_SERVICE_ACCOUNT_JSON"`
+
+	// WalletChannels lists the enabled pass channels (apple_wallet, google_wallet).
+	WalletChannels []string `env:"WALLET_CHANNELS" envDefault:"apple_wallet"`
+
+	// SyncConcurrency bounds how many tickets are generated, stored and recorded at once.
+	SyncConcurrency int `env:"SYNC_CONCURRENCY" envDefault:"4"`
+	// SyncFullReconcileInterval bounds how long incremental syncs run before all tickets are fetched again.
+	SyncFullReconcileInterval time.Duration `env:"SYNC_FULL_RECONCILE_INTERVAL" envDefault:"1h"`
+	// PassRetryMaxAttempts is how many times a pass that failed to be produced is tried before it is left failed.
+	PassRetryMaxAttempts int `env:"PASS_RETRY_MAX_ATTEMPTS" envDefault:"5"`
+	// PassRetryBaseDelay is the wait after the first failure; it doubles per attempt up to PassRetryMaxDelay.
+	PassRetryBaseDelay time.Duration `env:"PASS_RETRY_BASE_DELAY" envDefault:"10m"`
+	PassRetryMaxDelay  time.Duration `env:"PASS_RETRY_MAX_DELAY" envDefault:"6h"`
+
+	// ArtifactSinks lists where generated passes are stored (file, s3); passes are emailed from s3.
+	ArtifactSinks []string `env:"ARTIFACT_SINKS" envDefault:"file,s3"`
+	TicketsDir    string   `env:"TICKETS_DIR" envDefault:"tickets"`
+	// DownloadURLTTL is how long presigned pass download URLs stay valid; S3 allows at most 7 days.
+	DownloadURLTTL time.Duration `env:"DOWNLOAD_URL_TTL" envDefault:"168h"`
+	// DownloadURLRefreshBefore is how close to expiry a download URL is presigned again.
+	DownloadURLRefreshBefore time.Duration `env:"DOWNLOAD_URL_REFRESH_BEFORE" envDefault:"48h"`
+	// DownloadURLRefreshInterval is how often the server looks for expiring download URLs.
+	DownloadURLRefreshInterval time.Duration `env:"DOWNLOAD_URL_REFRESH_INTERVAL" envDefault:"1h"`
+	// PassLinkSecret signs the stable /passes/{token} links sent in emails; links are off without it.
+	PassLinkSecret string `env:"PASS_LINK_SECRET"`
+	// PublicBaseURL is where the server is reachable from email clients, e.g. https://hakuna-wallet.fly.dev.
+	PublicBaseURL string `env:"PUBLIC_BASE_URL"`
+	// PassDownloadMode is redirect (to a presigned S3 URL) or stream (Apple passes served by the server).
+	PassDownloadMode string `env:"PASS_DOWNLOAD_MODE" envDefault:"redirect"`
+	// StorageKeyTemplate lays out artifact keys in every sink, see batch.StorageKeyData for its fields.
+	StorageKeyTemplate string `env:"STORAGE_KEY_TEMPLATE" envDefault:"ham-2026/{{.Channel}}/{{.FileName}}"`
+
+	// HTTP server
+	Port string `env:"PORT" envDefault:"8080"`
+	// WebhookQueueSize bounds how many webhook tickets may wait for sync before deliveries are refused.
+	WebhookQueueSize int `env:"WEBHOOK_QUEUE_SIZE" envDefault:"256"`
 
 	// Database (raw inputs)
 	DatabaseURL                  string        `env:"DATABASE_URL,required"`
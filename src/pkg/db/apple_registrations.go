@@ -0,0 +1,370 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPassNotFound is returned when no pass exists for the requested serial number.
+var ErrPassNotFound = errors.New("pass not found")
+
+const applePassAuthTokenBytes = 32
+
+// GetOrCreateApplePassAuthToken returns the authentication token for a pass, minting one on first use.
+func GetOrCreateApplePassAuthToken(
+	ctx context.Context,
+	conn *gorm.DB,
+	passTypeIdentifier string,
+	serialNumber string,
+) (string, error) {
+	if conn == nil {
+		return "", fmt.Errorf("database connection is required")
+	}
+	if passTypeIdentifier == "" {
+		return "", fmt.Errorf("passTypeIdentifier is required")
+	}
+	if serialNumber == "" {
+		return "", fmt.Errorf("serialNumber is required")
+	}
+
+	raw := make([]byte, applePassAuthTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generating auth token: %w", err)
+	}
+
+	candidate := ApplePassAuthToken{
+		PassTypeIdentifier: passTypeIdentifier,
+		SerialNumber:       serialNumber,
+		Token:              hex.EncodeToString(raw),
+	}
+	err := conn.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "pass_type_identifier"}, {Name: "serial_number"}},
+			DoNothing: true,
+		}).
+		Create(&candidate).Error
+	if err != nil {
+		return "", fmt.Errorf("creating auth token: %w", err)
+	}
+
+	var stored ApplePassAuthToken
+	err = conn.WithContext(ctx).
+		Where("pass_type_identifier = ? AND serial_number = ?", passTypeIdentifier, serialNumber).
+		First(&stored).Error
+	if err != nil {
+		return "", fmt.Errorf("fetching auth token: %w", err)
+	}
+	return stored.Token, nil
+}
+
+// ApplePassAuthTokenMatches reports whether token is the one issued for the pass.
+func ApplePassAuthTokenMatches(
+	ctx context.Context,
+	conn *gorm.DB,
+	passTypeIdentifier string,
+	serialNumber string,
+	token string,
+) (bool, error) {
+	if conn == nil {
+		return false, fmt.Errorf("database connection is required")
+	}
+	if token == "" {
+		return false, nil
+	}
+
+	var stored ApplePassAuthToken
+	err := conn.WithContext(ctx).
+		Where("pass_type_identifier = ? AND serial_number = ?", passTypeIdentifier, serialNumber).
+		First(&stored).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("fetching auth token: %w", err)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored.Token), []byte(token)) == 1, nil
+}
+
+// RegisterAppleDevice records that a device wants updates for a pass. It reports whether the registration is new.
+func RegisterAppleDevice(
+	ctx context.Context,
+	conn *gorm.DB,
+	deviceLibraryIdentifier string,
+	pushToken string,
+	passTypeIdentifier string,
+	serialNumber string,
+) (bool, error) {
+	if conn == nil {
+		return false, fmt.Errorf("database connection is required")
+	}
+	if deviceLibraryIdentifier == "" {
+		return false, fmt.Errorf("deviceLibraryIdentifier is required")
+	}
+	if pushToken == "" {
+		return false, fmt.Errorf("pushToken is required")
+	}
+
+	created := false
+	err := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var registration AppleDeviceRegistration
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(
+				"device_library_identifier = ? AND pass_type_identifier = ? AND serial_number = ?",
+				deviceLibraryIdentifier, passTypeIdentifier, serialNumber,
+			).
+			First(&registration).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			registration = AppleDeviceRegistration{
+				DeviceLibraryIdentifier: deviceLibraryIdentifier,
+				PushToken:               pushToken,
+				PassTypeIdentifier:      passTypeIdentifier,
+				SerialNumber:            serialNumber,
+			}
+			if err := tx.Create(&registration).Error; err != nil {
+				return fmt.Errorf("creating device registration: %w", err)
+			}
+			created = true
+		case err != nil:
+			return fmt.Errorf("fetching device registration: %w", err)
+		default:
+			if registration.PushToken != pushToken || registration.UnregisteredAt != nil {
+				registration.PushToken = pushToken
+				registration.UnregisteredAt = nil
+				registration.PushFailureCount = 0
+				if err := tx.Save(&registration).Error; err != nil {
+					return fmt.Errorf("updating device registration: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// UnregisterAppleDevice removes a device registration for a pass. Missing registrations are ignored.
+func UnregisterAppleDevice(
+	ctx context.Context,
+	conn *gorm.DB,
+	deviceLibraryIdentifier string,
+	passTypeIdentifier string,
+	serialNumber string,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+
+	err := conn.WithContext(ctx).
+		Where(
+			"device_library_identifier = ? AND pass_type_identifier = ? AND serial_number = ?",
+			deviceLibraryIdentifier, passTypeIdentifier, serialNumber,
+		).
+		Delete(&AppleDeviceRegistration{}).Error
+	if err != nil {
+		return fmt.Errorf("deleting device registration: %w", err)
+	}
+	return nil
+}
+
+// GetUpdatedAppleSerialNumbers lists the serial numbers registered by a device whose Apple pass changed after since.
+// A nil since returns every registered pass. The latest update time among the results is returned alongside.
+func GetUpdatedAppleSerialNumbers(
+	ctx context.Context,
+	conn *gorm.DB,
+	deviceLibraryIdentifier string,
+	passTypeIdentifier string,
+	since *time.Time,
+) ([]string, time.Time, error) {
+	if conn == nil {
+		return nil, time.Time{}, fmt.Errorf("database connection is required")
+	}
+
+	type updatedRow struct {
+		SerialNumber string    `gorm:"column:serial_number"`
+		UpdatedAt    time.Time `gorm:"column:updated_at"`
+	}
+
+	query := conn.WithContext(ctx).
+		Table("apple_device_registrations").
+		Select("apple_device_registrations.serial_number", "ticket_passes.updated_at").
+		Joins("JOIN tickets ON tickets.ticket_tailor_id = apple_device_registrations.serial_number").
+		Joins("JOIN ticket_passes ON ticket_passes.ticket_id = tickets.id").
+		Where("ticket_passes.channel = ?", AppleWalletChannel).
+		Where("apple_device_registrations.device_library_identifier = ?", deviceLibraryIdentifier).
+		Where("apple_device_registrations.pass_type_identifier = ?", passTypeIdentifier)
+	if since != nil {
+		query = query.Where("ticket_passes.updated_at > ?", *since)
+	}
+
+	var rows []updatedRow
+	if err := query.Order("apple_device_registrations.serial_number").Find(&rows).Error; err != nil {
+		return nil, time.Time{}, fmt.Errorf("listing updated serial numbers: %w", err)
+	}
+
+	serials := make([]string, 0, len(rows))
+	var lastUpdated time.Time
+	for _, r := range rows {
+		serials = append(serials, r.SerialNumber)
+		if r.UpdatedAt.After(lastUpdated) {
+			lastUpdated = r.UpdatedAt
+		}
+	}
+	return serials, lastUpdated, nil
+}
+
+// ApplePushOutcome is the APNs result for a single device registration.
+type ApplePushOutcome struct {
+	Status       int
+	Error        string
+	Unregistered bool
+	PushedAt     time.Time
+}
+
+// GetApplePushTargets returns the live device registrations for the given serial numbers.
+func GetApplePushTargets(
+	ctx context.Context,
+	conn *gorm.DB,
+	serialNumbers []string,
+) ([]AppleDeviceRegistration, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if len(serialNumbers) == 0 {
+		return nil, nil
+	}
+
+	var registrations []AppleDeviceRegistration
+	err := conn.WithContext(ctx).
+		Where("serial_number IN ?", serialNumbers).
+		Where("unregistered_at IS NULL").
+		Order("pass_type_identifier, serial_number").
+		Find(&registrations).Error
+	if err != nil {
+		return nil, fmt.Errorf("listing push targets: %w", err)
+	}
+	return registrations, nil
+}
+
+// RecordApplePushOutcome stores the APNs result on a registration, retiring tokens APNs reports as unregistered.
+func RecordApplePushOutcome(
+	ctx context.Context,
+	conn *gorm.DB,
+	registrationID string,
+	outcome ApplePushOutcome,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if registrationID == "" {
+		return fmt.Errorf("registrationID is required")
+	}
+	if outcome.PushedAt.IsZero() {
+		return fmt.Errorf("pushedAt must be set")
+	}
+
+	updates := map[string]any{
+		"last_pushed_at":   outcome.PushedAt,
+		"last_push_status": outcome.Status,
+		"updated_at":       outcome.PushedAt,
+	}
+	if outcome.Error == "" {
+		updates["last_push_error"] = nil
+		updates["push_failure_count"] = 0
+	} else {
+		updates["last_push_error"] = outcome.Error
+		updates["push_failure_count"] = gorm.Expr("push_failure_count + 1")
+	}
+	if outcome.Unregistered {
+		updates["unregistered_at"] = outcome.PushedAt
+	}
+
+	err := conn.WithContext(ctx).
+		Model(&AppleDeviceRegistration{}).
+		Where("id = ?", registrationID).
+		Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("recording push outcome: %w", err)
+	}
+	return nil
+}
+
+// GetApplePassUpdatedAt returns when the Apple pass for a Ticket Tailor ID last changed. Only passes issued under
+// passTypeIdentifier, i.e. holding an auth token for it, are found.
+func GetApplePassUpdatedAt(
+	ctx context.Context,
+	conn *gorm.DB,
+	passTypeIdentifier string,
+	serialNumber string,
+) (time.Time, error) {
+	if conn == nil {
+		return time.Time{}, fmt.Errorf("database connection is required")
+	}
+
+	var pass TicketPass
+	err := conn.WithContext(ctx).
+		Table("ticket_passes").
+		Select("ticket_passes.*").
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
+		Joins(
+			"JOIN apple_pass_auth_tokens ON apple_pass_auth_tokens.serial_number = tickets.ticket_tailor_id AND apple_pass_auth_tokens.pass_type_identifier = ?",
+			passTypeIdentifier,
+		).
+		Where("tickets.ticket_tailor_id = ? AND ticket_passes.channel = ?", serialNumber, AppleWalletChannel).
+		First(&pass).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return time.Time{}, ErrPassNotFound
+	case err != nil:
+		return time.Time{}, fmt.Errorf("fetching apple pass: %w", err)
+	}
+	return pass.UpdatedAt, nil
+}
+
+// ApplePassRegistry exposes the Apple web service repository functions over a single connection.
+type ApplePassRegistry struct {
+	DB *gorm.DB
+}
+
+// NewApplePassRegistry binds the registry to a database connection.
+func NewApplePassRegistry(conn *gorm.DB) ApplePassRegistry {
+	return ApplePassRegistry{DB: conn}
+}
+
+func (r ApplePassRegistry) AuthTokenMatches(ctx context.Context, passTypeIdentifier, serialNumber, token string) (bool, error) {
+	return ApplePassAuthTokenMatches(ctx, r.DB, passTypeIdentifier, serialNumber, token)
+}
+
+func (r ApplePassRegistry) RegisterDevice(ctx context.Context, deviceLibraryIdentifier, pushToken, passTypeIdentifier, serialNumber string) (bool, error) {
+	return RegisterAppleDevice(ctx, r.DB, deviceLibraryIdentifier, pushToken, passTypeIdentifier, serialNumber)
+}
+
+func (r ApplePassRegistry) UnregisterDevice(ctx context.Context, deviceLibraryIdentifier, passTypeIdentifier, serialNumber string) error {
+	return UnregisterAppleDevice(ctx, r.DB, deviceLibraryIdentifier, passTypeIdentifier, serialNumber)
+}
+
+func (r ApplePassRegistry) UpdatedSerialNumbers(ctx context.Context, deviceLibraryIdentifier, passTypeIdentifier string, since *time.Time) ([]string, time.Time, error) {
+	return GetUpdatedAppleSerialNumbers(ctx, r.DB, deviceLibraryIdentifier, passTypeIdentifier, since)
+}
+
+func (r ApplePassRegistry) PassUpdatedAt(ctx context.Context, passTypeIdentifier, serialNumber string) (time.Time, bool, error) {
+	updatedAt, err := GetApplePassUpdatedAt(ctx, r.DB, passTypeIdentifier, serialNumber)
+	if errors.Is(err, ErrPassNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return updatedAt, true, nil
+}
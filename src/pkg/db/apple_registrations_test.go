@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppleWebServiceRepositories(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	const passType = "pass.com.hakuna.integration"
+
+	token, err := GetOrCreateApplePassAuthToken(ctx, conn, passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.Len(t, token, 2*applePassAuthTokenBytes)
+
+	again, err := GetOrCreateApplePassAuthToken(ctx, conn, passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.Equal(t, token, again, "token must be stable across calls")
+
+	ok, err := ApplePassAuthTokenMatches(ctx, conn, passType, "tt_ticket_1", token)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = ApplePassAuthTokenMatches(ctx, conn, passType, "tt_ticket_1", "nope")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_ticket_1", "buyer@example.com", producedAt))
+
+	created, err := RegisterAppleDevice(ctx, conn, "device-1", "push-1", passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.True(t, created)
+
+	created, err = RegisterAppleDevice(ctx, conn, "device-1", "push-2", passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.False(t, created)
+
+	serials, lastUpdated, err := GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tt_ticket_1"}, serials)
+	require.False(t, lastUpdated.IsZero())
+
+	serials, _, err = GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, &lastUpdated)
+	require.NoError(t, err)
+	require.Empty(t, serials)
+
+	updatedAt, err := GetApplePassUpdatedAt(ctx, conn, passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.True(t, updatedAt.Equal(lastUpdated))
+
+	_, err = GetApplePassUpdatedAt(ctx, conn, passType, "tt_missing")
+	require.ErrorIs(t, err, ErrPassNotFound)
+
+	_, err = GetApplePassUpdatedAt(ctx, conn, "pass.com.other", "tt_ticket_1")
+	require.ErrorIs(t, err, ErrPassNotFound, "passes issued under another pass type are not served")
+
+	// A Google pass for the same ticket must not answer for the Apple pass.
+	require.NoError(t, SetPassProduced(ctx, conn, GoogleWalletChannel, "tt_ticket_1", "buyer@example.com", producedAt.Add(time.Hour)))
+	serials, _, err = GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, &lastUpdated)
+	require.NoError(t, err)
+	require.Empty(t, serials, "a google pass update is not an apple pass update")
+	updatedAt, err = GetApplePassUpdatedAt(ctx, conn, passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.True(t, updatedAt.Equal(lastUpdated))
+
+	_, err = GetOrCreateApplePassAuthToken(ctx, conn, passType, "tt_google_only")
+	require.NoError(t, err)
+	require.NoError(t, SetPassProduced(ctx, conn, GoogleWalletChannel, "tt_google_only", "buyer@example.com", producedAt))
+	_, err = RegisterAppleDevice(ctx, conn, "device-1", "push-1", passType, "tt_google_only")
+	require.NoError(t, err)
+	serials, _, err = GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tt_ticket_1"}, serials, "tickets with only a google pass are not listed")
+	_, err = GetApplePassUpdatedAt(ctx, conn, passType, "tt_google_only")
+	require.ErrorIs(t, err, ErrPassNotFound)
+	require.NoError(t, UnregisterAppleDevice(ctx, conn, "device-1", passType, "tt_google_only"))
+
+	targets, err := GetApplePushTargets(ctx, conn, []string{"tt_ticket_1"})
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	require.Equal(t, "push-2", targets[0].PushToken)
+
+	pushedAt := time.Date(2025, 10, 21, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, RecordApplePushOutcome(ctx, conn, targets[0].ID, ApplePushOutcome{
+		Status:       410,
+		Error:        "apns rejected push: 410 Unregistered",
+		Unregistered: true,
+		PushedAt:     pushedAt,
+	}))
+
+	targets, err = GetApplePushTargets(ctx, conn, []string{"tt_ticket_1"})
+	require.NoError(t, err)
+	require.Empty(t, targets, "unregistered tokens must not be pushed again")
+
+	created, err = RegisterAppleDevice(ctx, conn, "device-1", "push-3", passType, "tt_ticket_1")
+	require.NoError(t, err)
+	require.False(t, created)
+	targets, err = GetApplePushTargets(ctx, conn, []string{"tt_ticket_1"})
+	require.NoError(t, err)
+	require.Len(t, targets, 1, "re-registering revives the device")
+	require.Zero(t, targets[0].PushFailureCount)
+
+	require.NoError(t, UnregisterAppleDevice(ctx, conn, "device-1", passType, "tt_ticket_1"))
+	serials, _, err = GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, nil)
+	require.NoError(t, err)
+	require.Empty(t, serials)
+}
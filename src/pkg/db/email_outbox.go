@@ -0,0 +1,374 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOutboxEmailNotFound is returned when an email is missing or no longer pending.
+var ErrOutboxEmailNotFound = errors.New("outbox email not found")
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	// OutboxDead emails exhausted their attempts and are left for manual follow-up.
+	OutboxDead OutboxStatus = "dead"
+	// OutboxSuperseded emails were replaced by a newer email for the same pass before they were sent.
+	OutboxSuperseded OutboxStatus = "superseded"
+)
+
+// OutboxMessage is a rendered email to queue. Kind records what the email announces.
+type OutboxMessage struct {
+	Kind        string
+	OrderID     string
+	Recipient   string
+	Subject     string
+	HTMLBody    string
+	TextBody    string
+	Attachments []OutboxAttachment
+}
+
+// ProducedPass identifies a pass to mark produced. Reissue renders a pass that was already produced again.
+// Metadata is merged into the pass metadata in the same transaction, see SetPassMetadata.
+type ProducedPass struct {
+	Channel        PassChannel
+	TicketTailorID string
+	Email          string
+	Reissue        bool
+	Metadata       map[string]any
+}
+
+// SetPassesProducedAndEnqueue records stored passes like SetPassesProduced and queues one email delivering all of
+// them in a single transaction, so a pass is never uploaded without an email or emailed without being uploaded.
+// Pending emails that only covered passes in this set are superseded; emails that also deliver other passes are
+// kept.
+func SetPassesProducedAndEnqueue(
+	ctx context.Context,
+	conn *gorm.DB,
+	passes []ProducedPass,
+	producedAt time.Time,
+	message OutboxMessage,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if err := validateProducedPasses(passes, producedAt); err != nil {
+		return err
+	}
+	if err := validateOutboxMessage(message); err != nil {
+		return err
+	}
+
+	return conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		passIDs, err := uploadPasses(tx, passes, producedAt)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&OutboxEmail{}).
+			Where("status = ?", OutboxPending).
+			Where("id IN (SELECT email_id FROM email_outbox_passes WHERE ticket_pass_id IN ?)", passIDs).
+			Where("NOT EXISTS (SELECT 1 FROM email_outbox_passes p WHERE p.email_id = email_outbox.id AND p.ticket_pass_id NOT IN ?)", passIDs).
+			Update("status", string(OutboxSuperseded)).Error
+		if err != nil {
+			return fmt.Errorf("superseding pending emails: %w", err)
+		}
+
+		outbox, err := enqueueEmail(tx, message, producedAt)
+		if err != nil {
+			return err
+		}
+
+		links := make([]OutboxEmailPass, len(passIDs))
+		for i, passID := range passIDs {
+			links[i] = OutboxEmailPass{EmailID: outbox.ID, TicketPassID: passID}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return fmt.Errorf("linking email passes: %w", err)
+		}
+		return nil
+	})
+}
+
+func validateOutboxMessage(message OutboxMessage) error {
+	if message.Kind == "" {
+		return fmt.Errorf("message kind is required")
+	}
+	if message.Recipient == "" {
+		return fmt.Errorf("message recipient is required")
+	}
+	return nil
+}
+
+// enqueueEmail queues message inside tx, due at dueAt.
+func enqueueEmail(tx *gorm.DB, message OutboxMessage, dueAt time.Time) (OutboxEmail, error) {
+	outbox := OutboxEmail{
+		Kind:          message.Kind,
+		Recipient:     message.Recipient,
+		Subject:       message.Subject,
+		TextBody:      message.TextBody,
+		Attachments:   datatypes.NewJSONSlice(message.Attachments),
+		Status:        string(OutboxPending),
+		NextAttemptAt: dueAt,
+	}
+	if outbox.Attachments == nil {
+		outbox.Attachments = datatypes.JSONSlice[OutboxAttachment]{}
+	}
+	if message.OrderID != "" {
+		outbox.OrderID = &message.OrderID
+	}
+	if message.HTMLBody != "" {
+		outbox.HTMLBody = &message.HTMLBody
+	}
+	if err := tx.Create(&outbox).Error; err != nil {
+		return OutboxEmail{}, fmt.Errorf("enqueueing email: %w", err)
+	}
+	return outbox, nil
+}
+
+// ClaimOutboxEmails locks up to limit due emails with FOR UPDATE SKIP LOCKED, counts the attempt and leases them
+// until now+lease, so concurrent workers never claim the same email and a crashed worker's claims expire.
+func ClaimOutboxEmails(
+	ctx context.Context,
+	conn *gorm.DB,
+	now time.Time,
+	limit int,
+	lease time.Duration,
+) ([]OutboxEmail, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	if lease <= 0 {
+		return nil, fmt.Errorf("lease must be positive")
+	}
+
+	var claimed []OutboxEmail
+	err := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", OutboxPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&claimed).Error
+		if err != nil {
+			return fmt.Errorf("selecting due emails: %w", err)
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(claimed))
+		for i := range claimed {
+			ids[i] = claimed[i].ID
+			claimed[i].Attempts++
+			claimed[i].NextAttemptAt = now.Add(lease)
+		}
+		err = tx.Model(&OutboxEmail{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": now.Add(lease),
+			}).Error
+		if err != nil {
+			return fmt.Errorf("leasing due emails: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// MarkOutboxEmailSent records the delivery and moves every pass the email delivers to sent.
+func MarkOutboxEmailSent(
+	ctx context.Context,
+	conn *gorm.DB,
+	id string,
+	sentAt time.Time,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if sentAt.IsZero() {
+		return fmt.Errorf("sentAt must be set")
+	}
+
+	return conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := updateOutboxEmail(tx, id, map[string]any{
+			"status":     string(OutboxSent),
+			"sent_at":    sentAt,
+			"last_error": nil,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = transitionPasses(tx, emailPasses(id), SendPass, sentAt, map[string]any{
+			"delivered_at":  sentAt,
+			"error_message": nil,
+		}, "")
+		if err != nil {
+			return fmt.Errorf("marking ticket pass delivered: %w", err)
+		}
+		return nil
+	})
+}
+
+// MarkOutboxEmailRetry records a failed attempt and schedules the next one.
+func MarkOutboxEmailRetry(
+	ctx context.Context,
+	conn *gorm.DB,
+	id string,
+	message string,
+	nextAttemptAt time.Time,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if nextAttemptAt.IsZero() {
+		return fmt.Errorf("nextAttemptAt must be set")
+	}
+
+	return updateOutboxEmail(conn.WithContext(ctx), id, map[string]any{
+		"last_error":      message,
+		"next_attempt_at": nextAttemptAt,
+	})
+}
+
+// MarkOutboxEmailDead dead-letters an email after its last attempt and marks every pass it delivers failed.
+func MarkOutboxEmailDead(
+	ctx context.Context,
+	conn *gorm.DB,
+	id string,
+	message string,
+	failedAt time.Time,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if failedAt.IsZero() {
+		return fmt.Errorf("failedAt must be set")
+	}
+
+	return conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := updateOutboxEmail(tx, id, map[string]any{
+			"status":     string(OutboxDead),
+			"last_error": message,
+		})
+		if err != nil {
+			return err
+		}
+
+		undelivered := func(q *gorm.DB) *gorm.DB {
+			return emailPasses(id)(q).Where("status = ?", Uploaded)
+		}
+		err = transitionPasses(tx, undelivered, FailPass, failedAt, map[string]any{
+			"error_message": message,
+			"failure_stage": PassStageDeliver,
+		}, message)
+		if err != nil {
+			return fmt.Errorf("marking ticket pass failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// emailPasses scopes a ticket_passes query to the passes an email delivers.
+func emailPasses(emailID string) func(*gorm.DB) *gorm.DB {
+	return func(q *gorm.DB) *gorm.DB {
+		return q.Where("id IN (SELECT ticket_pass_id FROM email_outbox_passes WHERE email_id = ?)", emailID)
+	}
+}
+
+// updateOutboxEmail applies values to a pending email; emails that were superseded meanwhile are reported as
+// not found.
+func updateOutboxEmail(tx *gorm.DB, id string, values map[string]any) error {
+	result := tx.Model(&OutboxEmail{}).
+		Where("id = ? AND status = ?", id, OutboxPending).
+		Updates(values)
+	if result.Error != nil {
+		return fmt.Errorf("updating outbox email: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrOutboxEmailNotFound
+	}
+	return nil
+}
+
+// EmailOutboxStore exposes the outbox functions over a single connection.
+type EmailOutboxStore struct {
+	DB *gorm.DB
+}
+
+// NewEmailOutboxStore binds the store to a database connection.
+func NewEmailOutboxStore(conn *gorm.DB) EmailOutboxStore {
+	return EmailOutboxStore{DB: conn}
+}
+
+func (s EmailOutboxStore) ClaimOutboxEmails(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	return ClaimOutboxEmails(ctx, s.DB, now, limit, lease)
+}
+
+func (s EmailOutboxStore) MarkOutboxEmailSent(ctx context.Context, id string, sentAt time.Time) error {
+	return MarkOutboxEmailSent(ctx, s.DB, id, sentAt)
+}
+
+func (s EmailOutboxStore) MarkOutboxEmailRetry(ctx context.Context, id string, message string, nextAttemptAt time.Time) error {
+	return MarkOutboxEmailRetry(ctx, s.DB, id, message, nextAttemptAt)
+}
+
+func (s EmailOutboxStore) MarkOutboxEmailDead(ctx context.Context, id string, message string, failedAt time.Time) error {
+	return MarkOutboxEmailDead(ctx, s.DB, id, message, failedAt)
+}
+
+// RenameOutboxAttachmentKey points attachments of pending emails stored under from at to, after the object was
+// moved. It returns how many emails changed.
+func RenameOutboxAttachmentKey(ctx context.Context, conn *gorm.DB, from string, to string) (int64, error) {
+	if conn == nil {
+		return 0, fmt.Errorf("database connection is required")
+	}
+	if from == "" || to == "" {
+		return 0, fmt.Errorf("from and to keys are required")
+	}
+
+	result := conn.WithContext(ctx).Exec(`
+UPDATE email_outbox
+SET attachments = (
+    SELECT jsonb_agg(
+        CASE WHEN attachment->>'key' = @from THEN jsonb_set(attachment, '{key}', to_jsonb(@to::text)) ELSE attachment END
+        ORDER BY position
+    )
+    FROM jsonb_array_elements(attachments) WITH ORDINALITY AS attached(attachment, position)
+), updated_at = now()
+WHERE status = @status
+  AND attachments @> jsonb_build_array(jsonb_build_object('key', @from::text))`,
+		sql.Named("from", from),
+		sql.Named("to", to),
+		sql.Named("status", string(OutboxPending)),
+	)
+	if result.Error != nil {
+		return 0, fmt.Errorf("renaming outbox attachment key: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
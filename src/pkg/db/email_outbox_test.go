@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailOutbox(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	order := []ProducedPass{
+		{
+			Channel:        AppleWalletChannel,
+			TicketTailorID: "tt_sent_1",
+			Email:          "buyer@example.com",
+			Metadata:       map[string]any{PassStorageKeyKey: "ham-2026/apple-wallet/1.pkpass"},
+		},
+		{
+			Channel:        AppleWalletChannel,
+			TicketTailorID: "tt_sent_2",
+			Email:          "buyer@example.com",
+			Metadata:       map[string]any{PassStorageKeyKey: "ham-2026/apple-wallet/2.pkpass"},
+		},
+		{Channel: GoogleWalletChannel, TicketTailorID: "tt_sent_1", Email: "buyer@example.com"},
+	}
+	message := OutboxMessage{
+		Kind:      "order_passes",
+		OrderID:   "or_1",
+		Recipient: "buyer@example.com",
+		Subject:   "Your tickets",
+		TextBody:  "Hi",
+		Attachments: []OutboxAttachment{
+			{Key: "ham-2026/apple-wallet/1.pkpass", Name: "Nala-tt_sent_1.pkpass"},
+			{Key: "ham-2026/apple-wallet/2.pkpass", Name: "Simba-tt_sent_2.pkpass"},
+		},
+	}
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, order, producedAt, message))
+
+	bounced := []ProducedPass{{Channel: AppleWalletChannel, TicketTailorID: "tt_bounced", Email: "typo@example"}}
+	message.OrderID, message.Recipient, message.Attachments = "or_2", "typo@example", nil
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, bounced, producedAt, message))
+
+	claimed, err := ClaimOutboxEmails(ctx, conn, producedAt, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2, "one email per order")
+	keys, err := GetPassStorageKeys(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	for _, email := range claimed {
+		for i, attachment := range email.Attachments {
+			require.Equal(t, keys["tt_sent_"+strconv.Itoa(i+1)], attachment.Key, "attachments point at the recorded storage keys")
+		}
+	}
+	require.Equal(t, 1, claimed[0].Attempts)
+
+	again, err := ClaimOutboxEmails(ctx, conn, producedAt, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, again, "leased emails are not claimed twice")
+
+	var sent, dead OutboxEmail
+	for _, email := range claimed {
+		if email.Recipient == "buyer@example.com" {
+			sent = email
+		} else {
+			dead = email
+		}
+	}
+	require.Len(t, sent.Attachments, 2)
+	require.Equal(t, "Simba-tt_sent_2.pkpass", sent.Attachments[1].Name)
+
+	deliveredAt := producedAt.Add(time.Second)
+	require.NoError(t, MarkOutboxEmailSent(ctx, conn, sent.ID, deliveredAt))
+	require.ErrorIs(t, MarkOutboxEmailSent(ctx, conn, sent.ID, deliveredAt), ErrOutboxEmailNotFound, "sent emails are final")
+
+	require.NoError(t, MarkOutboxEmailRetry(ctx, conn, dead.ID, "421 try later", producedAt.Add(2*time.Minute)))
+	claimed, err = ClaimOutboxEmails(ctx, conn, producedAt.Add(2*time.Minute), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, 2, claimed[0].Attempts)
+	require.NoError(t, MarkOutboxEmailDead(ctx, conn, dead.ID, "550 mailbox unavailable", producedAt.Add(2*time.Minute)))
+
+	appleRecords, err := GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	for _, id := range []string{"tt_sent_1", "tt_sent_2"} {
+		require.Equal(t, string(Sent), appleRecords[id].Status, "every pass of the order is delivered")
+		require.NotNil(t, appleRecords[id].DeliveredAt)
+		require.True(t, appleRecords[id].DeliveredAt.Equal(deliveredAt))
+	}
+	require.Equal(t, string(Failed), appleRecords["tt_bounced"].Status, "dead-lettered passes stay visible and are not re-produced")
+	require.Equal(t, "550 mailbox unavailable", *appleRecords["tt_bounced"].ErrorMessage)
+	events, err := GetPassEvents(ctx, conn, AppleWalletChannel, "tt_bounced")
+	require.NoError(t, err)
+	require.True(t, events[len(events)-1].CreatedAt.Equal(producedAt.Add(2*time.Minute)), "the failure is recorded at the worker's time")
+
+	googleRecords, err := GetProducedPasses(ctx, conn, GoogleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, string(Sent), googleRecords["tt_sent_1"].Status)
+}
+
+func TestSetPassesProducedAndEnqueueMetadata(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	message := OutboxMessage{Kind: "order_passes", Recipient: "buyer@example.com", Subject: "Your tickets", TextBody: "Hi"}
+	pass := ProducedPass{
+		Channel:        AppleWalletChannel,
+		TicketTailorID: "tt_keyed",
+		Email:          "buyer@example.com",
+		Metadata:       map[string]any{PassFingerprintKey: "fp-1", PassStorageKeyKey: "ham-2026/apple-wallet/tt_keyed.pkpass"},
+	}
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{pass}, producedAt, message))
+
+	records, err := GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, "fp-1", records["tt_keyed"].Fingerprint)
+
+	broken := ProducedPass{
+		Channel:        AppleWalletChannel,
+		TicketTailorID: "tt_broken",
+		Email:          "buyer@example.com",
+		Metadata:       map[string]any{PassFingerprintKey: func() {}},
+	}
+	err = SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{broken}, producedAt, message)
+	require.Error(t, err)
+
+	records, err = GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.NotContains(t, records, "tt_broken", "a failed metadata write rolls the pass back")
+	claimed, err := ClaimOutboxEmails(ctx, conn, producedAt, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "and its email is not queued")
+}
+
+func TestEmailOutboxSupersedesPendingEmail(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	message := OutboxMessage{Kind: "order_passes", Recipient: "buyer@example.com", Subject: "Your tickets", TextBody: "Hi"}
+	first := ProducedPass{Channel: AppleWalletChannel, TicketTailorID: "tt_1", Email: "buyer@example.com"}
+	second := ProducedPass{Channel: AppleWalletChannel, TicketTailorID: "tt_2", Email: "buyer@example.com"}
+	reissued := first
+	reissued.Reissue = true
+
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{first}, producedAt, message))
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{reissued}, producedAt.Add(time.Minute), message))
+
+	claimed, err := ClaimOutboxEmails(ctx, conn, producedAt.Add(time.Hour), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "only the latest version of a pass is emailed")
+
+	second.Reissue = true
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{reissued, second}, producedAt.Add(2*time.Hour), message))
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{second}, producedAt.Add(3*time.Hour), message))
+
+	claimed, err = ClaimOutboxEmails(ctx, conn, producedAt.Add(4*time.Hour), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2, "an order email still delivering other passes is kept")
+}
+
+func TestRenameOutboxAttachmentKey(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	passes := []ProducedPass{{Channel: AppleWalletChannel, TicketTailorID: "tt_moved", Email: "buyer@example.com"}}
+	message := OutboxMessage{
+		Kind:      "order_passes",
+		OrderID:   "or_1",
+		Recipient: "buyer@example.com",
+		Subject:   "Your tickets",
+		TextBody:  "Hi",
+		Attachments: []OutboxAttachment{
+			{Key: "ham-2026/apple-wallet/tt_moved.pkpass", Name: "Nala-tt_moved.pkpass"},
+			{Key: "ham-2026/apple-wallet/other.pkpass", Name: "Simba-other.pkpass"},
+		},
+	}
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, passes, producedAt, message))
+	require.NoError(t, SetPassMetadata(ctx, conn, AppleWalletChannel, "tt_moved", map[string]any{PassStorageKeyKey: "ham-2026/apple-wallet/tt_moved.pkpass"}))
+
+	keys, err := GetPassStorageKeys(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"tt_moved": "ham-2026/apple-wallet/tt_moved.pkpass"}, keys)
+
+	renamed, err := RenameOutboxAttachmentKey(ctx, conn, "ham-2026/apple-wallet/tt_moved.pkpass", "ev_1/apple-wallet/tt_moved.pkpass")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, renamed)
+
+	claimed, err := ClaimOutboxEmails(ctx, conn, producedAt, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, "ev_1/apple-wallet/tt_moved.pkpass", claimed[0].Attachments[0].Key)
+	require.Equal(t, "Nala-tt_moved.pkpass", claimed[0].Attachments[0].Name)
+	require.Equal(t, "ham-2026/apple-wallet/other.pkpass", claimed[0].Attachments[1].Key, "other attachments keep their key")
+}
+
+func TestSetPassVoidedAndEnqueue(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_refunded", "buyer@example.com", producedAt))
+
+	notice := OutboxMessage{Kind: "pass_voided", Recipient: "buyer@example.com", Subject: "Ticket cancelled", TextBody: "Cancelled"}
+	voidedAt := producedAt.Add(time.Hour)
+	changed, err := SetPassVoidedAndEnqueue(ctx, conn, AppleWalletChannel, "tt_refunded", voidedAt, notice)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	changed, err = SetPassVoidedAndEnqueue(ctx, conn, AppleWalletChannel, "tt_refunded", voidedAt, notice)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	claimed, err := ClaimOutboxEmails(ctx, conn, voidedAt, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "the notice is queued once, with the void")
+	require.Equal(t, "pass_voided", claimed[0].Kind)
+	require.Empty(t, claimed[0].Attachments)
+}
@@ -29,6 +29,10 @@ type TicketPass struct {
 	ProducedAt   *time.Time        `gorm:"column:produced_at;type:timestamptz"`
 	DeliveredAt  *time.Time        `gorm:"column:delivered_at;type:timestamptz"`
 	ErrorMessage *string           `gorm:"column:error_message;type:text"`
+	VoidedAt     *time.Time        `gorm:"column:voided_at;type:timestamptz"`
+	FailureStage *string           `gorm:"column:failure_stage;type:text"`
+	Attempts     int               `gorm:"column:attempts;type:integer;not null;default:0"`
+	NextRetryAt  *time.Time        `gorm:"column:next_retry_at;type:timestamptz"`
 	Metadata     datatypes.JSONMap `gorm:"column:metadata;type:jsonb;not null;default:'{}'::jsonb"`
 	CreatedAt    time.Time         `gorm:"column:created_at;type:timestamptz;not null;autoCreateTime"`
 	UpdatedAt    time.Time         `gorm:"column:updated_at;type:timestamptz;not null;autoUpdateTime"`
@@ -38,3 +42,157 @@ type TicketPass struct {
 func (TicketPass) TableName() string {
 	return "ticket_passes"
 }
+
+// ApplePassAuthToken stores the per-pass secret embedded as authenticationToken in Apple passes.
+type ApplePassAuthToken struct {
+	ID                 string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	PassTypeIdentifier string    `gorm:"column:pass_type_identifier;type:text;not null"`
+	SerialNumber       string    `gorm:"column:serial_number;type:text;not null"`
+	Token              string    `gorm:"column:token;type:text;not null"`
+	CreatedAt          time.Time `gorm:"column:created_at;type:timestamptz;not null;autoCreateTime"`
+}
+
+// TableName overrides the default table name.
+func (ApplePassAuthToken) TableName() string {
+	return "apple_pass_auth_tokens"
+}
+
+// AppleDeviceRegistration links a device push token to a pass it wants updates for.
+type AppleDeviceRegistration struct {
+	ID                      string     `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	DeviceLibraryIdentifier string     `gorm:"column:device_library_identifier;type:text;not null"`
+	PushToken               string     `gorm:"column:push_token;type:text;not null"`
+	PassTypeIdentifier      string     `gorm:"column:pass_type_identifier;type:text;not null"`
+	SerialNumber            string     `gorm:"column:serial_number;type:text;not null"`
+	LastPushedAt            *time.Time `gorm:"column:last_pushed_at;type:timestamptz"`
+	LastPushStatus          *int       `gorm:"column:last_push_status;type:integer"`
+	LastPushError           *string    `gorm:"column:last_push_error;type:text"`
+	PushFailureCount        int        `gorm:"column:push_failure_count;type:integer;not null;default:0"`
+	UnregisteredAt          *time.Time `gorm:"column:unregistered_at;type:timestamptz"`
+	CreatedAt               time.Time  `gorm:"column:created_at;type:timestamptz;not null;autoCreateTime"`
+	UpdatedAt               time.Time  `gorm:"column:updated_at;type:timestamptz;not null;autoUpdateTime"`
+}
+
+// TableName overrides the default table name.
+func (AppleDeviceRegistration) TableName() string {
+	return "apple_device_registrations"
+}
+
+// SyncCursor is the per-event watermark for incremental Ticket Tailor syncs.
+type SyncCursor struct {
+	EventID string `gorm:"column:event_id;type:text;primaryKey"`
+	// LastUpdatedAt is the highest Ticket Tailor updated_at (unix seconds) seen by a completed sync.
+	LastUpdatedAt  int64      `gorm:"column:last_updated_at;type:bigint;not null;default:0"`
+	LastTicketID   string     `gorm:"column:last_ticket_id;type:text;not null;default:''"`
+	LastFullSyncAt *time.Time `gorm:"column:last_full_sync_at;type:timestamptz"`
+	CreatedAt      time.Time  `gorm:"column:created_at;type:timestamptz;not null;autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at;type:timestamptz;not null;autoUpdateTime"`
+}
+
+// TableName overrides the default table name.
+func (SyncCursor) TableName() string {
+	return "sync_cursors"
+}
+
+// WebhookEvent records a processed Ticket Tailor webhook delivery so retries are not handled twice.
+type WebhookEvent struct {
+	ID             string    `gorm:"column:id;type:text;primaryKey"`
+	EventType      string    `gorm:"column:event_type;type:text;not null"`
+	TicketTailorID *string   `gorm:"column:ticket_tailor_id;type:text"`
+	ReceivedAt     time.Time `gorm:"column:received_at;type:timestamptz;not null;autoCreateTime"`
+}
+
+// TableName overrides the default table name.
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
+
+// OutboxEmail is a queued email, written in the same transaction as the pass state it announces. One email
+// covers every pass of an order, linked through email_outbox_passes.
+type OutboxEmail struct {
+	ID            string                                `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	OrderID       *string                               `gorm:"column:order_id;type:text"`
+	Kind          string                                `gorm:"column:kind;type:text;not null"`
+	Recipient     string                                `gorm:"column:recipient;type:text;not null"`
+	Subject       string                                `gorm:"column:subject;type:text;not null"`
+	HTMLBody      *string                               `gorm:"column:html_body;type:text"`
+	TextBody      string                                `gorm:"column:text_body;type:text;not null"`
+	Attachments   datatypes.JSONSlice[OutboxAttachment] `gorm:"column:attachments;type:jsonb;not null"`
+	Status        string                                `gorm:"column:status;type:text;not null;default:pending"`
+	Attempts      int                                   `gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt time.Time                             `gorm:"column:next_attempt_at;type:timestamptz;not null"`
+	LastError     *string                               `gorm:"column:last_error;type:text"`
+	SentAt        *time.Time                            `gorm:"column:sent_at;type:timestamptz"`
+	CreatedAt     time.Time                             `gorm:"column:created_at;type:timestamptz;not null;autoCreateTime"`
+	UpdatedAt     time.Time                             `gorm:"column:updated_at;type:timestamptz;not null;autoUpdateTime"`
+}
+
+// TableName overrides the default table name.
+func (OutboxEmail) TableName() string {
+	return "email_outbox"
+}
+
+// OutboxAttachment is a stored object attached to a queued email under Name. Key is the object storage key, so
+// any machine with bucket access can send the email.
+type OutboxAttachment struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// OutboxEmailPass links a queued email to a pass it delivers.
+type OutboxEmailPass struct {
+	EmailID      string `gorm:"column:email_id;type:uuid;primaryKey"`
+	TicketPassID string `gorm:"column:ticket_pass_id;type:uuid;primaryKey"`
+}
+
+// TableName overrides the default table name.
+func (OutboxEmailPass) TableName() string {
+	return "email_outbox_passes"
+}
+
+// TicketPassEvent records one status transition of a ticket pass.
+type TicketPassEvent struct {
+	ID           int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	TicketPassID string    `gorm:"column:ticket_pass_id;type:uuid;not null;index:idx_ticket_pass_events_pass,priority:1"`
+	Event        string    `gorm:"column:event;type:text;not null"`
+	FromStatus   string    `gorm:"column:from_status;type:text;not null"`
+	ToStatus     string    `gorm:"column:to_status;type:text;not null"`
+	Detail       *string   `gorm:"column:detail;type:text"`
+	CreatedAt    time.Time `gorm:"column:created_at;type:timestamptz;not null"`
+}
+
+// TableName overrides the default table name.
+func (TicketPassEvent) TableName() string {
+	return "ticket_pass_events"
+}
+
+// SyncRun records one batch sync: what started it, what it saw and produced, and how it ended.
+type SyncRun struct {
+	ID         string                           `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	EventID    string                           `gorm:"column:event_id;type:text;not null"`
+	Trigger    string                           `gorm:"column:trigger;type:text;not null"`
+	Status     string                           `gorm:"column:status;type:text;not null"`
+	StartedAt  time.Time                        `gorm:"column:started_at;type:timestamptz;not null;index:idx_sync_runs_started_at,sort:desc"`
+	FinishedAt *time.Time                       `gorm:"column:finished_at;type:timestamptz"`
+	Fetched    int                              `gorm:"column:fetched;type:integer;not null;default:0"`
+	New        int                              `gorm:"column:new_passes;type:integer;not null;default:0"`
+	Produced   int                              `gorm:"column:produced;type:integer;not null;default:0"`
+	Voided     int                              `gorm:"column:voided;type:integer;not null;default:0"`
+	Failed     int                              `gorm:"column:failed;type:integer;not null;default:0"`
+	Sent       int                              `gorm:"column:sent;type:integer;not null;default:0"`
+	Error      *string                          `gorm:"column:error;type:text"`
+	Failures   datatypes.JSONSlice[SyncFailure] `gorm:"column:failures;type:jsonb;not null;default:'[]'::jsonb"`
+}
+
+// SyncFailure is one ticket pass a sync run could not process.
+type SyncFailure struct {
+	TicketTailorID string `json:"ticket_id"`
+	Channel        string `json:"channel"`
+	Stage          string `json:"stage"`
+	Error          string `json:"error"`
+}
+
+// TableName overrides the default table name.
+func (SyncRun) TableName() string {
+	return "sync_runs"
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Metadata keys holding the presigned download URL of a stored pass and when it stops working.
+const (
+	PassDownloadURLKey       = "download_url"
+	PassDownloadExpiresAtKey = "download_url_expires_at"
+)
+
+// PassDownload is where a stored pass can be downloaded from.
+type PassDownload struct {
+	Channel        PassChannel
+	TicketTailorID string
+	Status         string
+	// StorageKey is empty until the pass was stored under a recorded key.
+	StorageKey string
+	// URL and ExpiresAt are empty until the pass was first presigned.
+	URL       string
+	ExpiresAt *time.Time
+}
+
+type passDownloadRow struct {
+	Channel        string     `gorm:"column:channel"`
+	TicketTailorID string     `gorm:"column:ticket_tailor_id"`
+	Status         string     `gorm:"column:status"`
+	StorageKey     string     `gorm:"column:storage_key"`
+	URL            string     `gorm:"column:download_url"`
+	ExpiresAt      *time.Time `gorm:"column:expires_at"`
+}
+
+func (r passDownloadRow) download() PassDownload {
+	return PassDownload{
+		Channel:        PassChannel(r.Channel),
+		TicketTailorID: r.TicketTailorID,
+		Status:         r.Status,
+		StorageKey:     r.StorageKey,
+		URL:            r.URL,
+		ExpiresAt:      r.ExpiresAt,
+	}
+}
+
+func passDownloads(ctx context.Context, conn *gorm.DB) *gorm.DB {
+	return conn.WithContext(ctx).
+		Table("ticket_passes").
+		Select("ticket_passes.channel, tickets.ticket_tailor_id, ticket_passes.status, " +
+			"COALESCE(ticket_passes.metadata->>'" + PassStorageKeyKey + "', '') AS storage_key, " +
+			"COALESCE(ticket_passes.metadata->>'" + PassDownloadURLKey + "', '') AS download_url, " +
+			"NULLIF(ticket_passes.metadata->>'" + PassDownloadExpiresAtKey + "', '')::timestamptz AS expires_at").
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id")
+}
+
+// GetPassDownload returns the download state of a ticket's pass on a channel.
+func GetPassDownload(ctx context.Context, conn *gorm.DB, channel PassChannel, ticketTailorID string) (PassDownload, error) {
+	if conn == nil {
+		return PassDownload{}, fmt.Errorf("database connection is required")
+	}
+	if channel == "" {
+		return PassDownload{}, fmt.Errorf("channel is required")
+	}
+	if ticketTailorID == "" {
+		return PassDownload{}, fmt.Errorf("ticketTailorID is required")
+	}
+
+	var rows []passDownloadRow
+	err := passDownloads(ctx, conn).
+		Where("ticket_passes.channel = ?", channel).
+		Where("tickets.ticket_tailor_id = ?", ticketTailorID).
+		Limit(1).
+		Find(&rows).
+		Error
+	if err != nil {
+		return PassDownload{}, fmt.Errorf("fetching pass download: %w", err)
+	}
+	if len(rows) == 0 {
+		return PassDownload{}, ErrPassNotFound
+	}
+	return rows[0].download(), nil
+}
+
+// GetExpiringPassDownloads lists stored, non-voided passes whose download URL is missing or expires before the
+// given time, soonest first.
+func GetExpiringPassDownloads(ctx context.Context, conn *gorm.DB, before time.Time, limit int) ([]PassDownload, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	var rows []passDownloadRow
+	err := passDownloads(ctx, conn).
+		Where("ticket_passes.status <> ?", Voided).
+		Where("COALESCE(ticket_passes.metadata->>?, '') <> ''", PassStorageKeyKey).
+		Where("NULLIF(ticket_passes.metadata->>?, '')::timestamptz IS NULL OR NULLIF(ticket_passes.metadata->>?, '')::timestamptz < ?",
+			PassDownloadExpiresAtKey, PassDownloadExpiresAtKey, before).
+		Order("expires_at NULLS FIRST").
+		Limit(limit).
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("listing expiring pass downloads: %w", err)
+	}
+
+	downloads := make([]PassDownload, len(rows))
+	for i, r := range rows {
+		downloads[i] = r.download()
+	}
+	return downloads, nil
+}
+
+// SetPassDownloadURL records a freshly presigned download URL for the pass. Like all metadata writes it leaves
+// updated_at alone, so refreshing URLs does not tell Apple devices to fetch the pass again.
+func SetPassDownloadURL(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+	url string,
+	expiresAt time.Time,
+) error {
+	if url == "" {
+		return fmt.Errorf("url is required")
+	}
+	if expiresAt.IsZero() {
+		return fmt.Errorf("expiresAt must be set")
+	}
+	return SetPassMetadata(ctx, conn, channel, ticketTailorID, map[string]any{
+		PassDownloadURLKey:       url,
+		PassDownloadExpiresAtKey: expiresAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// PassDownloadStore exposes the pass download repository functions over a single connection.
+type PassDownloadStore struct {
+	DB *gorm.DB
+}
+
+// NewPassDownloadStore binds the store to a database connection.
+func NewPassDownloadStore(conn *gorm.DB) PassDownloadStore {
+	return PassDownloadStore{DB: conn}
+}
+
+func (s PassDownloadStore) GetPassDownload(ctx context.Context, channel PassChannel, ticketTailorID string) (PassDownload, error) {
+	return GetPassDownload(ctx, s.DB, channel, ticketTailorID)
+}
+
+func (s PassDownloadStore) GetExpiringPassDownloads(ctx context.Context, before time.Time, limit int) ([]PassDownload, error) {
+	return GetExpiringPassDownloads(ctx, s.DB, before, limit)
+}
+
+func (s PassDownloadStore) SetPassDownloadURL(ctx context.Context, channel PassChannel, ticketTailorID string, url string, expiresAt time.Time) error {
+	return SetPassDownloadURL(ctx, s.DB, channel, ticketTailorID, url, expiresAt)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassDownloads(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	for _, id := range []string{"tt_fresh", "tt_stale", "tt_unsigned", "tt_unstored", "tt_voided"} {
+		require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, id, "buyer@example.com", now))
+		if id != "tt_unstored" {
+			require.NoError(t, SetPassMetadata(ctx, conn, AppleWalletChannel, id, map[string]any{PassStorageKeyKey: "ev_1/apple-wallet/" + id + ".pkpass"}))
+		}
+	}
+	require.NoError(t, SetPassDownloadURL(ctx, conn, AppleWalletChannel, "tt_fresh", "https://s3/fresh", now.Add(6*24*time.Hour)))
+	require.NoError(t, SetPassDownloadURL(ctx, conn, AppleWalletChannel, "tt_stale", "https://s3/stale", now.Add(time.Hour)))
+	_, err := SetPassVoided(ctx, conn, AppleWalletChannel, "tt_voided", now)
+	require.NoError(t, err)
+
+	download, err := GetPassDownload(ctx, conn, AppleWalletChannel, "tt_fresh")
+	require.NoError(t, err)
+	require.Equal(t, "ev_1/apple-wallet/tt_fresh.pkpass", download.StorageKey)
+	require.Equal(t, "https://s3/fresh", download.URL)
+	require.NotNil(t, download.ExpiresAt)
+	require.True(t, download.ExpiresAt.Equal(now.Add(6*24*time.Hour)))
+
+	voided, err := GetPassDownload(ctx, conn, AppleWalletChannel, "tt_voided")
+	require.NoError(t, err)
+	require.Equal(t, string(Voided), voided.Status)
+
+	_, err = GetPassDownload(ctx, conn, GoogleWalletChannel, "tt_fresh")
+	require.ErrorIs(t, err, ErrPassNotFound)
+
+	expiring, err := GetExpiringPassDownloads(ctx, conn, now.Add(2*24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, expiring, 2)
+	require.Equal(t, "tt_unsigned", expiring[0].TicketTailorID, "never presigned passes come first")
+	require.Equal(t, "tt_stale", expiring[1].TicketTailorID)
+}
+
+func TestPassDownloadURLRefreshKeepsApplePassUnchanged(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	const passType = "pass.com.hakuna.integration"
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_1", "buyer@example.com", now))
+	require.NoError(t, SetPassMetadata(ctx, conn, AppleWalletChannel, "tt_1", map[string]any{PassStorageKeyKey: "ev_1/apple-wallet/tt_1.pkpass"}))
+	_, err := RegisterAppleDevice(ctx, conn, "device-1", "push-1", passType, "tt_1")
+	require.NoError(t, err)
+
+	serials, lastUpdated, err := GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tt_1"}, serials)
+
+	require.NoError(t, SetPassDownloadURL(ctx, conn, AppleWalletChannel, "tt_1", "https://s3/first", now.Add(time.Hour)))
+	require.NoError(t, SetPassDownloadURL(ctx, conn, AppleWalletChannel, "tt_1", "https://s3/second", now.Add(2*time.Hour)))
+
+	serials, _, err = GetUpdatedAppleSerialNumbers(ctx, conn, "device-1", passType, &lastUpdated)
+	require.NoError(t, err)
+	require.Empty(t, serials, "presigning a download url again is not a pass update")
+}
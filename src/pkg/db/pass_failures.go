@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetrySchedule returns when a pass that has failed attempts times is tried again, or false to stop retrying it.
+type RetrySchedule func(attempts int) (time.Time, bool)
+
+// PassFailure describes why a channel pass could not be produced.
+type PassFailure struct {
+	Channel        PassChannel
+	TicketTailorID string
+	Email          string
+	// Stage is the pipeline step that failed, e.g. generate, store or record.
+	Stage   string
+	Message string
+}
+
+// SetPassFailed records a failed attempt at a channel pass, creating the ticket and pass rows when the pass was
+// never produced. Any live pass may fail; the attempt count grows with every failure and schedule decides the
+// next retry. Voided passes are returned unchanged.
+func SetPassFailed(
+	ctx context.Context,
+	conn *gorm.DB,
+	failure PassFailure,
+	failedAt time.Time,
+	schedule RetrySchedule,
+) (TicketPass, error) {
+	if conn == nil {
+		return TicketPass{}, fmt.Errorf("database connection is required")
+	}
+	if failure.Channel == "" {
+		return TicketPass{}, fmt.Errorf("channel is required")
+	}
+	if failure.TicketTailorID == "" {
+		return TicketPass{}, fmt.Errorf("ticketTailorID is required")
+	}
+	if failure.Stage == "" {
+		return TicketPass{}, fmt.Errorf("stage is required")
+	}
+	if failedAt.IsZero() {
+		return TicketPass{}, fmt.Errorf("failedAt must be set")
+	}
+	if schedule == nil {
+		return TicketPass{}, fmt.Errorf("retry schedule is required")
+	}
+
+	var pass TicketPass
+	err := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ticket, err := upsertTicket(tx, failure.TicketTailorID, failure.Email)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ticket_id = ? AND channel = ?", ticket.ID, failure.Channel).
+			First(&pass).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			pass = TicketPass{TicketID: ticket.ID, Channel: string(failure.Channel), Status: string(Pending)}
+			if err := tx.Create(&pass).Error; err != nil {
+				return fmt.Errorf("creating ticket pass: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("fetching ticket pass: %w", err)
+		}
+		if !FailPass.Allows(PassStatus(pass.Status)) {
+			return nil
+		}
+
+		attempts := pass.Attempts + 1
+		var nextRetryAt *time.Time
+		if retryAt, ok := schedule(attempts); ok {
+			nextRetryAt = &retryAt
+		}
+		changed, err := transitionPass(tx, &pass, FailPass, failedAt, map[string]any{
+			"error_message": failure.Message,
+			"failure_stage": failure.Stage,
+			"attempts":      attempts,
+			"next_retry_at": nextRetryAt,
+		}, failure.Stage+": "+failure.Message)
+		if err != nil {
+			return err
+		}
+		if changed {
+			pass.ErrorMessage = &failure.Message
+			pass.FailureStage = &failure.Stage
+			pass.Attempts = attempts
+			pass.NextRetryAt = nextRetryAt
+		}
+		return nil
+	})
+	if err != nil {
+		return TicketPass{}, err
+	}
+	return pass, nil
+}
+
+// GetPassRetries returns the Ticket Tailor IDs with a failed pass on any channel that is due for another attempt
+// at now. Dead-lettered deliveries are never retried.
+func GetPassRetries(ctx context.Context, conn *gorm.DB, now time.Time) ([]string, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if now.IsZero() {
+		return nil, fmt.Errorf("now must be set")
+	}
+
+	var ticketIDs []string
+	err := conn.WithContext(ctx).
+		Table("ticket_passes").
+		Distinct("tickets.ticket_tailor_id").
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
+		Where("ticket_passes.status = ?", Failed).
+		Where("ticket_passes.failure_stage IS DISTINCT FROM ?", PassStageDeliver).
+		Where("ticket_passes.next_retry_at <= ?", now).
+		Order("tickets.ticket_tailor_id").
+		Pluck("tickets.ticket_tailor_id", &ticketIDs).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("listing pass retries: %w", err)
+	}
+	return ticketIDs, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPassFailed(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	failedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	schedule := func(attempts int) (time.Time, bool) {
+		return failedAt.Add(time.Duration(attempts) * time.Hour), attempts < 3
+	}
+	failure := PassFailure{
+		Channel:        AppleWalletChannel,
+		TicketTailorID: "tt_broken",
+		Email:          "buyer@example.com",
+		Stage:          "generate",
+		Message:        "signing pass: bad certificate",
+	}
+
+	pass, err := SetPassFailed(ctx, conn, failure, failedAt, schedule)
+	require.NoError(t, err)
+	require.Equal(t, string(Failed), pass.Status)
+	require.Equal(t, 1, pass.Attempts)
+	require.Equal(t, "generate", *pass.FailureStage)
+	require.True(t, failedAt.Add(time.Hour).Equal(*pass.NextRetryAt))
+
+	records, err := GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.False(t, records["tt_broken"].Retryable(failedAt), "not due before the backoff")
+	require.True(t, records["tt_broken"].Retryable(failedAt.Add(time.Hour)))
+
+	retries, err := GetPassRetries(ctx, conn, failedAt.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, []string{"tt_broken"}, retries)
+
+	failure.Stage = "store"
+	pass, err = SetPassFailed(ctx, conn, failure, failedAt, schedule)
+	require.NoError(t, err)
+	require.Equal(t, 2, pass.Attempts)
+	require.Equal(t, "store", *pass.FailureStage)
+
+	pass, err = SetPassFailed(ctx, conn, failure, failedAt, schedule)
+	require.NoError(t, err)
+	require.Equal(t, 3, pass.Attempts)
+	require.Nil(t, pass.NextRetryAt, "retries give up after the schedule says so")
+
+	retries, err = GetPassRetries(ctx, conn, failedAt.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, retries)
+
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_broken", "buyer@example.com", failedAt))
+	records, err = GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, string(Produced), records["tt_broken"].Status)
+	require.Zero(t, records["tt_broken"].Attempts, "producing the pass resets its failures")
+	require.Empty(t, records["tt_broken"].FailureStage)
+}
+
+func TestSetPassFailedLeavesVoidedPasses(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_refunded", "buyer@example.com", producedAt))
+	_, err := SetPassVoided(ctx, conn, AppleWalletChannel, "tt_refunded", producedAt.Add(time.Hour))
+	require.NoError(t, err)
+
+	pass, err := SetPassFailed(ctx, conn, PassFailure{
+		Channel:        AppleWalletChannel,
+		TicketTailorID: "tt_refunded",
+		Stage:          "store",
+		Message:        "bucket unavailable",
+	}, producedAt, func(int) (time.Time, bool) { return producedAt, true })
+	require.NoError(t, err)
+	require.Equal(t, string(Voided), pass.Status)
+	require.Zero(t, pass.Attempts)
+}
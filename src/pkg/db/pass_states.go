@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIllegalTransition is returned when a pass is not in a status its transition may start from.
+var ErrIllegalTransition = errors.New("illegal pass status transition")
+
+// ErrPassClaimed is returned when a pass to be produced was already produced, e.g. by a concurrent worker.
+var ErrPassClaimed = fmt.Errorf("pass already claimed: %w", ErrIllegalTransition)
+
+// PassEvent names a pass status transition in ticket_pass_events.
+type PassEvent string
+
+const (
+	PassProducedEvent   PassEvent = "produced"
+	PassReissuedEvent   PassEvent = "reissued"
+	PassUploadedEvent   PassEvent = "uploaded"
+	PassSentEvent       PassEvent = "sent"
+	PassFailedEvent     PassEvent = "failed"
+	PassVoidedEvent     PassEvent = "voided"
+	PassReinstatedEvent PassEvent = "reinstated"
+)
+
+// PassTransition moves a pass to To from any status in From.
+type PassTransition struct {
+	Event PassEvent
+	From  []PassStatus
+	To    PassStatus
+}
+
+// The pass state machine: pending → produced → uploaded → sent, with failed and voided reachable from every
+// live status. A failed pass is produced again on retry, and a delivered pass is only rendered again through an
+// explicit re-issue. A voided pass stays voided unless Ticket Tailor reinstates its ticket.
+var (
+	ProducePass   = PassTransition{Event: PassProducedEvent, From: []PassStatus{Pending, Failed}, To: Produced}
+	ReissuePass   = PassTransition{Event: PassReissuedEvent, From: []PassStatus{Produced, Uploaded, Sent}, To: Produced}
+	ReinstatePass = PassTransition{Event: PassReinstatedEvent, From: []PassStatus{Voided}, To: Produced}
+	UploadPass    = PassTransition{Event: PassUploadedEvent, From: []PassStatus{Produced}, To: Uploaded}
+	SendPass      = PassTransition{Event: PassSentEvent, From: []PassStatus{Uploaded}, To: Sent}
+	FailPass      = PassTransition{Event: PassFailedEvent, From: livePassStatuses, To: Failed}
+	VoidPass      = PassTransition{Event: PassVoidedEvent, From: livePassStatuses, To: Voided}
+)
+
+var livePassStatuses = []PassStatus{Pending, Produced, Uploaded, Sent, Failed}
+
+// Allows reports whether the transition may start from status.
+func (t PassTransition) Allows(status PassStatus) bool {
+	return slices.Contains(t.From, status)
+}
+
+func (t PassTransition) from() []string {
+	statuses := make([]string, len(t.From))
+	for i, status := range t.From {
+		statuses[i] = string(status)
+	}
+	return statuses
+}
+
+// transitionPass applies t to pass inside tx with an update conditioned on the allowed source statuses, merges
+// values into the same update and records the event. It reports false, leaving pass untouched, when the stored
+// status does not allow the transition.
+func transitionPass(
+	tx *gorm.DB,
+	pass *TicketPass,
+	t PassTransition,
+	at time.Time,
+	values map[string]any,
+	detail string,
+) (bool, error) {
+	updates := map[string]any{"status": string(t.To)}
+	for column, value := range values {
+		updates[column] = value
+	}
+
+	result := tx.Model(&TicketPass{}).
+		Where("id = ? AND status IN ?", pass.ID, t.from()).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("moving ticket pass to %s: %w", t.To, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := recordPassEvent(tx, pass.ID, t.Event, PassStatus(pass.Status), t.To, at, detail); err != nil {
+		return false, err
+	}
+
+	pass.Status = string(t.To)
+	return true, nil
+}
+
+func recordPassEvent(
+	tx *gorm.DB,
+	passID string,
+	event PassEvent,
+	from PassStatus,
+	to PassStatus,
+	at time.Time,
+	detail string,
+) error {
+	row := TicketPassEvent{
+		TicketPassID: passID,
+		Event:        string(event),
+		FromStatus:   string(from),
+		ToStatus:     string(to),
+		CreatedAt:    at,
+	}
+	if detail != "" {
+		row.Detail = &detail
+	}
+	if err := tx.Create(&row).Error; err != nil {
+		return fmt.Errorf("recording ticket pass %s event: %w", event, err)
+	}
+	return nil
+}
+
+// mustTransitionPass is transitionPass for callers that treat a disallowed status as an error.
+func mustTransitionPass(
+	tx *gorm.DB,
+	pass *TicketPass,
+	t PassTransition,
+	at time.Time,
+	values map[string]any,
+	detail string,
+) error {
+	from := pass.Status
+	ok, err := transitionPass(tx, pass, t, at, values, detail)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s from %s", ErrIllegalTransition, t.Event, from)
+	}
+	return nil
+}
+
+// transitionPasses applies t to every pass selected by scope that allows it, locking them first so each event
+// records the status it left.
+func transitionPasses(
+	tx *gorm.DB,
+	scope func(*gorm.DB) *gorm.DB,
+	t PassTransition,
+	at time.Time,
+	values map[string]any,
+	detail string,
+) error {
+	var passes []TicketPass
+	err := scope(tx.Model(&TicketPass{})).
+		Where("status IN ?", t.from()).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Find(&passes).Error
+	if err != nil {
+		return fmt.Errorf("locking ticket passes: %w", err)
+	}
+	for i := range passes {
+		if _, err := transitionPass(tx, &passes[i], t, at, values, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPassEvents returns the status history of a ticket's pass on a channel, oldest first.
+func GetPassEvents(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+) ([]TicketPassEvent, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+	if ticketTailorID == "" {
+		return nil, fmt.Errorf("ticketTailorID is required")
+	}
+
+	var events []TicketPassEvent
+	err := conn.WithContext(ctx).
+		Joins("JOIN ticket_passes ON ticket_passes.id = ticket_pass_events.ticket_pass_id").
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
+		Where("ticket_passes.channel = ? AND tickets.ticket_tailor_id = ?", channel, ticketTailorID).
+		Order("ticket_pass_events.id").
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("listing pass events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassTransitionsAllow(t *testing.T) {
+	cases := map[string]struct {
+		transition PassTransition
+		from       PassStatus
+		want       bool
+	}{
+		"produce pending":   {transition: ProducePass, from: Pending, want: true},
+		"produce retry":     {transition: ProducePass, from: Failed, want: true},
+		"produce sent":      {transition: ProducePass, from: Sent, want: false},
+		"reissue sent":      {transition: ReissuePass, from: Sent, want: true},
+		"upload produced":   {transition: UploadPass, from: Produced, want: true},
+		"upload pending":    {transition: UploadPass, from: Pending, want: false},
+		"send uploaded":     {transition: SendPass, from: Uploaded, want: true},
+		"send produced":     {transition: SendPass, from: Produced, want: false},
+		"fail sent":         {transition: FailPass, from: Sent, want: true},
+		"fail voided":       {transition: FailPass, from: Voided, want: false},
+		"void uploaded":     {transition: VoidPass, from: Uploaded, want: true},
+		"void voided":       {transition: VoidPass, from: Voided, want: false},
+		"reissue voided":    {transition: ReissuePass, from: Voided, want: false},
+		"produce voided":    {transition: ProducePass, from: Voided, want: false},
+		"reinstate voided":  {transition: ReinstatePass, from: Voided, want: true},
+		"reinstate sent":    {transition: ReinstatePass, from: Sent, want: false},
+		"reissue pending":   {transition: ReissuePass, from: Pending, want: false},
+		"send already sent": {transition: SendPass, from: Sent, want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.transition.Allows(tc.from))
+		})
+	}
+}
+
+func TestPassStateMachine(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	at := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	pass := ProducedPass{Channel: AppleWalletChannel, TicketTailorID: "tt_lifecycle", Email: "buyer@example.com"}
+	message := OutboxMessage{Kind: "order_passes", Recipient: "buyer@example.com", Subject: "Your tickets", TextBody: "Hi"}
+	require.NoError(t, SetPassesProducedAndEnqueue(ctx, conn, []ProducedPass{pass}, at, message))
+
+	claimed, err := ClaimOutboxEmails(ctx, conn, at, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.NoError(t, MarkOutboxEmailSent(ctx, conn, claimed[0].ID, at.Add(time.Minute)))
+
+	err = SetPassProduced(ctx, conn, pass.Channel, pass.TicketTailorID, pass.Email, at.Add(time.Hour))
+	require.ErrorIs(t, err, ErrIllegalTransition, "a sent pass is not produced again without a re-issue")
+
+	require.NoError(t, SetPassReissued(ctx, conn, pass.Channel, pass.TicketTailorID, pass.Email, at.Add(time.Hour)))
+	changed, err := SetPassVoided(ctx, conn, pass.Channel, pass.TicketTailorID, at.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	err = SetPassReissued(ctx, conn, pass.Channel, pass.TicketTailorID, pass.Email, at.Add(3*time.Hour))
+	require.ErrorIs(t, err, ErrIllegalTransition, "voided passes are not re-issued")
+
+	require.NoError(t, SetPassProduced(ctx, conn, pass.Channel, pass.TicketTailorID, pass.Email, at.Add(4*time.Hour)),
+		"a ticket Ticket Tailor reinstates gets its pass back")
+	var reinstated TicketPass
+	require.NoError(t, conn.WithContext(ctx).
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
+		Where("tickets.ticket_tailor_id = ? AND ticket_passes.channel = ?", pass.TicketTailorID, pass.Channel).
+		First(&reinstated).Error)
+	require.Equal(t, string(Produced), reinstated.Status)
+	require.Nil(t, reinstated.VoidedAt)
+
+	events, err := GetPassEvents(ctx, conn, pass.Channel, pass.TicketTailorID)
+	require.NoError(t, err)
+	var history []string
+	for _, event := range events {
+		history = append(history, event.FromStatus+">"+event.ToStatus)
+	}
+	require.Equal(t, []string{
+		"pending>produced",
+		"produced>uploaded",
+		"uploaded>sent",
+		"sent>produced",
+		"produced>voided",
+		"voided>produced",
+	}, history)
+	require.Equal(t, string(PassReissuedEvent), events[3].Event)
+	require.Equal(t, string(PassReinstatedEvent), events[5].Event)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -10,13 +11,17 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// PassStatus is a state of the pass state machine, see PassTransition.
 type PassStatus string
 
 const (
 	Pending  PassStatus = "pending"
 	Produced PassStatus = "produced"
+	// Uploaded passes are stored in every artifact sink.
+	Uploaded PassStatus = "uploaded"
 	Sent     PassStatus = "sent"
 	Failed   PassStatus = "failed"
+	Voided   PassStatus = "voided"
 )
 
 type PassChannel string
@@ -34,9 +39,36 @@ type PassRecord struct {
 	ProducedAt     *time.Time
 	DeliveredAt    *time.Time
 	ErrorMessage   *string
+	// Fingerprint is the ticket content hash the pass was last produced from; empty for passes produced before
+	// fingerprints were recorded.
+	Fingerprint string
+	// FailureStage, Attempts and NextRetryAt describe a failed pass; NextRetryAt is nil once retries gave up.
+	FailureStage string
+	Attempts     int
+	NextRetryAt  *time.Time
 }
 
+// PassStageDeliver marks passes whose delivery email was dead-lettered; they are never produced again.
+const PassStageDeliver = "deliver"
+
+// Retryable reports whether the pass failed before it was delivered and is due for another attempt at now.
+func (r PassRecord) Retryable(now time.Time) bool {
+	return r.Status == string(Failed) &&
+		r.FailureStage != PassStageDeliver &&
+		r.NextRetryAt != nil &&
+		!r.NextRetryAt.After(now)
+}
+
+// PassFingerprintKey is the ticket_passes.metadata key holding the pass content fingerprint.
+const PassFingerprintKey = "fingerprint"
+
+// PassStorageKeyKey is the ticket_passes.metadata key holding the storage key the pass was last stored under.
+const PassStorageKeyKey = "storage_key"
+
 // GetProducedPasses returns a map keyed by Ticket Tailor ID for passes that have been produced (or beyond) for a channel.
+// Failed passes are included: dead-lettered deliveries count as produced so they are not silently re-sent, and
+// production failures carry their retry schedule, see PassRecord.Retryable. Voided passes are included so a
+// ticket Ticket Tailor reinstates can be told apart from one that never had a pass.
 func GetProducedPasses(
 	ctx context.Context,
 	conn *gorm.DB,
@@ -56,15 +88,19 @@ func GetProducedPasses(
 		ProducedAt     *time.Time `gorm:"column:produced_at"`
 		DeliveredAt    *time.Time `gorm:"column:delivered_at"`
 		ErrorMessage   *string    `gorm:"column:error_message"`
+		Fingerprint    string     `gorm:"column:fingerprint"`
+		FailureStage   string     `gorm:"column:failure_stage"`
+		Attempts       int        `gorm:"column:attempts"`
+		NextRetryAt    *time.Time `gorm:"column:next_retry_at"`
 	}
 
 	var results []ticketsRow
 	err := conn.WithContext(ctx).
 		Table("ticket_passes").
-		Select("tickets.ticket_tailor_id", "tickets.purchaser_email", "ticket_passes.status", "ticket_passes.produced_at", "ticket_passes.delivered_at", "ticket_passes.error_message").
+		Select("tickets.ticket_tailor_id, tickets.purchaser_email, ticket_passes.status, ticket_passes.produced_at, ticket_passes.delivered_at, ticket_passes.error_message, COALESCE(ticket_passes.metadata->>'"+PassFingerprintKey+"', '') AS fingerprint, COALESCE(ticket_passes.failure_stage, '') AS failure_stage, ticket_passes.attempts, ticket_passes.next_retry_at").
 		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
 		Where("ticket_passes.channel = ?", channel).
-		Where("ticket_passes.status IN ?", []string{string(Produced), string(Sent)}).
+		Where("ticket_passes.status IN ?", []string{string(Produced), string(Uploaded), string(Sent), string(Failed), string(Voided)}).
 		Find(&results).
 		Error
 	if err != nil {
@@ -80,13 +116,19 @@ func GetProducedPasses(
 			ProducedAt:     r.ProducedAt,
 			DeliveredAt:    r.DeliveredAt,
 			ErrorMessage:   r.ErrorMessage,
+			Fingerprint:    r.Fingerprint,
+			FailureStage:   r.FailureStage,
+			Attempts:       r.Attempts,
+			NextRetryAt:    r.NextRetryAt,
 		}
 	}
 
 	return records, nil
 }
 
-// SetPassProduced upserts the ticket and marks the channel-specific pass as produced.
+// SetPassProduced upserts the ticket and claims its channel pass as produced. Only new, pending and failed passes
+// may be produced, and a voided pass is reinstated; ErrPassClaimed means another worker produced the pass first,
+// and a pass that was already produced is rendered again through SetPassReissued.
 func SetPassProduced(
 	ctx context.Context,
 	conn *gorm.DB,
@@ -95,77 +137,385 @@ func SetPassProduced(
 	email string,
 	producedAt time.Time,
 ) error {
+	return setPassProduced(ctx, conn, ProducedPass{Channel: channel, TicketTailorID: ticketTailorID, Email: email}, producedAt)
+}
+
+// SetPassReissued moves a pass that was produced, uploaded or sent back to produced after its ticket changed.
+// Re-issuing clears delivered_at so the new artifact is delivered again.
+func SetPassReissued(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+	email string,
+	producedAt time.Time,
+) error {
+	return setPassProduced(ctx, conn, ProducedPass{Channel: channel, TicketTailorID: ticketTailorID, Email: email, Reissue: true}, producedAt)
+}
+
+func setPassProduced(ctx context.Context, conn *gorm.DB, produced ProducedPass, producedAt time.Time) error {
 	if conn == nil {
 		return fmt.Errorf("database connection is required")
 	}
-	if channel == "" {
-		return fmt.Errorf("channel is required")
+	if err := validateProducedPasses([]ProducedPass{produced}, producedAt); err != nil {
+		return err
 	}
-	if ticketTailorID == "" {
-		return fmt.Errorf("ticketTailorID is required")
+
+	return conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := producePass(tx, produced, producedAt)
+		return err
+	})
+}
+
+// SetPassesProduced records passes that every sink already stored: each moves to produced, or is re-issued, and
+// on to uploaded in one transaction.
+func SetPassesProduced(
+	ctx context.Context,
+	conn *gorm.DB,
+	passes []ProducedPass,
+	producedAt time.Time,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
 	}
-	if email == "" {
-		return fmt.Errorf("email is required")
+	if err := validateProducedPasses(passes, producedAt); err != nil {
+		return err
+	}
+
+	return conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := uploadPasses(tx, passes, producedAt)
+		return err
+	})
+}
+
+func validateProducedPasses(passes []ProducedPass, producedAt time.Time) error {
+	if len(passes) == 0 {
+		return fmt.Errorf("passes are required")
+	}
+	for _, pass := range passes {
+		if pass.Channel == "" {
+			return fmt.Errorf("channel is required")
+		}
+		if pass.TicketTailorID == "" {
+			return fmt.Errorf("ticketTailorID is required")
+		}
+		if pass.Email == "" {
+			return fmt.Errorf("email is required")
+		}
 	}
 	if producedAt.IsZero() {
 		return fmt.Errorf("producedAt must be set")
 	}
+	return nil
+}
 
-	err := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var ticket Ticket
-		err := tx.Clauses(
-			clause.Locking{Strength: "UPDATE"}).
-			Where("ticket_tailor_id = ?", ticketTailorID).
-			First(&ticket).Error
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			ticket = Ticket{
-				TicketTailorID: ticketTailorID,
-				PurchaserEmail: email,
-			}
-			if err := tx.Create(&ticket).Error; err != nil {
-				return fmt.Errorf("creating ticket record: %w", err)
-			}
-		case err != nil:
-			return fmt.Errorf("fetching ticket: %w", err)
-		default:
-			if email != "" && email != ticket.PurchaserEmail {
-				ticket.PurchaserEmail = email
-				if err := tx.Save(&ticket).Error; err != nil {
-					return fmt.Errorf("updating ticket: %w", err)
-				}
-			}
+// uploadPasses produces every pass and moves it on to uploaded inside tx, returning their IDs.
+func uploadPasses(tx *gorm.DB, passes []ProducedPass, producedAt time.Time) ([]string, error) {
+	passIDs := make([]string, 0, len(passes))
+	for _, produced := range passes {
+		pass, err := producePass(tx, produced, producedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := mustTransitionPass(tx, &pass, UploadPass, producedAt, nil, ""); err != nil {
+			return nil, err
+		}
+		passIDs = append(passIDs, pass.ID)
+	}
+	return passIDs, nil
+}
+
+// producePass upserts the ticket and moves its channel pass to produced inside tx. New passes start out pending;
+// a re-issue of a pass that may simply be produced is recorded as produced, and producing a voided pass reinstates
+// it.
+func producePass(tx *gorm.DB, produced ProducedPass, producedAt time.Time) (TicketPass, error) {
+	ticket, err := upsertTicket(tx, produced.TicketTailorID, produced.Email)
+	if err != nil {
+		return TicketPass{}, err
+	}
+
+	pass, claimed, err := claimPass(tx, ticket.ID, produced.Channel, producedAt)
+	if err != nil {
+		return TicketPass{}, err
+	}
+	if claimed {
+		return pass, mergePassMetadata(tx, pass.ID, produced.Metadata)
+	}
+
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ticket_id = ? AND channel = ?", ticket.ID, produced.Channel).
+		First(&pass).Error
+	if err != nil {
+		return TicketPass{}, fmt.Errorf("fetching ticket pass: %w", err)
+	}
+
+	transition := ProducePass
+	switch {
+	case produced.Reissue && !ProducePass.Allows(PassStatus(pass.Status)):
+		transition = ReissuePass
+	case !produced.Reissue && ReinstatePass.Allows(PassStatus(pass.Status)):
+		transition = ReinstatePass
+	}
+	from := pass.Status
+	ok, err := transitionPass(tx, &pass, transition, producedAt, map[string]any{
+		"produced_at":   producedAt,
+		"delivered_at":  nil,
+		"error_message": nil,
+		"failure_stage": nil,
+		"attempts":      0,
+		"next_retry_at": nil,
+		"voided_at":     nil,
+	}, "")
+	switch {
+	case err != nil:
+		return TicketPass{}, fmt.Errorf("ticket %s: %w", produced.TicketTailorID, err)
+	case !ok && !produced.Reissue && ReissuePass.Allows(PassStatus(from)):
+		return TicketPass{}, fmt.Errorf("ticket %s: %w", produced.TicketTailorID, ErrPassClaimed)
+	case !ok:
+		return TicketPass{}, fmt.Errorf("ticket %s: %w: %s from %s", produced.TicketTailorID, ErrIllegalTransition, transition.Event, from)
+	}
+	return pass, mergePassMetadata(tx, pass.ID, produced.Metadata)
+}
+
+// claimPass inserts the channel pass of a ticket straight as produced and reports whether it did. The insert is
+// the claim: of two workers producing the same new pass, exactly one inserts it and the other finds the row.
+func claimPass(tx *gorm.DB, ticketID string, channel PassChannel, producedAt time.Time) (TicketPass, bool, error) {
+	pass := TicketPass{
+		TicketID:   ticketID,
+		Channel:    string(channel),
+		Status:     string(Produced),
+		ProducedAt: &producedAt,
+	}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ticket_id"}, {Name: "channel"}},
+		DoNothing: true,
+	}).Create(&pass)
+	if result.Error != nil {
+		return TicketPass{}, false, fmt.Errorf("claiming ticket pass: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return TicketPass{}, false, nil
+	}
+
+	if err := recordPassEvent(tx, pass.ID, ProducePass.Event, Pending, Produced, producedAt, ""); err != nil {
+		return TicketPass{}, false, err
+	}
+	return pass, true, nil
+}
+
+// upsertTicket locks the ticket row inside tx, creating it or updating its purchaser email as needed. The row is
+// inserted with ON CONFLICT DO NOTHING so concurrent producers of a new ticket wait on each other instead of
+// failing on the unique ticket_tailor_id.
+func upsertTicket(tx *gorm.DB, ticketTailorID string, email string) (Ticket, error) {
+	insert := Ticket{
+		TicketTailorID: ticketTailorID,
+		PurchaserEmail: email,
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ticket_tailor_id"}},
+		DoNothing: true,
+	}).Create(&insert).Error
+	if err != nil {
+		return Ticket{}, fmt.Errorf("creating ticket record: %w", err)
+	}
+
+	var ticket Ticket
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ticket_tailor_id = ?", ticketTailorID).
+		First(&ticket).Error
+	if err != nil {
+		return Ticket{}, fmt.Errorf("fetching ticket: %w", err)
+	}
+	if email != "" && email != ticket.PurchaserEmail {
+		ticket.PurchaserEmail = email
+		if err := tx.Save(&ticket).Error; err != nil {
+			return Ticket{}, fmt.Errorf("updating ticket: %w", err)
 		}
+	}
+	return ticket, nil
+}
+
+// SetPassVoided marks a produced pass as voided. It reports false when the ticket has no pass on the channel or
+// the pass was already voided, so callers can run it on every sync without repeating side effects.
+func SetPassVoided(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+	voidedAt time.Time,
+) (bool, error) {
+	return setPassVoided(ctx, conn, channel, ticketTailorID, voidedAt, nil)
+}
+
+// SetPassVoidedAndEnqueue voids a pass like SetPassVoided and, only when the pass changed, queues message in the
+// same transaction, so the holder is told once and the notice survives a failing mail server.
+func SetPassVoidedAndEnqueue(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+	voidedAt time.Time,
+	message OutboxMessage,
+) (bool, error) {
+	if err := validateOutboxMessage(message); err != nil {
+		return false, err
+	}
+	return setPassVoided(ctx, conn, channel, ticketTailorID, voidedAt, &message)
+}
+
+func setPassVoided(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+	voidedAt time.Time,
+	message *OutboxMessage,
+) (bool, error) {
+	if conn == nil {
+		return false, fmt.Errorf("database connection is required")
+	}
+	if channel == "" {
+		return false, fmt.Errorf("channel is required")
+	}
+	if ticketTailorID == "" {
+		return false, fmt.Errorf("ticketTailorID is required")
+	}
+	if voidedAt.IsZero() {
+		return false, fmt.Errorf("voidedAt must be set")
+	}
 
+	var changed bool
+	err := conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var pass TicketPass
-		err = tx.Where("ticket_id = ? AND channel = ?", ticket.ID, channel).First(&pass).Error
-		switch {
-		case errors.Is(err, gorm.ErrRecordNotFound):
-			pass = TicketPass{
-				TicketID:     ticket.ID,
-				Channel:      string(channel),
-				Status:       string(Produced),
-				ProducedAt:   &producedAt,
-				ErrorMessage: nil,
-			}
-			if err := tx.Create(&pass).Error; err != nil {
-				return fmt.Errorf("creating ticket pass: %w", err)
-			}
-		case err != nil:
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("channel = ?", channel).
+			Where("ticket_id = (SELECT id FROM tickets WHERE ticket_tailor_id = ?)", ticketTailorID).
+			First(&pass).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
 			return fmt.Errorf("fetching ticket pass: %w", err)
-		default:
-			pass.Status = string(Produced)
-			pass.ProducedAt = &producedAt
-			pass.ErrorMessage = nil
-			if err := tx.Save(&pass).Error; err != nil {
-				return fmt.Errorf("updating ticket pass: %w", err)
-			}
 		}
 
-		return nil
+		changed, err = transitionPass(tx, &pass, VoidPass, voidedAt, map[string]any{
+			"voided_at":     voidedAt,
+			"error_message": nil,
+			"next_retry_at": nil,
+		}, "")
+		if err != nil || !changed || message == nil {
+			return err
+		}
+		_, err = enqueueEmail(tx, *message, voidedAt)
+		return err
 	})
+	if err != nil {
+		return false, fmt.Errorf("voiding ticket pass: %w", err)
+	}
+	return changed, nil
+}
+
+// SetPassMetadata merges values into the channel-specific pass metadata, leaving other keys untouched. It keeps
+// updated_at as is: Apple devices are told their pass changed by that column, and metadata is bookkeeping about
+// the artifact, not its content.
+func SetPassMetadata(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+	ticketTailorID string,
+	values map[string]any,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if channel == "" {
+		return fmt.Errorf("channel is required")
+	}
+	if ticketTailorID == "" {
+		return fmt.Errorf("ticketTailorID is required")
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	merge, err := metadataPatch(values)
 	if err != nil {
 		return err
 	}
+
+	result := conn.WithContext(ctx).
+		Model(&TicketPass{}).
+		Where("channel = ?", channel).
+		Where("ticket_id = (SELECT id FROM tickets WHERE ticket_tailor_id = ?)", ticketTailorID).
+		UpdateColumn("metadata", merge)
+	if result.Error != nil {
+		return fmt.Errorf("updating pass metadata: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPassNotFound
+	}
 	return nil
 }
+
+// mergePassMetadata is SetPassMetadata for a pass already locked inside tx.
+func mergePassMetadata(tx *gorm.DB, passID string, values map[string]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	merge, err := metadataPatch(values)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&TicketPass{}).Where("id = ?", passID).UpdateColumn("metadata", merge).Error; err != nil {
+		return fmt.Errorf("updating pass metadata: %w", err)
+	}
+	return nil
+}
+
+// metadataPatch merges values into the metadata column without replacing the keys it leaves out.
+func metadataPatch(values map[string]any) (clause.Expr, error) {
+	patch, err := json.Marshal(values)
+	if err != nil {
+		return clause.Expr{}, fmt.Errorf("encoding pass metadata: %w", err)
+	}
+	return gorm.Expr("metadata || ?::jsonb", string(patch)), nil
+}
+
+// GetPassStorageKeys maps the Ticket Tailor ID of every stored pass on a channel, voided ones included, to the
+// storage key recorded for it; the key is empty for passes stored before keys were recorded.
+func GetPassStorageKeys(
+	ctx context.Context,
+	conn *gorm.DB,
+	channel PassChannel,
+) (map[string]string, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+
+	type storageRow struct {
+		TicketTailorID string `gorm:"column:ticket_tailor_id"`
+		StorageKey     string `gorm:"column:storage_key"`
+	}
+
+	var rows []storageRow
+	err := conn.WithContext(ctx).
+		Table("ticket_passes").
+		Select("tickets.ticket_tailor_id, COALESCE(ticket_passes.metadata->>'"+PassStorageKeyKey+"', '') AS storage_key").
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
+		Where("ticket_passes.channel = ?", channel).
+		Where("ticket_passes.produced_at IS NOT NULL").
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("listing pass storage keys: %w", err)
+	}
+
+	keys := make(map[string]string, len(rows))
+	for _, r := range rows {
+		keys[r.TicketTailorID] = r.StorageKey
+	}
+	return keys, nil
+}
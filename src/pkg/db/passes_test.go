@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -63,6 +64,121 @@ func TestPassRepositories(t *testing.T) {
 	require.Equal(t, string(Produced), googleRecord.Status)
 }
 
+func TestPassFingerprintMetadata(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_transfer", "buyer@example.com", producedAt))
+
+	const passType = "pass.com.hakuna.integration"
+	_, err := GetOrCreateApplePassAuthToken(ctx, conn, passType, "tt_transfer")
+	require.NoError(t, err)
+
+	records, err := GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Empty(t, records["tt_transfer"].Fingerprint)
+	updatedAt, err := GetApplePassUpdatedAt(ctx, conn, passType, "tt_transfer")
+	require.NoError(t, err)
+
+	require.NoError(t, SetPassMetadata(ctx, conn, AppleWalletChannel, "tt_transfer", map[string]any{"other": "kept"}))
+	require.NoError(t, SetPassMetadata(ctx, conn, AppleWalletChannel, "tt_transfer", map[string]any{PassFingerprintKey: "abc"}))
+	backfilledAt, err := GetApplePassUpdatedAt(ctx, conn, passType, "tt_transfer")
+	require.NoError(t, err)
+	require.True(t, updatedAt.Equal(backfilledAt), "backfilling a fingerprint does not mark the pass changed")
+
+	deliveredAt := producedAt.Add(time.Minute)
+	require.NoError(t, mutatePass(ctx, conn, "tt_transfer", string(AppleWalletChannel), func(pass *TicketPass) {
+		pass.Status = string(Sent)
+		pass.DeliveredAt = &deliveredAt
+	}))
+
+	records, err = GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, "abc", records["tt_transfer"].Fingerprint)
+	require.NotNil(t, records["tt_transfer"].DeliveredAt)
+
+	require.NoError(t, SetPassReissued(ctx, conn, AppleWalletChannel, "tt_transfer", "buyer@example.com", producedAt.Add(time.Hour)))
+	records, err = GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, string(Produced), records["tt_transfer"].Status)
+	require.Nil(t, records["tt_transfer"].DeliveredAt, "re-produced passes must be delivered again")
+
+	var pass TicketPass
+	require.NoError(t, conn.WithContext(ctx).
+		Joins("JOIN tickets ON tickets.id = ticket_passes.ticket_id").
+		Where("tickets.ticket_tailor_id = ?", "tt_transfer").
+		First(&pass).Error)
+	require.Equal(t, "kept", pass.Metadata["other"])
+
+	err = SetPassMetadata(ctx, conn, AppleWalletChannel, "tt_missing", map[string]any{PassFingerprintKey: "x"})
+	require.ErrorIs(t, err, ErrPassNotFound)
+}
+
+func TestSetPassVoided(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SetPassProduced(ctx, conn, AppleWalletChannel, "tt_refunded", "buyer@example.com", producedAt))
+	require.NoError(t, SetPassProduced(ctx, conn, GoogleWalletChannel, "tt_refunded", "buyer@example.com", producedAt))
+
+	voidedAt := producedAt.Add(time.Hour)
+	changed, err := SetPassVoided(ctx, conn, AppleWalletChannel, "tt_refunded", voidedAt)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	changed, err = SetPassVoided(ctx, conn, AppleWalletChannel, "tt_refunded", voidedAt)
+	require.NoError(t, err)
+	require.False(t, changed, "voiding twice is a no-op")
+
+	changed, err = SetPassVoided(ctx, conn, AppleWalletChannel, "tt_unknown", voidedAt)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	appleRecords, err := GetProducedPasses(ctx, conn, AppleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, string(Voided), appleRecords["tt_refunded"].Status, "voided passes stay visible for reinstatement")
+
+	googleRecords, err := GetProducedPasses(ctx, conn, GoogleWalletChannel)
+	require.NoError(t, err)
+	require.Equal(t, string(Produced), googleRecords["tt_refunded"].Status, "voiding is per channel")
+}
+
+func TestSetPassProducedClaim(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	producedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	const workers = 4
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- SetPassProduced(ctx, conn, AppleWalletChannel, "tt_race", "buyer@example.com", producedAt)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	produced := 0
+	for err := range errs {
+		if err == nil {
+			produced++
+			continue
+		}
+		require.ErrorIs(t, err, ErrPassClaimed)
+	}
+	require.Equal(t, 1, produced, "exactly one worker claims a new pass")
+
+	events, err := GetPassEvents(ctx, conn, AppleWalletChannel, "tt_race")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, string(PassProducedEvent), events[0].Event)
+}
+
 func mutatePass(ctx context.Context, conn *gorm.DB, ticketTailorID string, channel string, mutate func(*TicketPass)) error {
 	var pass TicketPass
 	err := conn.WithContext(ctx).
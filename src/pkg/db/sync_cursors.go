@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSyncCursor returns the stored cursor for an event; found is false before the first completed sync.
+func GetSyncCursor(
+	ctx context.Context,
+	conn *gorm.DB,
+	eventID string,
+) (cursor SyncCursor, found bool, err error) {
+	if conn == nil {
+		return SyncCursor{}, false, fmt.Errorf("database connection is required")
+	}
+	if eventID == "" {
+		return SyncCursor{}, false, fmt.Errorf("eventID is required")
+	}
+
+	err = conn.WithContext(ctx).Where("event_id = ?", eventID).First(&cursor).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return SyncCursor{}, false, nil
+	case err != nil:
+		return SyncCursor{}, false, fmt.Errorf("fetching sync cursor: %w", err)
+	}
+	return cursor, true, nil
+}
+
+// SaveSyncCursor upserts the cursor for cursor.EventID.
+func SaveSyncCursor(
+	ctx context.Context,
+	conn *gorm.DB,
+	cursor SyncCursor,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if cursor.EventID == "" {
+		return fmt.Errorf("eventID is required")
+	}
+
+	err := conn.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "event_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_updated_at", "last_ticket_id", "last_full_sync_at", "updated_at"}),
+		}).
+		Create(&cursor).Error
+	if err != nil {
+		return fmt.Errorf("saving sync cursor: %w", err)
+	}
+	return nil
+}
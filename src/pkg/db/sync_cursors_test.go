@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncCursors(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	_, found, err := GetSyncCursor(ctx, conn, "ev_1")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	fullSyncAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, SaveSyncCursor(ctx, conn, SyncCursor{
+		EventID:        "ev_1",
+		LastUpdatedAt:  1760961600,
+		LastTicketID:   "it_1",
+		LastFullSyncAt: &fullSyncAt,
+	}))
+
+	require.NoError(t, SaveSyncCursor(ctx, conn, SyncCursor{
+		EventID:        "ev_1",
+		LastUpdatedAt:  1760965200,
+		LastTicketID:   "it_2",
+		LastFullSyncAt: &fullSyncAt,
+	}))
+
+	cursor, found, err := GetSyncCursor(ctx, conn, "ev_1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(1760965200), cursor.LastUpdatedAt)
+	require.Equal(t, "it_2", cursor.LastTicketID)
+	require.NotNil(t, cursor.LastFullSyncAt)
+	require.True(t, fullSyncAt.Equal(*cursor.LastFullSyncAt))
+}
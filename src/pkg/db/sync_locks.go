@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"gorm.io/gorm"
+)
+
+// ErrSyncLocked is returned when another sync run of the event holds its lock.
+var ErrSyncLocked = errors.New("sync run already in progress")
+
+// SyncLock is a session-level Postgres advisory lock on one event's syncs. Session locks belong to a connection,
+// so the lock pins one from the pool until it is released.
+type SyncLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// AcquireSyncLock takes the sync lock of eventID without waiting, returning ErrSyncLocked when it is held.
+func AcquireSyncLock(ctx context.Context, conn *gorm.DB, eventID string) (*SyncLock, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if eventID == "" {
+		return nil, fmt.Errorf("eventID is required")
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, fmt.Errorf("getting sql db: %w", err)
+	}
+	pinned, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pinning connection: %w", err)
+	}
+
+	key := syncLockKey(eventID)
+	var acquired bool
+	if err := pinned.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		_ = pinned.Close()
+		return nil, fmt.Errorf("taking sync lock: %w", err)
+	}
+	if !acquired {
+		_ = pinned.Close()
+		return nil, ErrSyncLocked
+	}
+	return &SyncLock{conn: pinned, key: key}, nil
+}
+
+// Release unlocks and returns the pinned connection. A connection that could not be unlocked is discarded
+// instead, which ends its session and with it the lock.
+func (l *SyncLock) Release(ctx context.Context) error {
+	var released bool
+	err := l.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", l.key).Scan(&released)
+	if err == nil && !released {
+		err = fmt.Errorf("sync lock was not held")
+	}
+	if err != nil {
+		_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = l.conn.Close()
+		return fmt.Errorf("releasing sync lock: %w", err)
+	}
+	return l.conn.Close()
+}
+
+// syncLockKey hashes an event ID into the 64-bit advisory lock key space.
+func syncLockKey(eventID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("sync_runs:" + eventID))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncLock(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	lock, err := AcquireSyncLock(ctx, conn, "ev_1")
+	require.NoError(t, err)
+
+	_, err = AcquireSyncLock(ctx, conn, "ev_1")
+	require.ErrorIs(t, err, ErrSyncLocked, "a second run of the event is refused")
+
+	other, err := AcquireSyncLock(ctx, conn, "ev_2")
+	require.NoError(t, err, "other events sync independently")
+	require.NoError(t, other.Release(ctx))
+
+	require.NoError(t, lock.Release(ctx))
+	lock, err = AcquireSyncLock(ctx, conn, "ev_1")
+	require.NoError(t, err)
+	require.NoError(t, lock.Release(ctx))
+}
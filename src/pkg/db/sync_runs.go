@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrSyncRunNotFound is returned when no sync run has the requested ID.
+var ErrSyncRunNotFound = errors.New("sync run not found")
+
+type SyncRunStatus string
+
+const (
+	SyncRunRunning   SyncRunStatus = "running"
+	SyncRunSucceeded SyncRunStatus = "succeeded"
+	SyncRunFailed    SyncRunStatus = "failed"
+)
+
+// SyncRunResult is what a finished sync run reports; Err marks the run failed.
+type SyncRunResult struct {
+	Fetched  int
+	New      int
+	Produced int
+	Voided   int
+	Sent     int
+	Failures []SyncFailure
+	Err      error
+}
+
+// StartSyncRun records a sync run as running.
+func StartSyncRun(
+	ctx context.Context,
+	conn *gorm.DB,
+	eventID string,
+	trigger string,
+	startedAt time.Time,
+) (SyncRun, error) {
+	if conn == nil {
+		return SyncRun{}, fmt.Errorf("database connection is required")
+	}
+	if eventID == "" {
+		return SyncRun{}, fmt.Errorf("eventID is required")
+	}
+	if trigger == "" {
+		return SyncRun{}, fmt.Errorf("trigger is required")
+	}
+	if startedAt.IsZero() {
+		return SyncRun{}, fmt.Errorf("startedAt must be set")
+	}
+
+	run := SyncRun{
+		EventID:   eventID,
+		Trigger:   trigger,
+		Status:    string(SyncRunRunning),
+		StartedAt: startedAt,
+		Failures:  datatypes.JSONSlice[SyncFailure]{},
+	}
+	if err := conn.WithContext(ctx).Create(&run).Error; err != nil {
+		return SyncRun{}, fmt.Errorf("creating sync run: %w", err)
+	}
+	return run, nil
+}
+
+// FinishSyncRun stores the counts and outcome of a running sync run.
+func FinishSyncRun(
+	ctx context.Context,
+	conn *gorm.DB,
+	id string,
+	result SyncRunResult,
+	finishedAt time.Time,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if finishedAt.IsZero() {
+		return fmt.Errorf("finishedAt must be set")
+	}
+
+	failures := datatypes.NewJSONSlice(result.Failures)
+	if failures == nil {
+		failures = datatypes.JSONSlice[SyncFailure]{}
+	}
+	values := map[string]any{
+		"status":      string(SyncRunSucceeded),
+		"finished_at": finishedAt,
+		"fetched":     result.Fetched,
+		"new_passes":  result.New,
+		"produced":    result.Produced,
+		"voided":      result.Voided,
+		"failed":      len(result.Failures),
+		"sent":        result.Sent,
+		"error":       nil,
+		"failures":    failures,
+	}
+	if result.Err != nil {
+		values["status"] = string(SyncRunFailed)
+		values["error"] = result.Err.Error()
+	}
+
+	update := conn.WithContext(ctx).
+		Model(&SyncRun{}).
+		Where("id = ? AND status = ?", id, SyncRunRunning).
+		Updates(values)
+	if update.Error != nil {
+		return fmt.Errorf("finishing sync run: %w", update.Error)
+	}
+	if update.RowsAffected == 0 {
+		return ErrSyncRunNotFound
+	}
+	return nil
+}
+
+// ListSyncRuns returns up to limit sync runs, newest first.
+func ListSyncRuns(ctx context.Context, conn *gorm.DB, limit int) ([]SyncRun, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("database connection is required")
+	}
+	if limit < 1 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	var runs []SyncRun
+	err := conn.WithContext(ctx).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	if err != nil {
+		return nil, fmt.Errorf("listing sync runs: %w", err)
+	}
+	return runs, nil
+}
+
+// GetSyncRun returns a sync run by ID.
+func GetSyncRun(ctx context.Context, conn *gorm.DB, id string) (SyncRun, error) {
+	if conn == nil {
+		return SyncRun{}, fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return SyncRun{}, fmt.Errorf("id is required")
+	}
+
+	var run SyncRun
+	err := conn.WithContext(ctx).Where("id = ?", id).First(&run).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return SyncRun{}, ErrSyncRunNotFound
+	case err != nil:
+		return SyncRun{}, fmt.Errorf("fetching sync run: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncRuns(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	startedAt := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	first, err := StartSyncRun(ctx, conn, "ev_1", "cron", startedAt)
+	require.NoError(t, err)
+	require.Equal(t, string(SyncRunRunning), first.Status)
+
+	require.NoError(t, FinishSyncRun(ctx, conn, first.ID, SyncRunResult{
+		Fetched:  3,
+		New:      2,
+		Produced: 1,
+		Sent:     1,
+		Failures: []SyncFailure{{TicketTailorID: "tt_2", Channel: string(AppleWalletChannel), Stage: "store", Error: "access denied"}},
+		Err:      errors.New("1 ticket passes failed"),
+	}, startedAt.Add(time.Minute)))
+	require.ErrorIs(t, FinishSyncRun(ctx, conn, first.ID, SyncRunResult{}, startedAt.Add(2*time.Minute)), ErrSyncRunNotFound, "finished runs are final")
+
+	second, err := StartSyncRun(ctx, conn, "ev_1", "manual", startedAt.Add(5*time.Minute))
+	require.NoError(t, err)
+
+	runs, err := ListSyncRuns(ctx, conn, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.Equal(t, second.ID, runs[0].ID, "newest run first")
+	require.Nil(t, runs[0].FinishedAt)
+
+	run, err := GetSyncRun(ctx, conn, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, string(SyncRunFailed), run.Status)
+	require.Equal(t, 3, run.Fetched)
+	require.Equal(t, 2, run.New)
+	require.Equal(t, 1, run.Failed)
+	require.NotNil(t, run.Error)
+	require.Equal(t, "1 ticket passes failed", *run.Error)
+	require.Len(t, run.Failures, 1)
+	require.Equal(t, "tt_2", run.Failures[0].TicketTailorID)
+
+	_, err = GetSyncRun(ctx, conn, "00000000-0000-0000-0000-000000000000")
+	require.ErrorIs(t, err, ErrSyncRunNotFound)
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordWebhookEvent stores a webhook delivery by ID and reports whether it is the first time it was seen.
+func RecordWebhookEvent(
+	ctx context.Context,
+	conn *gorm.DB,
+	id string,
+	eventType string,
+	ticketTailorID string,
+) (bool, error) {
+	if conn == nil {
+		return false, fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return false, fmt.Errorf("id is required")
+	}
+	if eventType == "" {
+		return false, fmt.Errorf("eventType is required")
+	}
+
+	event := WebhookEvent{ID: id, EventType: eventType}
+	if ticketTailorID != "" {
+		event.TicketTailorID = &ticketTailorID
+	}
+
+	result := conn.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&event)
+	if result.Error != nil {
+		return false, fmt.Errorf("recording webhook event: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ForgetWebhookEvent removes a recorded delivery so a redelivery of the same webhook is processed again.
+func ForgetWebhookEvent(
+	ctx context.Context,
+	conn *gorm.DB,
+	id string,
+) error {
+	if conn == nil {
+		return fmt.Errorf("database connection is required")
+	}
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+
+	if err := conn.WithContext(ctx).Where("id = ?", id).Delete(&WebhookEvent{}).Error; err != nil {
+		return fmt.Errorf("forgetting webhook event: %w", err)
+	}
+	return nil
+}
+
+// WebhookEventStore exposes the webhook deduplication functions over a single connection.
+type WebhookEventStore struct {
+	DB *gorm.DB
+}
+
+// NewWebhookEventStore binds the store to a database connection.
+func NewWebhookEventStore(conn *gorm.DB) WebhookEventStore {
+	return WebhookEventStore{DB: conn}
+}
+
+func (s WebhookEventStore) RecordWebhookEvent(ctx context.Context, id, eventType, ticketTailorID string) (bool, error) {
+	return RecordWebhookEvent(ctx, s.DB, id, eventType, ticketTailorID)
+}
+
+func (s WebhookEventStore) ForgetWebhookEvent(ctx context.Context, id string) error {
+	return ForgetWebhookEvent(ctx, s.DB, id)
+}
@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookEventDeduplication(t *testing.T) {
+	ctx := context.Background()
+	conn := setupTestDatabase(t, ctx)
+
+	first, err := RecordWebhookEvent(ctx, conn, "wh_1", "ISSUED_TICKET.CREATED", "it_1")
+	require.NoError(t, err)
+	require.True(t, first)
+
+	first, err = RecordWebhookEvent(ctx, conn, "wh_1", "ISSUED_TICKET.CREATED", "it_1")
+	require.NoError(t, err)
+	require.False(t, first, "redelivered webhooks are duplicates")
+
+	require.NoError(t, ForgetWebhookEvent(ctx, conn, "wh_1"))
+	first, err = RecordWebhookEvent(ctx, conn, "wh_1", "ISSUED_TICKET.CREATED", "it_1")
+	require.NoError(t, err)
+	require.True(t, first, "forgotten webhooks are processed again")
+}
@@ -0,0 +1,201 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned when the ticket has no stored pass on the channel.
+	ErrNotFound = errors.New("pass download not found")
+	// ErrVoided is returned for passes revoked after their ticket was voided.
+	ErrVoided = errors.New("pass voided")
+)
+
+// Store reads and records presigned download URLs of stored passes.
+type Store interface {
+	GetPassDownload(ctx context.Context, channel db.PassChannel, ticketTailorID string) (db.PassDownload, error)
+	GetExpiringPassDownloads(ctx context.Context, before time.Time, limit int) ([]db.PassDownload, error)
+	SetPassDownloadURL(ctx context.Context, channel db.PassChannel, ticketTailorID string, url string, expiresAt time.Time) error
+}
+
+// Presigner signs a time-limited GET URL for a storage key.
+type Presigner func(ctx context.Context, key string, ttl time.Duration) (string, error)
+
+// Download is a URL the pass can be fetched from until ExpiresAt.
+type Download struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// Resolver hands out current download URLs, presigning again whenever a stored URL is about to expire.
+type Resolver struct {
+	store   Store
+	presign Presigner
+
+	ttl           time.Duration
+	refreshBefore time.Duration
+	batchSize     int
+	now           func() time.Time
+}
+
+// Option overrides a Resolver setting.
+type Option func(*Resolver)
+
+// WithTTL sets how long presigned URLs stay valid. S3 caps SigV4 URLs at 7 days.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithRefreshBefore sets how close to expiry a URL is replaced.
+func WithRefreshBefore(window time.Duration) Option {
+	return func(r *Resolver) {
+		r.refreshBefore = window
+	}
+}
+
+// WithClock swaps the time source.
+func WithClock(now func() time.Time) Option {
+	return func(r *Resolver) {
+		r.now = now
+	}
+}
+
+// NewResolver builds a resolver presigning 7-day URLs and replacing them 2 days before they expire.
+func NewResolver(store Store, presign Presigner, opts ...Option) (*Resolver, error) {
+	if store == nil {
+		return nil, fmt.Errorf("download store is required")
+	}
+	if presign == nil {
+		return nil, fmt.Errorf("presigner is required")
+	}
+
+	r := &Resolver{
+		store:         store,
+		presign:       presign,
+		ttl:           7 * 24 * time.Hour,
+		refreshBefore: 2 * 24 * time.Hour,
+		batchSize:     100,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.ttl <= 0 {
+		return nil, fmt.Errorf("download url ttl must be positive")
+	}
+	if r.refreshBefore < 0 || r.refreshBefore >= r.ttl {
+		return nil, fmt.Errorf("refresh window must be shorter than the download url ttl")
+	}
+	return r, nil
+}
+
+// Locate returns the stored pass of a ticket, or ErrNotFound / ErrVoided when it cannot be downloaded.
+func (r *Resolver) Locate(ctx context.Context, channel db.PassChannel, ticketTailorID string) (db.PassDownload, error) {
+	download, err := r.store.GetPassDownload(ctx, channel, ticketTailorID)
+	if errors.Is(err, db.ErrPassNotFound) {
+		return db.PassDownload{}, ErrNotFound
+	}
+	if err != nil {
+		return db.PassDownload{}, err
+	}
+	if download.Status == string(db.Voided) {
+		return db.PassDownload{}, ErrVoided
+	}
+	if download.StorageKey == "" {
+		return db.PassDownload{}, ErrNotFound
+	}
+	return download, nil
+}
+
+// URL returns a download URL for the ticket's pass that stays valid for at least the refresh window.
+func (r *Resolver) URL(ctx context.Context, channel db.PassChannel, ticketTailorID string) (Download, error) {
+	download, err := r.Locate(ctx, channel, ticketTailorID)
+	if err != nil {
+		return Download{}, err
+	}
+
+	if download.URL != "" && download.ExpiresAt != nil && download.ExpiresAt.After(r.now().Add(r.refreshBefore)) {
+		return Download{URL: download.URL, ExpiresAt: *download.ExpiresAt}, nil
+	}
+	return r.refresh(ctx, download)
+}
+
+// Refresh presigns every URL that is missing or expires within the refresh window, returning how many changed.
+// Failures are logged per pass so one bad key does not hold back the rest.
+func (r *Resolver) Refresh(ctx context.Context) (int, error) {
+	refreshed := 0
+	failed := make(map[string]bool)
+	for {
+		due, err := r.store.GetExpiringPassDownloads(ctx, r.now().Add(r.refreshBefore), r.batchSize)
+		if err != nil {
+			return refreshed, fmt.Errorf("listing expiring downloads: %w", err)
+		}
+
+		progressed := false
+		for _, download := range due {
+			if ctx.Err() != nil {
+				return refreshed, ctx.Err()
+			}
+			id := string(download.Channel) + "/" + download.TicketTailorID
+			if failed[id] {
+				continue
+			}
+			if _, err := r.refresh(ctx, download); err != nil {
+				failed[id] = true
+				logger.Logger.Error(
+					"refreshing pass download url",
+					zap.String("ticket_id", download.TicketTailorID),
+					zap.String("channel", string(download.Channel)),
+					zap.Error(err),
+				)
+				continue
+			}
+			refreshed++
+			progressed = true
+		}
+		if len(due) < r.batchSize || !progressed {
+			return refreshed, nil
+		}
+	}
+}
+
+// Run refreshes expiring URLs every interval until ctx is cancelled.
+func (r *Resolver) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshed, err := r.Refresh(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Logger.Error("refreshing pass download urls", zap.Error(err))
+		} else if refreshed > 0 {
+			logger.Logger.Info("Refreshed pass download urls", zap.Int("count", refreshed))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Resolver) refresh(ctx context.Context, download db.PassDownload) (Download, error) {
+	expiresAt := r.now().Add(r.ttl)
+	url, err := r.presign(ctx, download.StorageKey, r.ttl)
+	if err != nil {
+		return Download{}, fmt.Errorf("presigning %s: %w", download.StorageKey, err)
+	}
+	if err := r.store.SetPassDownloadURL(ctx, download.Channel, download.TicketTailorID, url, expiresAt); err != nil {
+		return Download{}, fmt.Errorf("recording download url: %w", err)
+	}
+	return Download{URL: url, ExpiresAt: expiresAt}, nil
+}
@@ -0,0 +1,145 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+type memoryStore struct {
+	passes map[string]*db.PassDownload
+}
+
+func (m *memoryStore) GetPassDownload(_ context.Context, channel db.PassChannel, ticketTailorID string) (db.PassDownload, error) {
+	pass, ok := m.passes[ticketTailorID]
+	if !ok || pass.Channel != channel {
+		return db.PassDownload{}, db.ErrPassNotFound
+	}
+	return *pass, nil
+}
+
+func (m *memoryStore) GetExpiringPassDownloads(_ context.Context, before time.Time, limit int) ([]db.PassDownload, error) {
+	var due []db.PassDownload
+	for _, pass := range m.passes {
+		if pass.Status == string(db.Voided) || pass.StorageKey == "" {
+			continue
+		}
+		if pass.ExpiresAt == nil || pass.ExpiresAt.Before(before) {
+			due = append(due, *pass)
+		}
+		if len(due) == limit {
+			break
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryStore) SetPassDownloadURL(_ context.Context, _ db.PassChannel, ticketTailorID string, url string, expiresAt time.Time) error {
+	m.passes[ticketTailorID].URL = url
+	m.passes[ticketTailorID].ExpiresAt = &expiresAt
+	return nil
+}
+
+func newTestResolver(t *testing.T, store Store, now time.Time, presigned *int) *Resolver {
+	t.Helper()
+	presign := func(_ context.Context, key string, ttl time.Duration) (string, error) {
+		if key == "broken" {
+			return "", errors.New("access denied")
+		}
+		*presigned++
+		return fmt.Sprintf("https://s3.example.com/%s?ttl=%s&n=%d", key, ttl, *presigned), nil
+	}
+	resolver, err := NewResolver(store, presign, WithTTL(7*24*time.Hour), WithRefreshBefore(48*time.Hour), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	return resolver
+}
+
+func TestResolverURL(t *testing.T) {
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	fresh := now.Add(5 * 24 * time.Hour)
+	stale := now.Add(24 * time.Hour)
+	store := &memoryStore{passes: map[string]*db.PassDownload{
+		"tt_fresh":  {Channel: db.AppleWalletChannel, TicketTailorID: "tt_fresh", StorageKey: "ev/fresh.pkpass", URL: "https://cached", ExpiresAt: &fresh},
+		"tt_stale":  {Channel: db.AppleWalletChannel, TicketTailorID: "tt_stale", StorageKey: "ev/stale.pkpass", URL: "https://old", ExpiresAt: &stale},
+		"tt_voided": {Channel: db.AppleWalletChannel, TicketTailorID: "tt_voided", Status: string(db.Voided), StorageKey: "ev/voided.pkpass"},
+		"tt_legacy": {Channel: db.AppleWalletChannel, TicketTailorID: "tt_legacy"},
+	}}
+	presigned := 0
+	resolver := newTestResolver(t, store, now, &presigned)
+	ctx := context.Background()
+
+	download, err := resolver.URL(ctx, db.AppleWalletChannel, "tt_fresh")
+	if err != nil || download.URL != "https://cached" || presigned != 0 {
+		t.Fatalf("expected cached url without presigning, got %+v (%v), presigned %d", download, err, presigned)
+	}
+
+	download, err = resolver.URL(ctx, db.AppleWalletChannel, "tt_stale")
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	if download.URL == "https://old" || !download.ExpiresAt.Equal(now.Add(7*24*time.Hour)) {
+		t.Fatalf("expected url nearing expiry to be presigned again, got %+v", download)
+	}
+	if store.passes["tt_stale"].URL != download.URL {
+		t.Fatalf("expected refreshed url to be stored")
+	}
+
+	if _, err := resolver.URL(ctx, db.AppleWalletChannel, "tt_voided"); !errors.Is(err, ErrVoided) {
+		t.Fatalf("expected ErrVoided, got %v", err)
+	}
+	if _, err := resolver.URL(ctx, db.AppleWalletChannel, "tt_legacy"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a pass without storage key, got %v", err)
+	}
+	if _, err := resolver.URL(ctx, db.GoogleWalletChannel, "tt_fresh"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another channel, got %v", err)
+	}
+}
+
+func TestResolverRefresh(t *testing.T) {
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	fresh := now.Add(5 * 24 * time.Hour)
+	stale := now.Add(time.Hour)
+	store := &memoryStore{passes: map[string]*db.PassDownload{
+		"tt_fresh":    {Channel: db.AppleWalletChannel, TicketTailorID: "tt_fresh", StorageKey: "ev/fresh.pkpass", URL: "https://cached", ExpiresAt: &fresh},
+		"tt_stale":    {Channel: db.AppleWalletChannel, TicketTailorID: "tt_stale", StorageKey: "ev/stale.pkpass", URL: "https://old", ExpiresAt: &stale},
+		"tt_unsigned": {Channel: db.AppleWalletChannel, TicketTailorID: "tt_unsigned", StorageKey: "ev/unsigned.pkpass"},
+		"tt_broken":   {Channel: db.AppleWalletChannel, TicketTailorID: "tt_broken", StorageKey: "broken"},
+	}}
+	presigned := 0
+	resolver := newTestResolver(t, store, now, &presigned)
+
+	refreshed, err := resolver.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed != 2 {
+		t.Fatalf("expected stale and unsigned urls refreshed, got %d", refreshed)
+	}
+	if store.passes["tt_fresh"].URL != "https://cached" {
+		t.Fatalf("fresh urls must be kept")
+	}
+	if store.passes["tt_unsigned"].URL == "" || store.passes["tt_broken"].URL != "" {
+		t.Fatalf("unexpected refresh result: unsigned %q broken %q", store.passes["tt_unsigned"].URL, store.passes["tt_broken"].URL)
+	}
+}
+
+func TestNewResolverValidatesWindow(t *testing.T) {
+	presign := func(context.Context, string, time.Duration) (string, error) { return "", nil }
+	if _, err := NewResolver(&memoryStore{}, presign, WithTTL(time.Hour), WithRefreshBefore(2*time.Hour)); err == nil {
+		t.Fatalf("expected a refresh window longer than the ttl to be rejected")
+	}
+}
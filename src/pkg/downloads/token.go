@@ -0,0 +1,67 @@
+package downloads
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+)
+
+// tokenMACBytes truncates the HMAC-SHA256 tag to keep links short while staying unguessable.
+const tokenMACBytes = 16
+
+// ErrInvalidToken is returned for malformed or forged download tokens.
+var ErrInvalidToken = errors.New("invalid download token")
+
+// SignToken returns the URL-safe token "<base64 channel:ticket>.<base64 mac>" naming a ticket's pass.
+func SignToken(secret string, channel db.PassChannel, ticketTailorID string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("token secret is required")
+	}
+	if channel == "" || ticketTailorID == "" {
+		return "", fmt.Errorf("channel and ticket id are required")
+	}
+
+	payload := string(channel) + ":" + ticketTailorID
+	return encode([]byte(payload)) + "." + encode(tokenMAC(secret, payload)), nil
+}
+
+// ParseToken verifies a token made by SignToken and returns the pass it names.
+func ParseToken(secret string, token string) (db.PassChannel, string, error) {
+	if secret == "" {
+		return "", "", fmt.Errorf("token secret is required")
+	}
+
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, tokenMAC(secret, string(payload))) {
+		return "", "", ErrInvalidToken
+	}
+
+	channel, ticketTailorID, ok := strings.Cut(string(payload), ":")
+	if !ok || channel == "" || ticketTailorID == "" {
+		return "", "", ErrInvalidToken
+	}
+	return db.PassChannel(channel), ticketTailorID, nil
+}
+
+func tokenMAC(secret string, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)[:tokenMACBytes]
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
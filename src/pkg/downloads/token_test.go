@@ -0,0 +1,57 @@
+package downloads
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	token, err := SignToken("link-secret", db.AppleWalletChannel, "it_42")
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	if strings.ContainsAny(token, "/+=") {
+		t.Fatalf("token %q is not url safe", token)
+	}
+
+	channel, ticketID, err := ParseToken("link-secret", token)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if channel != db.AppleWalletChannel || ticketID != "it_42" {
+		t.Fatalf("ParseToken = %s %s, want apple_wallet it_42", channel, ticketID)
+	}
+}
+
+func TestParseTokenRejectsForgeries(t *testing.T) {
+	token, err := SignToken("link-secret", db.AppleWalletChannel, "it_42")
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	other, err := SignToken("link-secret", db.AppleWalletChannel, "it_43")
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	payload, _, _ := strings.Cut(token, ".")
+	_, mac, _ := strings.Cut(other, ".")
+
+	cases := map[string]struct {
+		secret string
+		token  string
+	}{
+		"wrong secret":    {secret: "other-secret", token: token},
+		"swapped payload": {secret: "link-secret", token: payload + "." + mac},
+		"missing mac":     {secret: "link-secret", token: payload},
+		"not base64":      {secret: "link-secret", token: "!!." + mac},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := ParseToken(tc.secret, tc.token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("ParseToken error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
@@ -1,61 +1,114 @@
 package mailer
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
 	gomail "gopkg.in/gomail.v2"
 )
 
-type MailDialer interface {
-	DialAndSend(...*gomail.Message) error
-}
+// PassContentType is the MIME type Apple Wallet requires for .pkpass attachments.
+const PassContentType = "application/vnd.apple.pkpass"
 
-func NewAppleMailDialer(host string, port int, username string, password string) MailDialer {
-	d := gomail.NewDialer(host, port, username, password)
-	return d
+// AttachmentOpener streams the stored object behind an attachment Key, e.g. from S3.
+type AttachmentOpener func(ctx context.Context, key string) (io.ReadCloser, error)
 
+// MailDialer is the transport that delivers built messages: SMTP, SES or .eml files.
+type MailDialer interface {
+	DialAndSend(ctx context.Context, msgs ...*gomail.Message) error
 }
 
-// SendAppleWalletEmail sends an email with a .pkpass Apple Wallet ticket attached and includes an HTML fallback link.
+// SendAppleWalletEmail sends a rendered ticket email with the in-memory .pkpass Apple Wallet ticket attached as
+// fileName.
 func SendAppleWalletEmail(
+	ctx context.Context,
 	from string,
 	to string,
-	subject string,
+	email Email,
 	dialer MailDialer,
-	pkpassPath string,
+	pass wallet.Artifact,
+	fileName string,
 ) error {
+	contentType := pass.ContentType
+	if contentType == "" {
+		contentType = PassContentType
+	}
+	email.Attachments = []Attachment{{Name: fileName, ContentType: contentType, Data: pass.Data}}
+	message, err := newMessage(ctx, from, to, email, nil)
+	if err != nil {
+		return err
+	}
+	if err := dialer.DialAndSend(ctx, message); err != nil {
+		return fmt.Errorf("failed to send Apple Wallet email: %w", err)
+	}
+
+	return nil
+}
+
+// newMessage builds a plain-text email with an HTML alternative and its attachments. Attachments stored under a
+// Key are streamed through open while the message is written, so they are never staged on local disk.
+func newMessage(ctx context.Context, from string, to string, email Email, open AttachmentOpener) (*gomail.Message, error) {
 	m := gomail.NewMessage()
 
-	// Set headers
 	m.SetHeader("From", from)
 	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-
-	htmlBody := `
-		<html>
-		<body style="font-family: Helvetica, Arial, sans-serif; color: #333; font-size: 16px;">
-			<p>Hi there,</p>
-			<p>Thank you for your purchase! Your event ticket is attached below. You can add it directly to your Apple Wallet.</p>
-			
-			<p>Enjoy the event!<br>- The Team</p>
-		</body>
-		</html>
-	`
-
-	// Set HTML and plain-text alternative
-	m.SetBody("text/html", htmlBody)
-
-	// Attach .pkpass file with correct MIME type
-	m.Attach(pkpassPath, gomail.SetHeader(map[string][]string{
-		"Content-Type":              {"application/vnd.apple.pkpass"},
-		"Content-Disposition":       {fmt.Sprintf(`attachment; filename="%s"`, "ticket.pkpass")},
-		"Content-Transfer-Encoding": {"base64"},
-	}))
-
-	// Send
-	if err := dialer.DialAndSend(m); err != nil {
-		return fmt.Errorf("failed to send Apple Wallet email: %w", err)
+	m.SetHeader("Subject", email.Subject)
+
+	m.SetBody("text/plain", email.Text)
+	if email.HTML != "" {
+		m.AddAlternative("text/html", email.HTML)
 	}
 
-	return nil
+	for _, attachment := range email.Attachments {
+		name := attachment.Name
+		if name == "" {
+			name = "ticket.pkpass"
+		}
+		copyFunc, err := attachmentCopyFunc(ctx, attachment, open)
+		if err != nil {
+			return nil, fmt.Errorf("attaching %s: %w", name, err)
+		}
+		m.Attach(name,
+			gomail.SetCopyFunc(copyFunc),
+			gomail.SetHeader(map[string][]string{
+				"Content-Type":              {attachment.ContentType},
+				"Content-Disposition":       {fmt.Sprintf(`attachment; filename="%s"`, name)},
+				"Content-Transfer-Encoding": {"base64"},
+			}),
+		)
+	}
+
+	return m, nil
+}
+
+func attachmentCopyFunc(ctx context.Context, attachment Attachment, open AttachmentOpener) (func(io.Writer) error, error) {
+	if len(attachment.Data) > 0 {
+		data := attachment.Data
+		return func(w io.Writer) error {
+			_, err := io.Copy(w, bytes.NewReader(data))
+			return err
+		}, nil
+	}
+	if attachment.Key == "" {
+		return nil, fmt.Errorf("attachment has no content")
+	}
+	if open == nil {
+		return nil, fmt.Errorf("no attachment opener for key %s", attachment.Key)
+	}
+
+	key := attachment.Key
+	return func(w io.Writer) error {
+		body, err := open(ctx, key)
+		if err != nil {
+			return fmt.Errorf("opening attachment %s: %w", key, err)
+		}
+		defer body.Close()
+		if _, err := io.Copy(w, body); err != nil {
+			return fmt.Errorf("streaming attachment %s: %w", key, err)
+		}
+		return nil
+	}, nil
 }
@@ -2,12 +2,13 @@ package mailer
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
-	"os"
-	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
 	gomail "gopkg.in/gomail.v2"
 )
 
@@ -15,7 +16,7 @@ type mockDialer struct {
 	messages []*gomail.Message
 }
 
-func (m *mockDialer) DialAndSend(msgs ...*gomail.Message) error {
+func (m *mockDialer) DialAndSend(_ context.Context, msgs ...*gomail.Message) error {
 	m.messages = append(m.messages, msgs...)
 	return nil
 }
@@ -27,15 +28,12 @@ func TestSendAppleWalletEmailBuildsMessage(t *testing.T) {
 		subject = "Your Ticket"
 	)
 
-	tmpDir := t.TempDir()
-	pkpassPath := filepath.Join(tmpDir, "ticket.pkpass")
-	if err := os.WriteFile(pkpassPath, []byte("pkpass content"), 0o600); err != nil {
-		t.Fatalf("write pkpass: %v", err)
-	}
+	pass := wallet.Artifact{Platform: "apple", ContentType: PassContentType, Data: []byte("pkpass content")}
 
 	mock := &mockDialer{}
 
-	if err := SendAppleWalletEmail(from, to, subject, mock, pkpassPath); err != nil {
+	email := Email{Subject: subject, HTML: "<p>Hi Nala</p>", Text: "Hi Nala"}
+	if err := SendAppleWalletEmail(context.Background(), from, to, email, mock, pass, "Nala-Hakuna-it_1.pkpass"); err != nil {
 		t.Fatalf("SendAppleWalletEmail returned error: %v", err)
 	}
 
@@ -63,9 +61,11 @@ func TestSendAppleWalletEmailBuildsMessage(t *testing.T) {
 	rendered := buf.String()
 
 	expectedSnippets := []string{
+		"Content-Type: text/plain",
 		"Content-Type: text/html",
 		"Content-Type: application/vnd.apple.pkpass",
-		`Content-Disposition: attachment; filename="ticket.pkpass"`,
+		`Content-Disposition: attachment; filename="Nala-Hakuna-it_1.pkpass"`,
+		base64.StdEncoding.EncodeToString(pass.Data),
 	}
 	for _, snippet := range expectedSnippets {
 		if !strings.Contains(rendered, snippet) {
@@ -78,19 +78,15 @@ type failingDialer struct {
 	err error
 }
 
-func (f failingDialer) DialAndSend(...*gomail.Message) error {
+func (f failingDialer) DialAndSend(context.Context, ...*gomail.Message) error {
 	return f.err
 }
 
 func TestSendAppleWalletEmailPropagatesDialerError(t *testing.T) {
 	wantErr := "smtp error"
-	tmpDir := t.TempDir()
-	pkpassPath := filepath.Join(tmpDir, "ticket.pkpass")
-	if err := os.WriteFile(pkpassPath, []byte("pkpass content"), 0o600); err != nil {
-		t.Fatalf("write pkpass: %v", err)
-	}
+	pass := wallet.Artifact{Data: []byte("pkpass content")}
 
-	err := SendAppleWalletEmail("from@example.com", "to@example.com", "subject", failingDialer{err: errors.New(wantErr)}, pkpassPath)
+	err := SendAppleWalletEmail(context.Background(), "from@example.com", "to@example.com", Email{Subject: "subject", Text: "body"}, failingDialer{err: errors.New(wantErr)}, pass, "ticket.pkpass")
 	if err == nil || !strings.Contains(err.Error(), wantErr) {
 		t.Fatalf("expected error containing %q, got %v", wantErr, err)
 	}
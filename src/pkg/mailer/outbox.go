@@ -0,0 +1,236 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+	gomail "gopkg.in/gomail.v2"
+)
+
+// OutboxStore claims and settles queued emails.
+type OutboxStore interface {
+	ClaimOutboxEmails(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]db.OutboxEmail, error)
+	MarkOutboxEmailSent(ctx context.Context, id string, sentAt time.Time) error
+	MarkOutboxEmailRetry(ctx context.Context, id string, message string, nextAttemptAt time.Time) error
+	MarkOutboxEmailDead(ctx context.Context, id string, message string, failedAt time.Time) error
+}
+
+// OutboxWorker sends queued emails, retrying failures with exponential backoff and dead-lettering them after
+// MaxAttempts.
+type OutboxWorker struct {
+	store  OutboxStore
+	dialer MailDialer
+	from   string
+	open   AttachmentOpener
+
+	batchSize    int
+	lease        time.Duration
+	pollInterval time.Duration
+	maxAttempts  int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	now          func() time.Time
+}
+
+// OutboxOption overrides an OutboxWorker setting.
+type OutboxOption func(*OutboxWorker)
+
+// WithOutboxMaxAttempts sets how many sends are tried before an email is dead-lettered.
+func WithOutboxMaxAttempts(attempts int) OutboxOption {
+	return func(w *OutboxWorker) {
+		w.maxAttempts = attempts
+	}
+}
+
+// WithOutboxBackoff sets the delay after the first failure and the cap it doubles up to.
+func WithOutboxBackoff(base time.Duration, max time.Duration) OutboxOption {
+	return func(w *OutboxWorker) {
+		w.baseDelay = base
+		w.maxDelay = max
+	}
+}
+
+// WithOutboxPollInterval sets how often Run looks for due emails once the outbox is drained.
+func WithOutboxPollInterval(interval time.Duration) OutboxOption {
+	return func(w *OutboxWorker) {
+		w.pollInterval = interval
+	}
+}
+
+// WithOutboxAttachments sets where attachments queued by storage key are streamed from.
+func WithOutboxAttachments(open AttachmentOpener) OutboxOption {
+	return func(w *OutboxWorker) {
+		w.open = open
+	}
+}
+
+// WithOutboxClock swaps the time source used for claims and retry schedules.
+func WithOutboxClock(now func() time.Time) OutboxOption {
+	return func(w *OutboxWorker) {
+		w.now = now
+	}
+}
+
+// NewOutboxWorker builds a worker that sends from the given address.
+func NewOutboxWorker(store OutboxStore, dialer MailDialer, from string, opts ...OutboxOption) (*OutboxWorker, error) {
+	if store == nil {
+		return nil, fmt.Errorf("outbox store is required")
+	}
+	if dialer == nil {
+		return nil, fmt.Errorf("mail dialer is required")
+	}
+	if from == "" {
+		return nil, fmt.Errorf("from address is required")
+	}
+
+	w := &OutboxWorker{
+		store:        store,
+		dialer:       dialer,
+		from:         from,
+		batchSize:    20,
+		lease:        5 * time.Minute,
+		pollInterval: 10 * time.Second,
+		maxAttempts:  8,
+		baseDelay:    time.Minute,
+		maxDelay:     time.Hour,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.maxAttempts < 1 {
+		w.maxAttempts = 1
+	}
+	return w, nil
+}
+
+// Run sends due emails until ctx is cancelled.
+func (w *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Drain(ctx); err != nil && ctx.Err() == nil {
+			logger.Logger.Error("draining email outbox", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain sends batches until no email is due and returns how many emails it sent, including before an error.
+// Failed emails are rescheduled into the future, so Drain returns once every due email had one attempt.
+func (w *OutboxWorker) Drain(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		processed, sent, err := w.processDue(ctx)
+		total += sent
+		if err != nil {
+			return total, err
+		}
+		if processed == 0 {
+			return total, nil
+		}
+	}
+}
+
+// ProcessDue claims one batch of due emails and attempts each once, returning how many were claimed.
+func (w *OutboxWorker) ProcessDue(ctx context.Context) (int, error) {
+	processed, _, err := w.processDue(ctx)
+	return processed, err
+}
+
+// processDue is ProcessDue that also reports how many of the claimed emails were sent.
+func (w *OutboxWorker) processDue(ctx context.Context) (int, int, error) {
+	emails, err := w.store.ClaimOutboxEmails(ctx, w.now(), w.batchSize, w.lease)
+	if err != nil {
+		return 0, 0, fmt.Errorf("claiming outbox emails: %w", err)
+	}
+
+	sent := 0
+	for _, email := range emails {
+		if ctx.Err() != nil {
+			return len(emails), sent, ctx.Err()
+		}
+		if w.send(ctx, email) {
+			sent++
+		}
+	}
+	return len(emails), sent, nil
+}
+
+// send attempts one email and reports whether it was sent and recorded as sent.
+func (w *OutboxWorker) send(ctx context.Context, email db.OutboxEmail) bool {
+	fields := []zap.Field{
+		zap.String("outbox_id", email.ID),
+		zap.String("kind", email.Kind),
+		zap.Int("attempt", email.Attempts),
+	}
+
+	message, err := w.message(ctx, email)
+	if err == nil {
+		err = w.dialer.DialAndSend(ctx, message)
+	}
+	if err == nil {
+		if err := w.store.MarkOutboxEmailSent(ctx, email.ID, w.now()); err != nil {
+			logger.Logger.Error("marking outbox email sent", append(fields, zap.Error(err))...)
+			return false
+		}
+		logger.Logger.Info("Sent outbox email", fields...)
+		return true
+	}
+
+	if email.Attempts >= w.maxAttempts {
+		logger.Logger.Error("dead-lettering outbox email", append(fields, zap.Error(err))...)
+		if markErr := w.store.MarkOutboxEmailDead(ctx, email.ID, err.Error(), w.now()); markErr != nil {
+			logger.Logger.Error("marking outbox email dead", append(fields, zap.Error(markErr))...)
+		}
+		return false
+	}
+
+	retryAt := w.now().Add(w.backoff(email.Attempts))
+	logger.Logger.Warn("retrying outbox email", append(fields, zap.Time("retry_at", retryAt), zap.Error(err))...)
+	if markErr := w.store.MarkOutboxEmailRetry(ctx, email.ID, err.Error(), retryAt); markErr != nil {
+		logger.Logger.Error("rescheduling outbox email", append(fields, zap.Error(markErr))...)
+	}
+	return false
+}
+
+// backoff returns the delay after failed attempt number attempt (1-based).
+func (w *OutboxWorker) backoff(attempt int) time.Duration {
+	delay := time.Duration(float64(w.baseDelay) * math.Pow(2, float64(attempt-1)))
+	if w.maxDelay > 0 && (delay > w.maxDelay || delay <= 0) {
+		delay = w.maxDelay
+	}
+	return delay
+}
+
+func (w *OutboxWorker) message(ctx context.Context, email db.OutboxEmail) (*gomail.Message, error) {
+	rendered := Email{
+		Subject: email.Subject,
+		Text:    email.TextBody,
+	}
+	if email.HTMLBody != nil {
+		rendered.HTML = *email.HTMLBody
+	}
+	for _, attachment := range email.Attachments {
+		rendered.Attachments = append(rendered.Attachments, Attachment{
+			Name:        attachment.Name,
+			ContentType: PassContentType,
+			Key:         attachment.Key,
+		})
+	}
+
+	if rendered.Text == "" && rendered.HTML == "" {
+		return nil, fmt.Errorf("outbox email has no body")
+	}
+	return newMessage(ctx, w.from, email.Recipient, rendered, w.open)
+}
@@ -0,0 +1,387 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// fakeSMTPServer speaks just enough SMTP for SMTPTransport, recording DATA payloads and optionally rejecting
+// recipients. It offers STARTTLS with a self-signed certificate unless plain is set.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	rootCAs   *x509.CertPool
+
+	mu       sync.Mutex
+	messages []string
+	reject   bool
+	plain    bool
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	certs := httptest.NewUnstartedServer(nil)
+	certs.StartTLS()
+	t.Cleanup(certs.Close)
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(certs.Certificate())
+
+	s := &fakeSMTPServer{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: certs.TLS.Certificates},
+		rootCAs:   rootCAs,
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) dialer(t *testing.T) MailDialer {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split addr: %v", err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("parse port: %v", err)
+	}
+	transport, err := NewSMTPTransport(SMTPConfig{Host: host, Port: portNumber, TLS: SMTPStartTLS})
+	if err != nil {
+		t.Fatalf("NewSMTPTransport: %v", err)
+	}
+	transport.tlsConfig.RootCAs = s.rootCAs
+	return transport
+}
+
+func (s *fakeSMTPServer) setPlain(plain bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plain = plain
+}
+
+func (s *fakeSMTPServer) setReject(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+func (s *fakeSMTPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	s.mu.Lock()
+	offerTLS := !s.plain
+	s.mu.Unlock()
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			if offerTLS {
+				reply("250-fake")
+				reply("250 STARTTLS")
+			} else {
+				reply("250 fake")
+			}
+		case command == "STARTTLS" && offerTLS:
+			reply("220 ready")
+			conn = tls.Server(conn, s.tlsConfig)
+			reader = bufio.NewReader(conn)
+			offerTLS = false
+		case strings.HasPrefix(command, "RCPT"):
+			s.mu.Lock()
+			reject := s.reject
+			s.mu.Unlock()
+			if reject {
+				reply("550 mailbox unavailable")
+			} else {
+				reply("250 OK")
+			}
+		case command == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// memoryOutbox mimics the database outbox: claims lease rows and count attempts.
+type memoryOutbox struct {
+	emails map[string]*db.OutboxEmail
+}
+
+func (m *memoryOutbox) ClaimOutboxEmails(_ context.Context, now time.Time, limit int, lease time.Duration) ([]db.OutboxEmail, error) {
+	var claimed []db.OutboxEmail
+	for _, email := range m.emails {
+		if len(claimed) == limit {
+			break
+		}
+		if email.Status != string(db.OutboxPending) || email.NextAttemptAt.After(now) {
+			continue
+		}
+		email.Attempts++
+		email.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, *email)
+	}
+	return claimed, nil
+}
+
+func (m *memoryOutbox) MarkOutboxEmailSent(_ context.Context, id string, sentAt time.Time) error {
+	m.emails[id].Status = string(db.OutboxSent)
+	m.emails[id].SentAt = &sentAt
+	return nil
+}
+
+func (m *memoryOutbox) MarkOutboxEmailRetry(_ context.Context, id string, message string, nextAttemptAt time.Time) error {
+	m.emails[id].LastError = &message
+	m.emails[id].NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (m *memoryOutbox) MarkOutboxEmailDead(_ context.Context, id string, message string, _ time.Time) error {
+	m.emails[id].Status = string(db.OutboxDead)
+	m.emails[id].LastError = &message
+	return nil
+}
+
+// passObjects serves stored passes by key, standing in for the S3 bucket.
+var passObjects = map[string]string{
+	"ham-2026/apple-wallet/it_1.pkpass": "pkpass content 1",
+	"ham-2026/apple-wallet/it_2.pkpass": "pkpass content 2",
+}
+
+func openPassObject(_ context.Context, key string) (io.ReadCloser, error) {
+	content, ok := passObjects[key]
+	if !ok {
+		return nil, fmt.Errorf("no such key %s", key)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func newOrderEmail(id string, now time.Time) *db.OutboxEmail {
+	return &db.OutboxEmail{
+		ID:        id,
+		Kind:      KindOrderPasses,
+		Recipient: "buyer@example.com",
+		Subject:   "Your tickets",
+		TextBody:  "Hi Nala, your tickets are attached.",
+		Attachments: []db.OutboxAttachment{
+			{Key: "ham-2026/apple-wallet/it_1.pkpass", Name: "Nala-Hakuna-it_1.pkpass"},
+			{Key: "ham-2026/apple-wallet/it_2.pkpass", Name: "Simba-Hakuna-it_2.pkpass"},
+		},
+		Status:        string(db.OutboxPending),
+		NextAttemptAt: now,
+	}
+}
+
+func TestOutboxWorkerSendsDueEmails(t *testing.T) {
+	smtp := newFakeSMTPServer(t)
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	store := &memoryOutbox{emails: map[string]*db.OutboxEmail{"e1": newOrderEmail("e1", now)}}
+
+	worker, err := NewOutboxWorker(
+		store,
+		smtp.dialer(t),
+		"from@example.com",
+		WithOutboxAttachments(openPassObject),
+		WithOutboxClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatalf("NewOutboxWorker: %v", err)
+	}
+	sent, err := worker.Drain(context.Background())
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if sent != 1 {
+		t.Fatalf("Drain sent %d emails, want 1", sent)
+	}
+
+	if store.emails["e1"].Status != string(db.OutboxSent) {
+		t.Fatalf("expected email sent, got %s", store.emails["e1"].Status)
+	}
+	received := smtp.received()
+	if len(received) != 1 || strings.Count(received[0], "Content-Type: application/vnd.apple.pkpass") != 2 {
+		t.Fatalf("expected one email carrying both passes over smtp, got %d", len(received))
+	}
+	for _, name := range []string{"Nala-Hakuna-it_1.pkpass", "Simba-Hakuna-it_2.pkpass"} {
+		if !strings.Contains(received[0], `filename="`+name+`"`) {
+			t.Fatalf("expected attachment %s, got %q", name, received[0])
+		}
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(passObjects["ham-2026/apple-wallet/it_2.pkpass"]))
+	if !strings.Contains(received[0], encoded) {
+		t.Fatalf("expected pass streamed from storage, got %q", received[0])
+	}
+}
+
+func TestOutboxWorkerRetriesUnreadableAttachments(t *testing.T) {
+	smtp := newFakeSMTPServer(t)
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	email := newOrderEmail("e1", now)
+	email.Attachments[1].Key = "ham-2026/apple-wallet/missing.pkpass"
+	store := &memoryOutbox{emails: map[string]*db.OutboxEmail{"e1": email}}
+
+	worker, err := NewOutboxWorker(
+		store,
+		smtp.dialer(t),
+		"from@example.com",
+		WithOutboxAttachments(openPassObject),
+		WithOutboxClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatalf("NewOutboxWorker: %v", err)
+	}
+	if _, err := worker.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	if email.Status != string(db.OutboxPending) || email.LastError == nil || !strings.Contains(*email.LastError, "missing.pkpass") {
+		t.Fatalf("expected retry recording the missing attachment, got status %s error %v", email.Status, email.LastError)
+	}
+}
+
+func TestOutboxWorkerRetriesThenDeadLetters(t *testing.T) {
+	smtp := newFakeSMTPServer(t)
+	smtp.setReject(true)
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	store := &memoryOutbox{emails: map[string]*db.OutboxEmail{"e1": newOrderEmail("e1", now)}}
+
+	worker, err := NewOutboxWorker(
+		store,
+		smtp.dialer(t),
+		"from@example.com",
+		WithOutboxAttachments(openPassObject),
+		WithOutboxMaxAttempts(3),
+		WithOutboxBackoff(time.Minute, 10*time.Minute),
+		WithOutboxClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatalf("NewOutboxWorker: %v", err)
+	}
+
+	wantDelays := []time.Duration{time.Minute, 2 * time.Minute}
+	for _, delay := range wantDelays {
+		if _, err := worker.Drain(context.Background()); err != nil {
+			t.Fatalf("Drain: %v", err)
+		}
+		email := store.emails["e1"]
+		if email.Status != string(db.OutboxPending) || !email.NextAttemptAt.Equal(now.Add(delay)) {
+			t.Fatalf("expected retry after %s, got status %s at %s", delay, email.Status, email.NextAttemptAt)
+		}
+		if email.LastError == nil || !strings.Contains(*email.LastError, "550") {
+			t.Fatalf("expected smtp error recorded, got %v", email.LastError)
+		}
+		now = email.NextAttemptAt
+	}
+
+	sent, err := worker.Drain(context.Background())
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if sent != 0 {
+		t.Fatalf("Drain sent %d emails, want none", sent)
+	}
+	if store.emails["e1"].Status != string(db.OutboxDead) {
+		t.Fatalf("expected email dead-lettered after 3 attempts, got %s", store.emails["e1"].Status)
+	}
+	if len(smtp.received()) != 0 {
+		t.Fatalf("rejected emails must not be delivered")
+	}
+}
+
+func TestOutboxWorkerSendsVoidNotice(t *testing.T) {
+	smtp := newFakeSMTPServer(t)
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	store := &memoryOutbox{emails: map[string]*db.OutboxEmail{"v1": {
+		ID:            "v1",
+		Kind:          KindPassVoided,
+		Recipient:     "buyer@example.com",
+		Subject:       "Ticket cancelled",
+		TextBody:      "Your ticket has been cancelled.",
+		Status:        string(db.OutboxPending),
+		NextAttemptAt: now,
+	}}}
+
+	worker, err := NewOutboxWorker(store, smtp.dialer(t), "from@example.com", WithOutboxClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("NewOutboxWorker: %v", err)
+	}
+	if _, err := worker.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	if store.emails["v1"].Status != string(db.OutboxSent) {
+		t.Fatalf("expected void notice sent, got %s", store.emails["v1"].Status)
+	}
+	received := smtp.received()
+	if len(received) != 1 || !strings.Contains(received[0], "cancelled") || strings.Contains(received[0], "attachment") {
+		t.Fatalf("expected one void notice without attachments, got %q", received)
+	}
+}
@@ -0,0 +1,280 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"unicode"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+)
+
+// Email kinds; each names a template set.
+const (
+	// KindOrderPasses delivers every pass of an order in one email.
+	KindOrderPasses = "order_passes"
+	// KindPassVoided is the notice sent when a ticket is voided and its pass revoked.
+	KindPassVoided = "pass_voided"
+)
+
+// DefaultLocale is used when a requested locale has no templates.
+const DefaultLocale = "en"
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// TemplateData is the ticket information available to email templates. The top-level fields describe the ticket
+// the email is addressed about; Passes lists every pass an order email delivers.
+type TemplateData struct {
+	TicketID   string
+	FullName   string
+	FirstName  string
+	EventName  string
+	TicketType string
+	OrderID    string
+	Passes     []PassLink
+}
+
+// PassLink describes one delivered ticket: an attached Apple pass, a Google save link, or both. DownloadURL is
+// the stable link to the Apple pass, for holders whose mail client drops the attachment.
+type PassLink struct {
+	TicketID    string
+	FullName    string
+	TicketType  string
+	SaveURL     string
+	DownloadURL string
+	Attached    bool
+}
+
+// TemplateDataFromTicket fills template data from a Ticket Tailor ticket; Ticket Tailor puts the ticket type name
+// in the description.
+func TemplateDataFromTicket(ticket tickets.TTIssuedTicket, eventName string) TemplateData {
+	return TemplateData{
+		TicketID:   ticket.ID,
+		FullName:   ticket.FullName,
+		FirstName:  ticket.FirstName,
+		EventName:  eventName,
+		TicketType: ticket.Description,
+		OrderID:    ticket.OrderID,
+	}
+}
+
+// PassLinkFromTicket starts the pass entry of a ticket; callers set Attached and SaveURL per enabled channel.
+func PassLinkFromTicket(ticket tickets.TTIssuedTicket) PassLink {
+	return PassLink{
+		TicketID:   ticket.ID,
+		FullName:   ticket.FullName,
+		TicketType: ticket.Description,
+	}
+}
+
+// Email is a rendered email with optional attachments.
+type Email struct {
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email under Name. Its content is Data when set, otherwise the object
+// stored under Key, streamed through an AttachmentOpener while the message is sent.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Key         string
+}
+
+// Templates renders emails from <locale>/<kind>.{subject,html,txt}.tmpl files. Files in the override directory
+// replace the embedded ones of the same name; anything not overridden keeps the embedded default.
+type Templates struct {
+	files fs.FS
+}
+
+// NewTemplates loads the embedded templates, overridden by files under dir when dir is set.
+func NewTemplates(dir string) (*Templates, error) {
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return &Templates{files: embedded}, nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("email templates dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("email templates dir %s is not a directory", dir)
+	}
+	return &Templates{files: overlayFS{override: os.DirFS(dir), base: embedded}}, nil
+}
+
+// Render renders the kind's templates for locale, falling back from e.g. "es-MX" to "es" and then to DefaultLocale.
+func (t *Templates) Render(kind string, locale string, data TemplateData) (Email, error) {
+	resolved, err := t.resolveLocale(kind, locale)
+	if err != nil {
+		return Email{}, err
+	}
+	prefix := resolved + "/" + kind
+
+	subject, err := t.renderText(prefix+".subject.tmpl", data)
+	if err != nil {
+		return Email{}, err
+	}
+	text, err := t.renderText(prefix+".txt.tmpl", data)
+	if err != nil {
+		return Email{}, err
+	}
+	html, err := t.renderHTML(prefix+".html.tmpl", data)
+	if err != nil {
+		return Email{}, err
+	}
+
+	return Email{
+		Subject: strings.TrimSpace(subject),
+		HTML:    html,
+		Text:    text,
+	}, nil
+}
+
+// localeNames maps language names, as buyers answer a language question, to template locales.
+var localeNames = map[string]string{
+	"english": "en",
+	"inglés":  "en",
+	"ingles":  "en",
+	"spanish": "es",
+	"español": "es",
+	"espanol": "es",
+}
+
+// Locale picks the template locale for a buyer's answer to a language question, which may name the language
+// ("Español", "English") or give a locale such as es-MX. Answers without matching templates use fallback.
+func (t *Templates) Locale(answer string, fallback string) string {
+	locale := strings.ToLower(strings.TrimSpace(answer))
+	if name, ok := localeNames[locale]; ok {
+		locale = name
+	}
+	locale = strings.ReplaceAll(locale, "_", "-")
+
+	base, _, _ := strings.Cut(locale, "-")
+	if base == "" || strings.ContainsAny(base, "./") {
+		return fallback
+	}
+	if info, err := fs.Stat(t.files, base); err != nil || !info.IsDir() {
+		return fallback
+	}
+	return locale
+}
+
+func (t *Templates) resolveLocale(kind string, locale string) (string, error) {
+	candidates := []string{strings.ToLower(locale)}
+	if base, _, found := strings.Cut(candidates[0], "-"); found {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, DefaultLocale)
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if _, err := fs.Stat(t.files, candidate+"/"+kind+".subject.tmpl"); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no email templates for %q", kind)
+}
+
+func (t *Templates) renderText(name string, data TemplateData) (string, error) {
+	tmpl, err := texttemplate.New(name).Option("missingkey=error").ParseFS(t.files, name)
+	if err != nil {
+		return "", fmt.Errorf("parsing email template %s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, lastSegment(name), data); err != nil {
+		return "", fmt.Errorf("rendering email template %s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+func (t *Templates) renderHTML(name string, data TemplateData) (string, error) {
+	tmpl, err := htmltemplate.New(name).Option("missingkey=error").ParseFS(t.files, name)
+	if err != nil {
+		return "", fmt.Errorf("parsing email template %s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, lastSegment(name), data); err != nil {
+		return "", fmt.Errorf("rendering email template %s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// overlayFS serves files from override when present and from base otherwise.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.override.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
+
+// PassFileName names a ticket's .pkpass attachment after its holder and ticket ID, e.g. "Nala-Hakuna-it_123.pkpass",
+// so several passes in one inbox stay distinguishable.
+func PassFileName(pass PassLink) string {
+	var name strings.Builder
+	for _, r := range pass.FullName {
+		if plain, ok := spanishLetters[r]; ok {
+			r = plain
+		}
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			name.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			if name.Len() > 0 && !strings.HasSuffix(name.String(), "-") {
+				name.WriteByte('-')
+			}
+		}
+	}
+	base := strings.Trim(name.String(), "-")
+	if base == "" {
+		base = "ticket"
+	}
+	if pass.TicketID != "" {
+		base += "-" + sanitizeFileSegment(pass.TicketID)
+	}
+	return base + ".pkpass"
+}
+
+// spanishLetters keeps accented holder names readable in ASCII file names.
+var spanishLetters = map[rune]rune{
+	'á': 'a', 'é': 'e', 'í': 'i', 'ó': 'o', 'ú': 'u', 'ü': 'u', 'ñ': 'n',
+	'Á': 'A', 'É': 'E', 'Í': 'I', 'Ó': 'O', 'Ú': 'U', 'Ü': 'U', 'Ñ': 'N',
+}
+
+func sanitizeFileSegment(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-') {
+			return r
+		}
+		return -1
+	}, value)
+}
@@ -0,0 +1,188 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+)
+
+func testTemplateData() TemplateData {
+	ticket := tickets.TTIssuedTicket{
+		ID:          "it_42",
+		FullName:    "Nala Hakuna",
+		FirstName:   "Nala",
+		Description: "VIP",
+		OrderID:     "or_7",
+	}
+	data := TemplateDataFromTicket(ticket, "Hakuna Fest")
+	pass := PassLinkFromTicket(ticket)
+	pass.Attached = true
+	data.Passes = []PassLink{pass}
+	return data
+}
+
+func TestTemplatesRenderLocales(t *testing.T) {
+	templates, err := NewTemplates("")
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+
+	cases := map[string]struct {
+		locale  string
+		subject string
+		text    string
+	}{
+		"english":          {locale: "en", subject: "Your Hakuna Fest ticket is ready", text: "Hi Nala,"},
+		"spanish":          {locale: "es", subject: "Tu boleto para Hakuna Fest está listo", text: "Hola Nala,"},
+		"regional spanish": {locale: "es-MX", subject: "Tu boleto para Hakuna Fest está listo", text: "Pedido or_7"},
+		"unknown locale":   {locale: "fr", subject: "Your Hakuna Fest ticket is ready", text: "Order or_7"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			email, err := templates.Render(KindOrderPasses, tc.locale, testTemplateData())
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if email.Subject != tc.subject {
+				t.Fatalf("subject = %q, want %q", email.Subject, tc.subject)
+			}
+			if !strings.Contains(email.Text, tc.text) {
+				t.Fatalf("expected text part to contain %q, got %q", tc.text, email.Text)
+			}
+			if !strings.Contains(email.HTML, "&middot; VIP") {
+				t.Fatalf("expected ticket type in html part, got %q", email.HTML)
+			}
+		})
+	}
+}
+
+func TestTemplatesEscapeHTML(t *testing.T) {
+	templates, err := NewTemplates("")
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+
+	data := testTemplateData()
+	data.FirstName = `<script>alert("x")</script>`
+	email, err := templates.Render(KindPassVoided, "en", data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(email.HTML, "<script>") {
+		t.Fatalf("expected holder name to be escaped in html, got %q", email.HTML)
+	}
+}
+
+func TestTemplatesOverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "es"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	override := "¡{{.FirstName}}, ya tienes tu pase!"
+	if err := os.WriteFile(filepath.Join(dir, "es", "order_passes.subject.tmpl"), []byte(override), 0o600); err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+
+	templates, err := NewTemplates(dir)
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+	data := testTemplateData()
+	data.Passes[0].SaveURL = "https://pay.google.com/gp/v/save/abc"
+	email, err := templates.Render(KindOrderPasses, "es", data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if email.Subject != "¡Nala, ya tienes tu pase!" {
+		t.Fatalf("expected overridden subject, got %q", email.Subject)
+	}
+	if !strings.Contains(email.Text, data.Passes[0].SaveURL) {
+		t.Fatalf("expected embedded text part with save url, got %q", email.Text)
+	}
+}
+
+func TestTemplatesLocale(t *testing.T) {
+	templates, err := NewTemplates("")
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+
+	cases := map[string]struct {
+		answer string
+		want   string
+	}{
+		"language name":   {answer: "English", want: "en"},
+		"accented name":   {answer: " Español ", want: "es"},
+		"locale tag":      {answer: "es_MX", want: "es-mx"},
+		"no templates":    {answer: "Français", want: "es"},
+		"unknown tag":     {answer: "fr", want: "es"},
+		"path-like input": {answer: "../en", want: "es"},
+		"unanswered":      {answer: "", want: "es"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := templates.Locale(tc.answer, "es"); got != tc.want {
+				t.Fatalf("Locale(%q) = %q, want %q", tc.answer, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPassFileName(t *testing.T) {
+	cases := map[string]struct {
+		pass PassLink
+		want string
+	}{
+		"holder name":   {pass: PassLink{TicketID: "it_42", FullName: "Nala Hakuna"}, want: "Nala-Hakuna-it_42.pkpass"},
+		"accented name": {pass: PassLink{TicketID: "it_43", FullName: "José  Muñoz"}, want: "Jose-Munoz-it_43.pkpass"},
+		"no name":       {pass: PassLink{TicketID: "it_44"}, want: "ticket-it_44.pkpass"},
+		"unsafe input":  {pass: PassLink{TicketID: "../it_45", FullName: `a"b/c`}, want: "abc-it_45.pkpass"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := PassFileName(tc.pass); got != tc.want {
+				t.Fatalf("PassFileName = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTemplatesRenderOrder(t *testing.T) {
+	templates, err := NewTemplates("")
+	if err != nil {
+		t.Fatalf("NewTemplates: %v", err)
+	}
+
+	data := testTemplateData()
+	data.Passes[0].SaveURL = "https://pay.google.com/gp/v/save/nala"
+	data.Passes = append(data.Passes, PassLink{
+		TicketID:    "it_43",
+		FullName:    "Simba Hakuna",
+		TicketType:  "General",
+		DownloadURL: "https://wallet.example.com/passes/simba",
+		Attached:    true,
+	})
+	email, err := templates.Render(KindOrderPasses, "en", data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if email.Subject != "Your Hakuna Fest tickets are ready" {
+		t.Fatalf("unexpected subject %q", email.Subject)
+	}
+	for _, want := range []string{
+		"Nala Hakuna (VIP)",
+		"Simba Hakuna (General)",
+		"Save to Google Wallet: https://pay.google.com/gp/v/save/nala",
+		"Download Apple Wallet pass: https://wallet.example.com/passes/simba",
+	} {
+		if !strings.Contains(email.Text, want) {
+			t.Fatalf("expected text part to contain %q, got %q", want, email.Text)
+		}
+	}
+	if !strings.Contains(email.HTML, `href="https://pay.google.com/gp/v/save/nala"`) {
+		t.Fatalf("expected save link in html part, got %q", email.HTML)
+	}
+}
@@ -0,0 +1,240 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	gomail "gopkg.in/gomail.v2"
+)
+
+// Supported mail transports.
+const (
+	TransportSMTP = "smtp"
+	TransportSES  = "ses"
+	TransportFile = "file"
+)
+
+// Supported values of SMTPConfig.TLS.
+const (
+	// SMTPStartTLS upgrades a plain connection with STARTTLS and fails when the server does not offer it (port 587).
+	SMTPStartTLS = "starttls"
+	// SMTPImplicitTLS opens the connection over TLS (port 465).
+	SMTPImplicitTLS = "tls"
+)
+
+// SMTPConfig configures a generic SMTP relay.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+}
+
+// smtpDialTimeout bounds connecting to the relay, like gomail's dialer did.
+const smtpDialTimeout = 10 * time.Second
+
+// SMTPTransport sends mail through an SMTP relay. In SMTPStartTLS mode a server that does not offer STARTTLS is
+// refused rather than spoken to in cleartext, so credentials and messages are always encrypted.
+type SMTPTransport struct {
+	cfg       SMTPConfig
+	tlsConfig *tls.Config
+}
+
+// NewSMTPTransport sends mail through an SMTP relay.
+func NewSMTPTransport(cfg SMTPConfig) (*SMTPTransport, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+	if cfg.Port <= 0 {
+		return nil, fmt.Errorf("smtp port is required")
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = SMTPStartTLS
+	case SMTPStartTLS, SMTPImplicitTLS:
+	default:
+		return nil, fmt.Errorf("unsupported smtp tls mode %q", cfg.TLS)
+	}
+	return &SMTPTransport{
+		cfg:       cfg,
+		tlsConfig: &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12},
+	}, nil
+}
+
+// DialAndSend opens one connection, secures and authenticates it, and sends every message over it. Cancelling ctx
+// aborts the connection.
+func (t *SMTPTransport) DialAndSend(ctx context.Context, msgs ...*gomail.Message) error {
+	client, conn, err := t.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	send := gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		if err := client.Mail(from); err != nil {
+			return err
+		}
+		for _, rcpt := range to {
+			if err := client.Rcpt(rcpt); err != nil {
+				return err
+			}
+		}
+		w, err := client.Data()
+		if err != nil {
+			return err
+		}
+		if _, err := msg.WriteTo(w); err != nil {
+			_ = w.Close()
+			return err
+		}
+		return w.Close()
+	})
+	if err := gomail.Send(send, msgs...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return client.Quit()
+}
+
+func (t *SMTPTransport) dial(ctx context.Context) (*smtp.Client, net.Conn, error) {
+	addr := net.JoinHostPort(t.cfg.Host, strconv.Itoa(t.cfg.Port))
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	var conn net.Conn
+	var err error
+	if t.cfg.TLS == SMTPImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: t.tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to smtp server %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, t.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("greeting smtp server %s: %w", addr, err)
+	}
+	if t.cfg.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			_ = client.Close()
+			return nil, nil, fmt.Errorf("smtp server %s does not offer STARTTLS", addr)
+		}
+		if err := client.StartTLS(t.tlsConfig); err != nil {
+			_ = client.Close()
+			return nil, nil, fmt.Errorf("starting tls with %s: %w", addr, err)
+		}
+	}
+	if t.cfg.Username != "" {
+		auth := smtp.PlainAuth("", t.cfg.Username, t.cfg.Password, t.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			_ = client.Close()
+			return nil, nil, fmt.Errorf("authenticating with %s: %w", addr, err)
+		}
+	}
+	return client, conn, nil
+}
+
+// SESTransport sends mail as raw MIME through the Amazon SES v2 API.
+type SESTransport struct {
+	client *sesv2.Client
+}
+
+// NewSESTransport builds an SES transport from an AWS config. A non-empty endpoint replaces the regional SES
+// endpoint, e.g. to point at a local stand-in.
+func NewSESTransport(cfg aws.Config, endpoint string) *SESTransport {
+	client := sesv2.NewFromConfig(cfg, func(o *sesv2.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &SESTransport{client: client}
+}
+
+// DialAndSend sends each message with its own SendEmail call, bounded by ctx.
+func (t *SESTransport) DialAndSend(ctx context.Context, msgs ...*gomail.Message) error {
+	for _, m := range msgs {
+		var raw bytes.Buffer
+		if _, err := m.WriteTo(&raw); err != nil {
+			return fmt.Errorf("encoding message: %w", err)
+		}
+
+		input := &sesv2.SendEmailInput{
+			Destination: &types.Destination{
+				ToAddresses: m.GetHeader("To"),
+				CcAddresses: m.GetHeader("Cc"),
+			},
+			Content: &types.EmailContent{Raw: &types.RawMessage{Data: raw.Bytes()}},
+		}
+		if from := m.GetHeader("From"); len(from) > 0 {
+			input.FromEmailAddress = aws.String(from[0])
+		}
+
+		if _, err := t.client.SendEmail(ctx, input); err != nil {
+			return fmt.Errorf("sending email through ses: %w", err)
+		}
+	}
+	return nil
+}
+
+// FileTransport writes every message as an .eml file instead of sending it, for local previews and tests.
+type FileTransport struct {
+	Dir string
+}
+
+// NewFileTransport writes messages into dir, creating it when missing.
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("mail directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating mail directory: %w", err)
+	}
+	return &FileTransport{Dir: dir}, nil
+}
+
+// DialAndSend writes each message to <timestamp>-<random>.eml so files sort in send order.
+func (t *FileTransport) DialAndSend(ctx context.Context, msgs ...*gomail.Message) error {
+	for _, m := range msgs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		prefix := time.Now().UTC().Format("20060102T150405.000000000")
+		file, err := os.CreateTemp(t.Dir, prefix+"-*.eml")
+		if err != nil {
+			return fmt.Errorf("creating eml file: %w", err)
+		}
+		_, err = m.WriteTo(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(file.Name())
+			return fmt.Errorf("writing %s: %w", filepath.Base(file.Name()), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package mailer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func newTestMessage(t *testing.T) Email {
+	t.Helper()
+	return Email{
+		Subject:     "Your tickets",
+		Text:        "Hi Nala",
+		HTML:        "<p>Hi Nala</p>",
+		Attachments: []Attachment{{Name: "Nala-Hakuna-it_1.pkpass", ContentType: PassContentType, Data: []byte("pkpass content")}},
+	}
+}
+
+func TestFileTransportWritesEml(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	transport, err := NewFileTransport(dir)
+	if err != nil {
+		t.Fatalf("NewFileTransport: %v", err)
+	}
+
+	message, err := newMessage(context.Background(), "from@example.com", "to@example.com", newTestMessage(t), nil)
+	if err != nil {
+		t.Fatalf("newMessage: %v", err)
+	}
+	if err := transport.DialAndSend(context.Background(), message, message); err != nil {
+		t.Fatalf("DialAndSend: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 eml files, got %v (%v)", files, err)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read eml: %v", err)
+	}
+	for _, snippet := range []string{"To: to@example.com", "Subject: Your tickets", `filename="Nala-Hakuna-it_1.pkpass"`} {
+		if !strings.Contains(string(content), snippet) {
+			t.Fatalf("expected eml to contain %q, got %q", snippet, content)
+		}
+	}
+}
+
+func TestSESTransportSendsRawMessage(t *testing.T) {
+	var request struct {
+		FromEmailAddress string
+		Destination      struct{ ToAddresses []string }
+		Content          struct{ Raw struct{ Data string } }
+	}
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decode ses request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"MessageId":"ses-1"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	}
+	transport := NewSESTransport(cfg, server.URL)
+
+	message, err := newMessage(context.Background(), "from@example.com", "to@example.com", newTestMessage(t), nil)
+	if err != nil {
+		t.Fatalf("newMessage: %v", err)
+	}
+	if err := transport.DialAndSend(context.Background(), message); err != nil {
+		t.Fatalf("DialAndSend: %v", err)
+	}
+
+	if path != "/v2/email/outbound-emails" {
+		t.Fatalf("unexpected ses path %q", path)
+	}
+	if request.FromEmailAddress != "from@example.com" || len(request.Destination.ToAddresses) != 1 || request.Destination.ToAddresses[0] != "to@example.com" {
+		t.Fatalf("unexpected ses envelope: %+v", request)
+	}
+	raw, err := base64.StdEncoding.DecodeString(request.Content.Raw.Data)
+	if err != nil {
+		t.Fatalf("decode raw message: %v", err)
+	}
+	if !strings.Contains(string(raw), `filename="Nala-Hakuna-it_1.pkpass"`) {
+		t.Fatalf("expected raw message with pass attachment, got %q", raw)
+	}
+}
+
+func TestNewSMTPTransportValidatesConfig(t *testing.T) {
+	if _, err := NewSMTPTransport(SMTPConfig{Port: 587}); err == nil {
+		t.Fatalf("expected missing host to fail")
+	}
+	if _, err := NewSMTPTransport(SMTPConfig{Host: "smtp.example.com", Port: 587, TLS: "ssl3"}); err == nil {
+		t.Fatalf("expected unknown tls mode to fail")
+	}
+	if _, err := NewSMTPTransport(SMTPConfig{Host: "smtp.example.com", Port: 465, TLS: SMTPImplicitTLS}); err != nil {
+		t.Fatalf("NewSMTPTransport: %v", err)
+	}
+}
+
+func TestSMTPTransportRequiresStartTLS(t *testing.T) {
+	message, err := newMessage(context.Background(), "from@example.com", "to@example.com", newTestMessage(t), nil)
+	if err != nil {
+		t.Fatalf("newMessage: %v", err)
+	}
+
+	server := newFakeSMTPServer(t)
+	if err := server.dialer(t).DialAndSend(context.Background(), message); err != nil {
+		t.Fatalf("DialAndSend over STARTTLS: %v", err)
+	}
+	if len(server.received()) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(server.received()))
+	}
+
+	plain := newFakeSMTPServer(t)
+	plain.setPlain(true)
+	err = plain.dialer(t).DialAndSend(context.Background(), message)
+	if err == nil || !strings.Contains(err.Error(), "does not offer STARTTLS") {
+		t.Fatalf("expected a server without STARTTLS to be refused, got %v", err)
+	}
+	if len(plain.received()) != 0 {
+		t.Fatalf("expected nothing sent in cleartext, got %d messages", len(plain.received()))
+	}
+}
+
+func TestSESTransportHonorsContext(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"MessageId":"ses-1"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	}
+	message, err := newMessage(context.Background(), "from@example.com", "to@example.com", newTestMessage(t), nil)
+	if err != nil {
+		t.Fatalf("newMessage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewSESTransport(cfg, server.URL).DialAndSend(ctx, message); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled send to fail with context.Canceled, got %v", err)
+	}
+	if called {
+		t.Fatalf("expected no ses request after cancellation")
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/downloads"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/mailer"
+	"go.uber.org/zap"
+)
+
+const passDownloadPath = "/passes/{token}"
+
+// PassLocator finds a stored pass and its current presigned URL; voided passes report downloads.ErrVoided.
+type PassLocator interface {
+	Locate(ctx context.Context, channel db.PassChannel, ticketTailorID string) (db.PassDownload, error)
+	URL(ctx context.Context, channel db.PassChannel, ticketTailorID string) (downloads.Download, error)
+}
+
+// PassOpener streams a stored pass by its storage key.
+type PassOpener func(ctx context.Context, key string) (io.ReadCloser, error)
+
+// PassDownloads serves the stable pass links sent in emails. Tokens are signed ticket IDs and never expire;
+// voiding the ticket revokes the link.
+type PassDownloads struct {
+	Secret string
+	Passes PassLocator
+	// Open streams Apple passes through the service when set; otherwise every link redirects to S3.
+	Open PassOpener
+}
+
+// NewPassDownloads wires the link handler; a nil opener redirects to presigned URLs.
+func NewPassDownloads(secret string, passes PassLocator, open PassOpener) *PassDownloads {
+	return &PassDownloads{Secret: secret, Passes: passes, Open: open}
+}
+
+// ServeHTTP answers 404 for forged tokens and unknown passes and 410 for voided ones, so a revoked link does not
+// look like a typo.
+func (h *PassDownloads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	channel, ticketID, err := downloads.ParseToken(h.Secret, r.PathValue("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.Open != nil && channel == db.AppleWalletChannel {
+		h.stream(w, r, channel, ticketID)
+		return
+	}
+
+	download, err := h.Passes.URL(r.Context(), channel, ticketID)
+	if err != nil {
+		h.fail(w, r, ticketID, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, download.URL, http.StatusFound)
+}
+
+func (h *PassDownloads) stream(w http.ResponseWriter, r *http.Request, channel db.PassChannel, ticketID string) {
+	pass, err := h.Passes.Locate(r.Context(), channel, ticketID)
+	if err != nil {
+		h.fail(w, r, ticketID, err)
+		return
+	}
+
+	body, err := h.Open(r.Context(), pass.StorageKey)
+	if err != nil {
+		h.fail(w, r, ticketID, err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", mailer.PassContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Logger.Warn("streaming pass download", zap.String("ticket_id", ticketID), zap.Error(err))
+	}
+}
+
+func (h *PassDownloads) fail(w http.ResponseWriter, r *http.Request, ticketID string, err error) {
+	switch {
+	case errors.Is(err, downloads.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, downloads.ErrVoided):
+		http.Error(w, "this pass has been revoked", http.StatusGone)
+	default:
+		logger.Logger.Error("serving pass download", zap.String("ticket_id", ticketID), zap.Error(err))
+		http.Error(w, "pass download unavailable", http.StatusInternalServerError)
+	}
+}
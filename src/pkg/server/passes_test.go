@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/downloads"
+	"github.com/atunbetun/hakuna-wallet/pkg/mailer"
+)
+
+const testPassLinkSecret = "link-secret"
+
+type fakePassLocator struct {
+	passes map[string]db.PassDownload
+}
+
+func (f fakePassLocator) Locate(_ context.Context, channel db.PassChannel, ticketTailorID string) (db.PassDownload, error) {
+	pass, ok := f.passes[ticketTailorID]
+	if !ok || pass.Channel != channel {
+		return db.PassDownload{}, downloads.ErrNotFound
+	}
+	if pass.Status == string(db.Voided) {
+		return db.PassDownload{}, downloads.ErrVoided
+	}
+	return pass, nil
+}
+
+func (f fakePassLocator) URL(ctx context.Context, channel db.PassChannel, ticketTailorID string) (downloads.Download, error) {
+	pass, err := f.Locate(ctx, channel, ticketTailorID)
+	if err != nil {
+		return downloads.Download{}, err
+	}
+	return downloads.Download{URL: "https://bucket.example.com/" + pass.StorageKey + "?signed"}, nil
+}
+
+func newTestPassLocator() fakePassLocator {
+	return fakePassLocator{passes: map[string]db.PassDownload{
+		"it_1": {Channel: db.AppleWalletChannel, TicketTailorID: "it_1", Status: string(db.Produced), StorageKey: "ev_1/apple-wallet/it_1.pkpass"},
+		"it_2": {Channel: db.AppleWalletChannel, TicketTailorID: "it_2", Status: string(db.Voided), StorageKey: "ev_1/apple-wallet/it_2.pkpass"},
+	}}
+}
+
+func getPass(t *testing.T, handler http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("GET "+passDownloadPath, handler)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/passes/"+token, nil))
+	return rec
+}
+
+func signPass(t *testing.T, ticketID string) string {
+	t.Helper()
+	token, err := downloads.SignToken(testPassLinkSecret, db.AppleWalletChannel, ticketID)
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	return token
+}
+
+func TestPassDownloadsRedirects(t *testing.T) {
+	handler := NewPassDownloads(testPassLinkSecret, newTestPassLocator(), nil)
+
+	rec := getPass(t, handler, signPass(t, "it_1"))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", rec.Code)
+	}
+	if location := rec.Header().Get("Location"); location != "https://bucket.example.com/ev_1/apple-wallet/it_1.pkpass?signed" {
+		t.Fatalf("unexpected redirect %q", location)
+	}
+}
+
+func TestPassDownloadsStreamsApplePasses(t *testing.T) {
+	var opened string
+	open := func(_ context.Context, key string) (io.ReadCloser, error) {
+		opened = key
+		return io.NopCloser(strings.NewReader("pkpass content")), nil
+	}
+	handler := NewPassDownloads(testPassLinkSecret, newTestPassLocator(), open)
+
+	rec := getPass(t, handler, signPass(t, "it_1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if opened != "ev_1/apple-wallet/it_1.pkpass" {
+		t.Fatalf("opened %q", opened)
+	}
+	if rec.Header().Get("Content-Type") != mailer.PassContentType || rec.Body.String() != "pkpass content" {
+		t.Fatalf("unexpected response %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestPassDownloadsRejectsUnusableLinks(t *testing.T) {
+	failing := func(context.Context, string) (io.ReadCloser, error) {
+		return nil, errors.New("bucket unavailable")
+	}
+
+	cases := map[string]struct {
+		token string
+		open  PassOpener
+		want  int
+	}{
+		"forged token":   {token: signPass(t, "it_1") + "x", want: http.StatusNotFound},
+		"unknown ticket": {token: signPass(t, "it_9"), want: http.StatusNotFound},
+		"voided ticket":  {token: signPass(t, "it_2"), want: http.StatusGone},
+		"voided stream":  {token: signPass(t, "it_2"), open: failing, want: http.StatusGone},
+		"storage error":  {token: signPass(t, "it_1"), open: failing, want: http.StatusInternalServerError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := NewPassDownloads(testPassLinkSecret, newTestPassLocator(), tc.open)
+			if rec := getPass(t, handler, tc.token); rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
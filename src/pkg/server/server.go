@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg"
+	"github.com/atunbetun/hakuna-wallet/pkg/batch"
+	"github.com/atunbetun/hakuna-wallet/pkg/db"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet/apple"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	applePrefix     = "/apple"
+	shutdownTimeout = 10 * time.Second
+)
+
+// Serve runs the wallet HTTP endpoints on the configured port until ctx is cancelled.
+func Serve(ctx context.Context, cfg pkg.AppConfig) error {
+	databaseCfg, err := db.FromAppConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Open(ctx, databaseCfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(conn); err != nil {
+			logger.Logger.Error("closing database", zap.Error(err))
+		}
+	}()
+
+	handler, err := NewHandler(ctx, cfg, conn)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              net.JoinHostPort("", cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Logger.Error("shutting down http server", zap.Error(err))
+		}
+	}()
+
+	logger.Logger.Info("Listening", zap.String("addr", srv.Addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving http: %w", err)
+	}
+	return nil
+}
+
+// NewHandler mounts every HTTP surface of the service on a single mux. Background workers started for the
+// handler stop when ctx is cancelled.
+func NewHandler(ctx context.Context, cfg pkg.AppConfig, conn *gorm.DB) (http.Handler, error) {
+	ticketCfg, err := tickets.NewTicketTailorConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := batch.NewApplePassCreator(cfg, batch.EmbeddedAppleGenerator, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	ticketClient, err := tickets.NewClient(ticketCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(ctx context.Context, serialNumber string) (tickets.TTIssuedTicket, error) {
+		return ticketClient.FetchIssuedTicket(ctx, serialNumber)
+	}
+	webService := apple.NewWebService(cfg.ApplePassTypeID, db.NewApplePassRegistry(conn), creator, lookup)
+
+	mux := http.NewServeMux()
+	mux.Handle(applePrefix+"/", http.StripPrefix(applePrefix, webService.Handler()))
+	if cfg.TicketTailorWebhookSecret != "" {
+		webhook, err := newTicketTailorWebhook(ctx, cfg, conn)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle("POST "+ticketTailorWebhookPath, webhook)
+	}
+	if batch.HasArtifactSink(cfg, batch.S3ArtifactSink) {
+		resolver, err := batch.NewDownloadResolver(ctx, cfg, conn)
+		if err != nil {
+			return nil, err
+		}
+		go resolver.Run(ctx, cfg.DownloadURLRefreshInterval)
+
+		if cfg.PassLinkSecret != "" {
+			open, err := batch.NewPassOpener(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			mux.Handle("GET "+passDownloadPath, NewPassDownloads(cfg.PassLinkSecret, resolver, open))
+		}
+	}
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux, nil
+}
+
+// newTicketTailorWebhook starts the single-ticket sync worker, and the outbox worker sending the emails it queues,
+// and returns the receiver feeding them.
+func newTicketTailorWebhook(ctx context.Context, cfg pkg.AppConfig, conn *gorm.DB) (*TicketTailorWebhook, error) {
+	syncer, err := batch.NewWalletTicketSyncer(ctx, cfg, conn)
+	if err != nil {
+		return nil, fmt.Errorf("building webhook ticket syncer: %w", err)
+	}
+
+	queue := batch.NewSyncerTicketQueue(cfg.WebhookQueueSize, syncer)
+	go queue.Run(ctx)
+
+	if batch.OutboxEnabled(cfg) {
+		outbox, err := batch.NewOutboxWorker(ctx, cfg, conn)
+		if err != nil {
+			return nil, err
+		}
+		go outbox.Run(ctx)
+	}
+
+	return NewTicketTailorWebhook(cfg.TicketTailorWebhookSecret, db.NewWebhookEventStore(conn), queue.Enqueue), nil
+}
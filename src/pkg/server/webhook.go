@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"go.uber.org/zap"
+)
+
+const (
+	ticketTailorWebhookPath = "/webhooks/tickettailor"
+	maxWebhookBodyBytes     = 1 << 20
+)
+
+// WebhookEventLog deduplicates webhook deliveries by their Ticket Tailor webhook ID.
+type WebhookEventLog interface {
+	RecordWebhookEvent(ctx context.Context, id, eventType, ticketTailorID string) (bool, error)
+	ForgetWebhookEvent(ctx context.Context, id string) error
+}
+
+// TicketEnqueuer schedules a single ticket for sync and reports false when it cannot take more work.
+type TicketEnqueuer func(ticketID string) bool
+
+// TicketTailorWebhook accepts signed Ticket Tailor webhooks and queues the affected ticket for sync.
+type TicketTailorWebhook struct {
+	Secret    string
+	Tolerance time.Duration
+	Events    WebhookEventLog
+	Enqueue   TicketEnqueuer
+	Now       func() time.Time
+}
+
+// NewTicketTailorWebhook wires the receiver with the default replay tolerance.
+func NewTicketTailorWebhook(secret string, events WebhookEventLog, enqueue TicketEnqueuer) *TicketTailorWebhook {
+	return &TicketTailorWebhook{
+		Secret:    secret,
+		Tolerance: tickets.DefaultWebhookTolerance,
+		Events:    events,
+		Enqueue:   enqueue,
+		Now:       time.Now,
+	}
+}
+
+// ServeHTTP acknowledges verified deliveries once the ticket is queued. Duplicates and non-ticket events are
+// acknowledged without work; a full queue answers 503 so Ticket Tailor redelivers later.
+func (h *TicketTailorWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	err = tickets.VerifyWebhookSignature(h.Secret, r.Header.Get(tickets.WebhookSignatureHeader), body, h.Now(), h.Tolerance)
+	if err != nil {
+		logger.Logger.Warn("rejected ticket tailor webhook", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event tickets.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.Event == "" {
+		http.Error(w, "invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	ticket, ok, err := event.IssuedTicket()
+	if err != nil {
+		http.Error(w, "invalid issued ticket payload", http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		logger.Logger.Debug("ignoring ticket tailor webhook", zap.String("event", event.Event))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	first, err := h.Events.RecordWebhookEvent(r.Context(), event.ID, event.Event, ticket.ID)
+	if err != nil {
+		h.internalError(w, "recording webhook event", err, zap.String("webhook_id", event.ID))
+		return
+	}
+	if !first {
+		logger.Logger.Debug("duplicate ticket tailor webhook", zap.String("webhook_id", event.ID))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !h.Enqueue(ticket.ID) {
+		// Forget the delivery so the redelivery is not mistaken for a duplicate.
+		if err := h.Events.ForgetWebhookEvent(context.WithoutCancel(r.Context()), event.ID); err != nil {
+			logger.Logger.Error("forgetting webhook event", zap.String("webhook_id", event.ID), zap.Error(err))
+		}
+		logger.Logger.Warn("webhook ticket queue is full", zap.String("ticket_id", ticket.ID))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	logger.Logger.Info(
+		"queued ticket tailor webhook",
+		zap.String("webhook_id", event.ID),
+		zap.String("event", event.Event),
+		zap.String("ticket_id", ticket.ID),
+	)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TicketTailorWebhook) internalError(w http.ResponseWriter, action string, err error, fields ...zap.Field) {
+	logger.Logger.Error("ticket tailor webhook "+action, append(fields, zap.Error(err))...)
+	w.WriteHeader(http.StatusInternalServerError)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"go.uber.org/zap"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+type fakeWebhookEvents struct {
+	seen map[string]string
+}
+
+func (f *fakeWebhookEvents) RecordWebhookEvent(_ context.Context, id, _, ticketTailorID string) (bool, error) {
+	if _, ok := f.seen[id]; ok {
+		return false, nil
+	}
+	f.seen[id] = ticketTailorID
+	return true, nil
+}
+
+func (f *fakeWebhookEvents) ForgetWebhookEvent(_ context.Context, id string) error {
+	delete(f.seen, id)
+	return nil
+}
+
+func newTestWebhook(queueSize int) (*TicketTailorWebhook, *fakeWebhookEvents, *[]string) {
+	events := &fakeWebhookEvents{seen: map[string]string{}}
+	var queued []string
+	enqueue := func(ticketID string) bool {
+		if len(queued) >= queueSize {
+			return false
+		}
+		queued = append(queued, ticketID)
+		return true
+	}
+	webhook := NewTicketTailorWebhook(testWebhookSecret, events, enqueue)
+	webhook.Now = func() time.Time { return time.Unix(1760961600, 0) }
+	return webhook, events, &queued
+}
+
+func deliver(t *testing.T, webhook *TicketTailorWebhook, body string, signature string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, ticketTailorWebhookPath, strings.NewReader(body))
+	if signature == "" {
+		timestamp := fmt.Sprint(webhook.Now().Unix())
+		signature = fmt.Sprintf("t=%s,v1=%s", timestamp, tickets.SignWebhook(testWebhookSecret, timestamp, []byte(body)))
+	}
+	req.Header.Set(tickets.WebhookSignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	webhook.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestTicketTailorWebhookQueuesTicketOnce(t *testing.T) {
+	webhook, _, queued := newTestWebhook(10)
+	body := `{"id":"wh_1","event":"ISSUED_TICKET.CREATED","payload":{"object":"issued_ticket","id":"it_1","status":"valid"}}`
+
+	if code := deliver(t, webhook, body, ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := deliver(t, webhook, body, ""); code != http.StatusOK {
+		t.Fatalf("expected duplicate delivery to be acknowledged, got %d", code)
+	}
+	if len(*queued) != 1 || (*queued)[0] != "it_1" {
+		t.Fatalf("expected it_1 queued once, got %v", *queued)
+	}
+}
+
+func TestTicketTailorWebhookRejectsBadSignature(t *testing.T) {
+	webhook, events, queued := newTestWebhook(10)
+	body := `{"id":"wh_1","event":"ISSUED_TICKET.VOIDED","payload":{"id":"it_1","status":"void"}}`
+
+	if code := deliver(t, webhook, body, "t=1760961600,v1=deadbeef"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if len(*queued) != 0 || len(events.seen) != 0 {
+		t.Fatalf("unsigned deliveries must not be recorded or queued")
+	}
+}
+
+func TestTicketTailorWebhookIgnoresOtherEvents(t *testing.T) {
+	webhook, events, queued := newTestWebhook(10)
+
+	if code := deliver(t, webhook, `{"id":"wh_2","event":"ORDER.CREATED","payload":{"id":"or_1"}}`, ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(*queued) != 0 || len(events.seen) != 0 {
+		t.Fatalf("non-ticket events must be skipped")
+	}
+}
+
+func TestTicketTailorWebhookFullQueueIsRedelivered(t *testing.T) {
+	webhook, events, queued := newTestWebhook(0)
+	body := `{"id":"wh_3","event":"ISSUED_TICKET.UPDATED","payload":{"id":"it_3","status":"valid"}}`
+
+	if code := deliver(t, webhook, body, ""); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", code)
+	}
+	if _, ok := events.seen["wh_3"]; ok {
+		t.Fatalf("refused deliveries must be forgotten so the retry is processed")
+	}
+	if len(*queued) != 0 {
+		t.Fatalf("unexpected queued tickets %v", *queued)
+	}
+}
@@ -0,0 +1,269 @@
+package tickets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/http_logs"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrUnauthorized is matched by API errors for a missing, invalid or under-privileged API key.
+	ErrUnauthorized = errors.New("ticket tailor: unauthorized")
+	// ErrNotFound is matched by API errors for unknown tickets or events.
+	ErrNotFound = errors.New("ticket tailor: not found")
+	// ErrRateLimited is matched by API errors once retries are exhausted on 429 responses.
+	ErrRateLimited = errors.New("ticket tailor: rate limited")
+	// ErrValidation is matched by API errors for rejected request parameters.
+	ErrValidation = errors.New("ticket tailor: validation failed")
+)
+
+// APIError is a non-2xx Ticket Tailor response, carrying the error body when one was returned.
+type APIError struct {
+	StatusCode int
+	ErrorCode  string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("ticket tailor api: %d %s: %s", e.StatusCode, e.ErrorCode, msg)
+	}
+	return fmt.Sprintf("ticket tailor api: %d: %s", e.StatusCode, msg)
+}
+
+// Is lets callers match API errors against the package sentinels.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrValidation:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+type apiErrorBody struct {
+	Status    int    `json:"status"`
+	ErrorCode string `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// RetryPolicy controls how failed requests are retried. Rate-limited requests are always retried; server errors
+// and transport failures are retried only for idempotent requests.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy keeps a 5-minute cron run inside Ticket Tailor's per-minute request budget.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// backoff returns the delay before retry number attempt (1-based), preferring the server's Retry-After.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(attempt-1)))
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Client talks to the Ticket Tailor API for a single event.
+type Client struct {
+	config     TicketTailorConfig
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// ClientOption overrides a Client dependency.
+type ClientOption func(*Client)
+
+// WithHTTPClient injects the HTTP client used for API requests.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// NewClient validates the config and builds a client with the logging HTTP client and default retry policy.
+func NewClient(config TicketTailorConfig, opts ...ClientOption) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		config: config,
+		retry:  DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = http_logs.NewLoggingClient()
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// Config returns the Ticket Tailor config the client was built with.
+func (c *Client) Config() TicketTailorConfig {
+	return c.config
+}
+
+func (c *Client) endpoint(query url.Values, segments ...string) (string, error) {
+	u, err := url.Parse(c.config.BaseUrl)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(append([]string{u.Path}, segments...)...)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+// do sends the request, retrying per the policy, and decodes a 2xx JSON body into out.
+func (c *Client) do(ctx context.Context, method string, endpoint string, form url.Values, out any) error {
+	idempotent := method == http.MethodGet
+
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, endpoint, form, out)
+		if err == nil {
+			return nil
+		}
+
+		var apiErr *APIError
+		isAPIErr := errors.As(err, &apiErr)
+		retryable := errors.Is(err, ErrRateLimited) ||
+			(idempotent && isAPIErr && apiErr.StatusCode >= http.StatusInternalServerError) ||
+			(idempotent && !isAPIErr && ctx.Err() == nil)
+		if !retryable || attempt >= c.retry.MaxAttempts {
+			return err
+		}
+
+		delay := c.retry.backoff(attempt, retryAfter)
+		logger.Logger.Warn(
+			"Retrying ticket tailor request",
+			zap.String("method", method),
+			zap.String("url", endpoint),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(
+	ctx context.Context,
+	method string,
+	endpoint string,
+	form url.Values,
+	out any,
+) (time.Duration, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return 0, err
+	}
+	encodedApiKey := base64.StdEncoding.EncodeToString([]byte(c.config.ApiKey))
+	req.Header.Set("Authorization", "Basic "+encodedApiKey)
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("reading ticket tailor response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var errBody apiErrorBody
+		if json.Unmarshal(raw, &errBody) == nil {
+			apiErr.ErrorCode = errBody.ErrorCode
+			apiErr.Message = errBody.Message
+		}
+		return parseRetryAfter(resp.Header.Get("Retry-After")), apiErr
+	}
+
+	if out == nil {
+		return 0, nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return 0, fmt.Errorf("decoding ticket tailor response: %w", err)
+	}
+	return 0, nil
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date forms.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
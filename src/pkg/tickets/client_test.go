@@ -0,0 +1,102 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetriesRateLimitedRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"status":429,"error_code":"RATE_LIMITED","message":"slow down"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"it_1"}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, TicketTailorConfig{ApiKey: "key", EventId: "ev", BaseUrl: server.URL})
+	ticket, err := client.FetchIssuedTicket(context.Background(), "it_1")
+	if err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+	if ticket.ID != "it_1" || calls.Load() != 3 {
+		t.Fatalf("unexpected result %+v after %d calls", ticket, calls.Load())
+	}
+}
+
+func TestClientSurfacesTypedErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		status    int
+		body      string
+		want      error
+		wantCalls int32
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"status":401,"error_code":"UNAUTHORIZED","message":"bad key"}`, want: ErrUnauthorized, wantCalls: 1},
+		{name: "validation", status: http.StatusUnprocessableEntity, body: `{"status":422,"error_code":"VALIDATION_ERROR","message":"bad quantity"}`, want: ErrValidation, wantCalls: 1},
+		{name: "rate limit exhausted", status: http.StatusTooManyRequests, want: ErrRateLimited, wantCalls: 3},
+		{name: "server error retried for reads", status: http.StatusBadGateway, wantCalls: 3},
+		{name: "server error not retried for check-ins", method: http.MethodPost, status: http.StatusBadGateway, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, TicketTailorConfig{ApiKey: "key", EventId: "ev", BaseUrl: server.URL})
+			var err error
+			if tt.method == http.MethodPost {
+				_, err = client.CheckInTicket(context.Background(), "it_1", CheckIn)
+			} else {
+				_, err = client.FetchIssuedTicket(context.Background(), "it_1")
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("expected APIError with status %d, got %v", tt.status, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	if got := policy.backoff(1, 0); got != 100*time.Millisecond {
+		t.Fatalf("first retry: got %v", got)
+	}
+	if got := policy.backoff(3, 0); got != 400*time.Millisecond {
+		t.Fatalf("third retry: got %v", got)
+	}
+	if got := policy.backoff(10, 0); got != time.Second {
+		t.Fatalf("expected cap at max delay, got %v", got)
+	}
+	if got := policy.backoff(1, 700*time.Millisecond); got != 700*time.Millisecond {
+		t.Fatalf("expected Retry-After to win, got %v", got)
+	}
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Fatalf("parse Retry-After seconds: got %v", got)
+	}
+}
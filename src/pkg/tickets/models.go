@@ -1,7 +1,10 @@
 package tickets
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"github.com/atunbetun/hakuna-wallet/pkg"
 )
@@ -12,33 +15,70 @@ type TTResponse struct {
 }
 
 type TTIssuedTicket struct {
-	Object             string           `json:"object"`
-	ID                 string           `json:"id"`
-	AddOnID            *string          `json:"add_on_id"`
-	Barcode            string           `json:"barcode"`
-	BarcodeURL         string           `json:"barcode_url"`
-	CheckedIn          string           `json:"checked_in"`
-	CreatedAt          int64            `json:"created_at"`
-	CustomQuestions    []any            `json:"custom_questions"`
-	Description        string           `json:"description"`
-	Email              string           `json:"email"`
-	EventID            string           `json:"event_id"`
-	EventSeriesID      string           `json:"event_series_id"`
-	FirstName          string           `json:"first_name"`
-	FullName           string           `json:"full_name"`
-	GroupTicketBarcode *string          `json:"group_ticket_barcode"`
-	LastName           string           `json:"last_name"`
-	ListedCurrency     TTListedCurrency `json:"listed_currency"`
-	ListedPrice        int              `json:"listed_price"`
-	OrderID            string           `json:"order_id"`
-	QRCodeURL          string           `json:"qr_code_url"`
-	Reference          *string          `json:"reference"`
-	Reservation        *string          `json:"reservation"`
-	Source             string           `json:"source"`
-	Status             string           `json:"status"`
-	TicketTypeID       string           `json:"ticket_type_id"`
-	UpdatedAt          int64            `json:"updated_at"`
-	VoidedAt           *string          `json:"voided_at"`
+	Object             string             `json:"object"`
+	ID                 string             `json:"id"`
+	AddOnID            *string            `json:"add_on_id"`
+	Barcode            string             `json:"barcode"`
+	BarcodeURL         string             `json:"barcode_url"`
+	CheckedIn          string             `json:"checked_in"`
+	CreatedAt          int64              `json:"created_at"`
+	CustomQuestions    []TTCustomQuestion `json:"custom_questions"`
+	Description        string             `json:"description"`
+	Email              string             `json:"email"`
+	EventID            string             `json:"event_id"`
+	EventSeriesID      string             `json:"event_series_id"`
+	FirstName          string             `json:"first_name"`
+	FullName           string             `json:"full_name"`
+	GroupTicketBarcode *string            `json:"group_ticket_barcode"`
+	LastName           string             `json:"last_name"`
+	ListedCurrency     TTListedCurrency   `json:"listed_currency"`
+	ListedPrice        int                `json:"listed_price"`
+	OrderID            string             `json:"order_id"`
+	QRCodeURL          string             `json:"qr_code_url"`
+	Reference          *string            `json:"reference"`
+	Reservation        *string            `json:"reservation"`
+	Source             string             `json:"source"`
+	Status             string             `json:"status"`
+	TicketTypeID       string             `json:"ticket_type_id"`
+	UpdatedAt          int64              `json:"updated_at"`
+	VoidedAt           *string            `json:"voided_at"`
+}
+
+// IsVoided reports whether Ticket Tailor voided the ticket (e.g. after a refund).
+func (t TTIssuedTicket) IsVoided() bool {
+	return t.Status == string(Void) || t.VoidedAt != nil
+}
+
+// Answer returns the holder's answer to the custom question with the given text, matched case-insensitively,
+// or "" when the ticket has none.
+func (t TTIssuedTicket) Answer(question string) string {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return ""
+	}
+	for _, q := range t.CustomQuestions {
+		if strings.EqualFold(strings.TrimSpace(q.Question), question) {
+			return strings.TrimSpace(q.Answer)
+		}
+	}
+	return ""
+}
+
+// Fingerprint hashes the fields rendered onto wallet passes, so a changed holder name or re-issued barcode
+// produces a different value while unrelated updates (check-ins, prices) do not.
+func (t TTIssuedTicket) Fingerprint() string {
+	h := sha256.New()
+	for _, field := range []string{t.FullName, t.FirstName, t.LastName, t.Barcode, t.Description, t.TicketTypeID} {
+		// Length-prefix each field so values cannot bleed into their neighbours.
+		fmt.Fprintf(h, "%d:%s|", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// TTCustomQuestion is a checkout question and the holder's answer to it.
+type TTCustomQuestion struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
 }
 
 type TTListedCurrency struct {
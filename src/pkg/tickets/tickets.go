@@ -2,16 +2,11 @@ package tickets
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"path"
-	"strings"
+	"strconv"
 
-	"github.com/atunbetun/hakuna-wallet/pkg/http_logs"
 	"github.com/atunbetun/hakuna-wallet/pkg/logger"
 	"go.uber.org/zap"
 )
@@ -22,6 +17,7 @@ type TicketStatus string
 
 const (
 	Valid TicketStatus = "valid"
+	Void  TicketStatus = "void"
 )
 
 type CheckAction string
@@ -31,65 +27,93 @@ const (
 	CheckOut CheckAction = "checkOut"
 )
 
-func FetchIssuedTickets(
+func (c *Client) FetchIssuedTickets(
 	ctx context.Context,
-	config TicketTailorConfig,
 	status TicketStatus,
 	startingAfter string,
 ) (
 	[]TTIssuedTicket,
 	error,
 ) {
-	if err := config.Validate(); err != nil {
-		return nil, err
-	}
+	return c.FetchIssuedTicketsUpdatedSince(ctx, status, 0, startingAfter)
+}
 
-	u, err := url.Parse(config.BaseUrl)
-	if err != nil {
-		return nil, err
-	}
-	u.Path = path.Join(u.Path, "issued_tickets")
+// FetchIssuedTicketsUpdatedSince fetches one page of tickets whose updated_at is at or after updatedSince
+// (unix seconds). A zero updatedSince applies no filter.
+func (c *Client) FetchIssuedTicketsUpdatedSince(
+	ctx context.Context,
+	status TicketStatus,
+	updatedSince int64,
+	startingAfter string,
+) (
+	[]TTIssuedTicket,
+	error,
+) {
 	q := url.Values{}
-	q.Set("event_id", config.EventId)
+	q.Set("event_id", c.config.EventId)
 	q.Set("status", string(status))
+	if updatedSince > 0 {
+		q.Set("updated_at.gte", strconv.FormatInt(updatedSince, 10))
+	}
 	if startingAfter != "" {
 		q.Set("starting_after", startingAfter)
 	}
-	u.RawQuery = q.Encode()
 
-	logger.Logger.Debug("TT Url", zap.Any("url", u.String()))
-
-	req, _ := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
-	encodedApiKey := base64.StdEncoding.EncodeToString([]byte(config.ApiKey))
-	req.Header.Set("Authorization", "Basic "+encodedApiKey)
-
-	// TODO: this should probably be injected
-	client := http_logs.NewLoggingClient()
-	resp, err := client.Do(req)
+	endpoint, err := c.endpoint(q, "issued_tickets")
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	logger.Logger.Debug("TT Url", zap.Any("url", endpoint))
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	var ttResp TTResponse
+	if err := c.do(ctx, http.MethodGet, endpoint, nil, &ttResp); err != nil {
+		return nil, fmt.Errorf("listing issued tickets: %w", err)
 	}
 
-	var ttResp TTResponse
-	err = json.Unmarshal(body, &ttResp)
+	return ttResp.Data, nil
+}
+
+// FetchIssuedTicket retrieves a single issued ticket by its Ticket Tailor ID.
+func (c *Client) FetchIssuedTicket(
+	ctx context.Context,
+	ticketId string,
+) (
+	TTIssuedTicket,
+	error,
+) {
+	if ticketId == "" {
+		return TTIssuedTicket{}, fmt.Errorf("ticket id is required")
+	}
+
+	endpoint, err := c.endpoint(nil, "issued_tickets", ticketId)
 	if err != nil {
-		return nil, err
+		return TTIssuedTicket{}, err
 	}
-	// logger.Logger.Debug("TT Issued Tickets", zap.Any("data", ttResp.Data))
+	logger.Logger.Debug("TT Url", zap.Any("url", endpoint))
 
-	return ttResp.Data, nil
+	var ticket TTIssuedTicket
+	if err := c.do(ctx, http.MethodGet, endpoint, nil, &ticket); err != nil {
+		return TTIssuedTicket{}, fmt.Errorf("fetching issued ticket %s: %w", ticketId, err)
+	}
+
+	return ticket, nil
+}
+
+func (c *Client) FetchAllIssuedTickets(
+	ctx context.Context,
+	status TicketStatus,
+) (
+	[]TTIssuedTicket,
+	error,
+) {
+	return c.FetchAllIssuedTicketsUpdatedSince(ctx, status, 0)
 }
 
-func FetchAllIssuedTickets(
+// FetchAllIssuedTicketsUpdatedSince pages through every ticket changed at or after updatedSince (unix seconds).
+func (c *Client) FetchAllIssuedTicketsUpdatedSince(
 	ctx context.Context,
-	config TicketTailorConfig,
 	status TicketStatus,
+	updatedSince int64,
 ) (
 	[]TTIssuedTicket,
 	error,
@@ -98,7 +122,7 @@ func FetchAllIssuedTickets(
 	var startingAfter string
 
 	for {
-		tickets, err := FetchIssuedTickets(ctx, config, status, startingAfter)
+		tickets, err := c.FetchIssuedTicketsUpdatedSince(ctx, status, updatedSince, startingAfter)
 		if err != nil {
 			return nil, err
 		}
@@ -114,59 +138,37 @@ func FetchAllIssuedTickets(
 	return allTickets, nil
 }
 
-func CheckInTicket(
+func (c *Client) CheckInTicket(
 	ctx context.Context,
-	config TicketTailorConfig,
 	ticketId string,
 	checkAction CheckAction,
 ) (
 	CheckInResponse,
 	error,
 ) {
-	if err := config.Validate(); err != nil {
-		return CheckInResponse{}, err
-	}
-
-	u, err := url.Parse(config.BaseUrl)
-	if err != nil {
-		return CheckInResponse{}, err
-	}
-
 	var quantity int
 	switch checkAction {
 	case CheckIn:
 		quantity = 1
 	case CheckOut:
 		quantity = -1
+	default:
+		return CheckInResponse{}, fmt.Errorf("unknown check action: %s", checkAction)
 	}
 
-	payload := strings.NewReader(fmt.Sprintf("issued_ticket_id=%s&quantity=%d", ticketId, quantity))
-	u.Path = path.Join(u.Path, "check_ins")
-
-	logger.Logger.Debug("TT Url", zap.Any("url", u.String()))
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", u.String(), payload)
-	encodedApiKey := base64.StdEncoding.EncodeToString([]byte(config.ApiKey))
-	req.Header.Set("Authorization", "Basic "+encodedApiKey)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	// TODO: this should probably be injected
-	client := http_logs.NewLoggingClient()
-	resp, err := client.Do(req)
+	endpoint, err := c.endpoint(nil, "check_ins")
 	if err != nil {
 		return CheckInResponse{}, err
 	}
-	defer resp.Body.Close()
+	logger.Logger.Debug("TT Url", zap.Any("url", endpoint))
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
-	}
+	form := url.Values{}
+	form.Set("issued_ticket_id", ticketId)
+	form.Set("quantity", strconv.Itoa(quantity))
 
 	var chResponse CheckInResponse
-	err = json.Unmarshal(body, &chResponse)
-	if err != nil {
-		panic(err)
+	if err := c.do(ctx, http.MethodPost, endpoint, form, &chResponse); err != nil {
+		return CheckInResponse{}, fmt.Errorf("%s ticket %s: %w", checkAction, ticketId, err)
 	}
 	logger.Logger.Debug(fmt.Sprintf("TT %s ticket", checkAction), zap.Any("ticketId", ticketId), zap.Any("action", checkAction))
 
@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/atunbetun/hakuna-wallet/pkg/logger"
 	"go.uber.org/zap"
@@ -19,6 +21,15 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+func newTestClient(t *testing.T, config TicketTailorConfig) *Client {
+	t.Helper()
+	client, err := NewClient(config, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	return client
+}
+
 func TestFetchIssuedTicketsSuccess(t *testing.T) {
 	var capturedAuth string
 	var capturedQuery url.Values
@@ -51,7 +62,7 @@ func TestFetchIssuedTicketsSuccess(t *testing.T) {
 		BaseUrl: server.URL,
 	}
 
-	tickets, err := FetchIssuedTickets(context.Background(), config, "issued", "cursor-42")
+	tickets, err := newTestClient(t, config).FetchIssuedTickets(context.Background(), "issued", "cursor-42")
 	if err != nil {
 		t.Fatalf("FetchIssuedTickets returned error: %v", err)
 	}
@@ -76,8 +87,8 @@ func TestFetchIssuedTicketsSuccess(t *testing.T) {
 	}
 }
 
-func TestFetchIssuedTicketsInvalidConfig(t *testing.T) {
-	_, err := FetchIssuedTickets(context.Background(), TicketTailorConfig{}, "issued", "")
+func TestNewClientInvalidConfig(t *testing.T) {
+	_, err := NewClient(TicketTailorConfig{})
 	if err == nil {
 		t.Fatal("expected validation error, got nil")
 	}
@@ -113,7 +124,7 @@ func TestFetchAllIssuedTickets(t *testing.T) {
 		BaseUrl: server.URL,
 	}
 
-	tickets, err := FetchAllIssuedTickets(context.Background(), config, "issued")
+	tickets, err := newTestClient(t, config).FetchAllIssuedTickets(context.Background(), "issued")
 	if err != nil {
 		t.Fatalf("FetchAllIssuedTickets returned error: %v", err)
 	}
@@ -126,6 +137,49 @@ func TestFetchAllIssuedTickets(t *testing.T) {
 	}
 }
 
+func TestFetchIssuedTicket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/issued_tickets/it_42" {
+			t.Fatalf("expected path /issued_tickets/it_42, got %s", r.URL.Path)
+		}
+		if err := json.NewEncoder(w).Encode(TTIssuedTicket{ID: "it_42", FullName: "Rafiki"}); err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := TicketTailorConfig{
+		ApiKey:  "secret-key",
+		EventId: "event-123",
+		BaseUrl: server.URL,
+	}
+
+	ticket, err := newTestClient(t, config).FetchIssuedTicket(context.Background(), "it_42")
+	if err != nil {
+		t.Fatalf("FetchIssuedTicket returned error: %v", err)
+	}
+	if ticket.ID != "it_42" || ticket.FullName != "Rafiki" {
+		t.Fatalf("unexpected ticket returned: %+v", ticket)
+	}
+}
+
+func TestFetchIssuedTicketNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := TicketTailorConfig{
+		ApiKey:  "secret-key",
+		EventId: "event-123",
+		BaseUrl: server.URL,
+	}
+
+	if _, err := newTestClient(t, config).FetchIssuedTicket(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing ticket, got %v", err)
+	}
+}
+
 func TestCheckInTicket(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -175,7 +229,7 @@ func TestCheckInTicket(t *testing.T) {
 				BaseUrl: server.URL,
 			}
 
-			resp, err := CheckInTicket(context.Background(), config, "ticket-42", tt.action)
+			resp, err := newTestClient(t, config).CheckInTicket(context.Background(), "ticket-42", tt.action)
 			if err != nil {
 				t.Fatalf("CheckInTicket returned error: %v", err)
 			}
@@ -204,9 +258,98 @@ func TestCheckInTicket(t *testing.T) {
 	}
 }
 
-func TestCheckInTicketInvalidConfig(t *testing.T) {
-	_, err := CheckInTicket(context.Background(), TicketTailorConfig{}, "ticket-42", CheckIn)
+func TestCheckInTicketUnknownAction(t *testing.T) {
+	client := newTestClient(t, TicketTailorConfig{ApiKey: "secret-key", EventId: "event-123", BaseUrl: "http://127.0.0.1:0"})
+	_, err := client.CheckInTicket(context.Background(), "ticket-42", CheckAction("teleport"))
 	if err == nil {
-		t.Fatal("expected validation error, got nil")
+		t.Fatal("expected error for unknown action, got nil")
+	}
+}
+
+func TestTicketFingerprint(t *testing.T) {
+	base := TTIssuedTicket{
+		ID:           "ticket-1",
+		FullName:     "Nala Hakuna",
+		Barcode:      "BR-1",
+		Description:  "VIP",
+		TicketTypeID: "tt_vip",
+		CheckedIn:    "false",
+	}
+
+	checkedIn := base
+	checkedIn.CheckedIn = "true"
+	checkedIn.UpdatedAt = 1700000000
+	if base.Fingerprint() != checkedIn.Fingerprint() {
+		t.Fatalf("fields not rendered on the pass must not change the fingerprint")
+	}
+
+	transferred := base
+	transferred.FullName = "Simba Hakuna"
+	if base.Fingerprint() == transferred.Fingerprint() {
+		t.Fatalf("expected name transfer to change the fingerprint")
+	}
+
+	reissued := base
+	reissued.Barcode = "BR-2"
+	if base.Fingerprint() == reissued.Fingerprint() {
+		t.Fatalf("expected re-issued barcode to change the fingerprint")
+	}
+}
+
+func TestTicketAnswer(t *testing.T) {
+	var ticket TTIssuedTicket
+	payload := `{"id":"ticket-1","custom_questions":[{"question":"Dietary needs","answer":"None"},{"question":"Idioma / Language","answer":" English "}]}`
+	if err := json.Unmarshal([]byte(payload), &ticket); err != nil {
+		t.Fatalf("decoding ticket: %v", err)
+	}
+
+	if got := ticket.Answer("idioma / language"); got != "English" {
+		t.Fatalf("Answer = %q, want %q", got, "English")
+	}
+	if got := ticket.Answer("T-shirt size"); got != "" {
+		t.Fatalf("expected no answer for an unasked question, got %q", got)
+	}
+	if got := ticket.Answer(""); got != "" {
+		t.Fatalf("expected no answer without a question, got %q", got)
+	}
+}
+
+func TestFetchAllIssuedTicketsUpdatedSince(t *testing.T) {
+	var capturedQueries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		capturedQueries = append(capturedQueries, query)
+
+		var tickets []TTIssuedTicket
+		if query.Get("starting_after") == "" {
+			tickets = []TTIssuedTicket{{ID: "7", UpdatedAt: 1760965200}}
+		}
+		if err := json.NewEncoder(w).Encode(TTResponse{Data: tickets}); err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	config := TicketTailorConfig{ApiKey: "secret-key", EventId: "event-123", BaseUrl: server.URL}
+
+	tickets, err := newTestClient(t, config).FetchAllIssuedTicketsUpdatedSince(context.Background(), Valid, 1760961600)
+	if err != nil {
+		t.Fatalf("FetchAllIssuedTicketsUpdatedSince returned error: %v", err)
+	}
+	if len(tickets) != 1 || tickets[0].ID != "7" {
+		t.Fatalf("unexpected tickets: %+v", tickets)
+	}
+	for _, query := range capturedQueries {
+		if query.Get("updated_at.gte") != "1760961600" {
+			t.Fatalf("expected updated_at.gte filter on every page, got %q", query.Get("updated_at.gte"))
+		}
+	}
+
+	capturedQueries = nil
+	if _, err := newTestClient(t, config).FetchAllIssuedTickets(context.Background(), Valid); err != nil {
+		t.Fatalf("FetchAllIssuedTickets returned error: %v", err)
+	}
+	if capturedQueries[0].Has("updated_at.gte") {
+		t.Fatalf("full fetch must not filter by updated_at")
 	}
 }
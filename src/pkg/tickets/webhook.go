@@ -0,0 +1,104 @@
+package tickets
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+	WebhookSignatureHeader = "Tickettailor-Webhook-Signature"
+	// DefaultWebhookTolerance bounds how old a signed webhook may be before it is treated as a replay.
+	DefaultWebhookTolerance = 5 * time.Minute
+
+	issuedTicketEventPrefix = "ISSUED_TICKET."
+)
+
+// ErrInvalidWebhookSignature is returned for missing, malformed, stale or mismatched webhook signatures.
+var ErrInvalidWebhookSignature = errors.New("ticket tailor: invalid webhook signature")
+
+// WebhookEvent is the envelope Ticket Tailor posts to webhook endpoints.
+type WebhookEvent struct {
+	ID          string          `json:"id"`
+	CreatedAt   int64           `json:"created_at"`
+	Event       string          `json:"event"`
+	ResourceURL string          `json:"resource_url"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// IssuedTicket decodes the payload of ISSUED_TICKET.* events; ok is false for other event types.
+func (e WebhookEvent) IssuedTicket() (ticket TTIssuedTicket, ok bool, err error) {
+	if !strings.HasPrefix(e.Event, issuedTicketEventPrefix) {
+		return TTIssuedTicket{}, false, nil
+	}
+	if err := json.Unmarshal(e.Payload, &ticket); err != nil {
+		return TTIssuedTicket{}, false, fmt.Errorf("decoding issued ticket payload: %w", err)
+	}
+	if ticket.ID == "" {
+		return TTIssuedTicket{}, false, fmt.Errorf("issued ticket payload has no id")
+	}
+	return ticket, true, nil
+}
+
+// VerifyWebhookSignature checks the signature header against body using the shared webhook secret.
+func VerifyWebhookSignature(
+	secret string,
+	header string,
+	body []byte,
+	now time.Time,
+	tolerance time.Duration,
+) error {
+	if secret == "" {
+		return fmt.Errorf("webhook secret is required")
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("%w: malformed header", ErrInvalidWebhookSignature)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrInvalidWebhookSignature)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidWebhookSignature)
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature mismatch", ErrInvalidWebhookSignature)
+}
+
+// SignWebhook returns the hex v1 signature Ticket Tailor computes for a webhook body.
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
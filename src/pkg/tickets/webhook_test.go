@@ -0,0 +1,57 @@
+package tickets
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"id":"wh_1","event":"ISSUED_TICKET.CREATED","payload":{"id":"it_1"}}`)
+	now := time.Unix(1760961600, 0)
+	timestamp := fmt.Sprint(now.Unix())
+	valid := fmt.Sprintf("t=%s,v1=%s", timestamp, SignWebhook(secret, timestamp, body))
+
+	if err := VerifyWebhookSignature(secret, valid, body, now, DefaultWebhookTolerance); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	cases := map[string]struct {
+		header string
+		body   []byte
+		now    time.Time
+	}{
+		"missing header":  {header: "", body: body, now: now},
+		"tampered body":   {header: valid, body: []byte(`{"id":"wh_2"}`), now: now},
+		"wrong secret":    {header: fmt.Sprintf("t=%s,v1=%s", timestamp, SignWebhook("other", timestamp, body)), body: body, now: now},
+		"replayed":        {header: valid, body: body, now: now.Add(time.Hour)},
+		"bad timestamp":   {header: "t=yesterday,v1=abc", body: body, now: now},
+		"no v1 signature": {header: "t=" + timestamp, body: body, now: now},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyWebhookSignature(secret, tc.header, tc.body, tc.now, DefaultWebhookTolerance)
+			if !errors.Is(err, ErrInvalidWebhookSignature) {
+				t.Fatalf("expected ErrInvalidWebhookSignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookEventIssuedTicket(t *testing.T) {
+	event := WebhookEvent{Event: "ISSUED_TICKET.VOIDED", Payload: []byte(`{"id":"it_9","status":"void"}`)}
+	ticket, ok, err := event.IssuedTicket()
+	if err != nil || !ok {
+		t.Fatalf("expected issued ticket payload, got ok=%v err=%v", ok, err)
+	}
+	if ticket.ID != "it_9" || !ticket.IsVoided() {
+		t.Fatalf("unexpected ticket %+v", ticket)
+	}
+
+	_, ok, err = WebhookEvent{Event: "ORDER.CREATED", Payload: []byte(`{"id":"or_1"}`)}.IssuedTicket()
+	if err != nil || ok {
+		t.Fatalf("expected non-ticket events to be skipped, got ok=%v err=%v", ok, err)
+	}
+}
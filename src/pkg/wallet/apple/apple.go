@@ -2,6 +2,7 @@ package apple
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/alvinbaena/passkit"
 	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
@@ -16,6 +17,9 @@ type Signer interface {
 // SigningInfoLoader loads signing information from disk or another source.
 type SigningInfoLoader func(certPath string, password string, rootPath string) (*passkit.SigningInformation, error)
 
+// AuthTokenSource returns the per-pass authentication token Wallet presents to the web service.
+type AuthTokenSource func(ctx context.Context, passTypeIdentifier string, serialNumber string) (string, error)
+
 // Creator exposes the minimum API needed by the rest of the system.
 type Creator interface {
 	Create(ctx context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error)
@@ -31,4 +35,28 @@ type AppleConfig struct {
 	SigningCertificatePath     string `validate:"required"`
 	SigningCertificatePassword string `validate:"required"`
 	AppleRootCertificatePath   string `validate:"required"`
+	// WebServiceURL enables live updates when set; passes then carry an authentication token.
+	WebServiceURL string `validate:"omitempty,url"`
+	// APNsKeyID and APNsKeyPath select token-based APNs auth; without them the signing certificate is used.
+	APNsKeyID   string
+	APNsKeyPath string
+}
+
+// applyWebService points the pass at the update web service and stamps its authentication token.
+func applyWebService(ctx context.Context, cfg AppleConfig, tokens AuthTokenSource, pass *passkit.Pass) error {
+	if cfg.WebServiceURL == "" {
+		return nil
+	}
+	if tokens == nil {
+		return fmt.Errorf("auth token source is required when web service url is set")
+	}
+
+	token, err := tokens(ctx, pass.PassTypeIdentifier, pass.SerialNumber)
+	if err != nil {
+		return fmt.Errorf("resolving pass auth token: %w", err)
+	}
+
+	pass.WebServiceURL = cfg.WebServiceURL
+	pass.AuthenticationToken = token
+	return nil
 }
@@ -30,6 +30,7 @@ type defaultApplePassCreator struct {
 	Config            AppleConfig `validate:"required"`
 	Signer            Signer
 	SigningInfoLoader SigningInfoLoader
+	AuthTokens        AuthTokenSource
 	QRSize            int
 }
 
@@ -72,6 +73,10 @@ func (c *defaultApplePassCreator) Create(ctx context.Context, ticket tickets.TTI
 		return wallet.Artifact{}, err
 	}
 
+	if err := applyWebService(ctx, c.Config, c.AuthTokens, draft.Pass); err != nil {
+		return wallet.Artifact{}, err
+	}
+
 	logger.Logger.Debug(
 		"loading signing information",
 		zap.String("signing_certificate_path", c.Config.SigningCertificatePath),
@@ -194,6 +199,7 @@ func buildPass(cfg AppleConfig, ticket tickets.TTIssuedTicket) *passkit.Pass {
 		OrganizationName:   cfg.OrganizationName,
 		Description:        cfg.Description,
 		LogoText:           cfg.LogoText,
+		Voided:             ticket.IsVoided(),
 	}
 
 	pass.Barcodes = append(pass.Barcodes, passkit.Barcode{
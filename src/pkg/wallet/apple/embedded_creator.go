@@ -37,6 +37,7 @@ type embeddedApplePassCreator struct {
 	Config            AppleConfig `validate:"required"`
 	Signer            Signer
 	SigningInfoLoader SigningInfoLoader
+	AuthTokens        AuthTokenSource
 }
 
 // NewEmbeddedApplePassCreator returns a creator that relies on the embedded pass assets.
@@ -64,6 +65,10 @@ func (c *embeddedApplePassCreator) Create(ctx context.Context, ticket tickets.TT
 		return wallet.Artifact{}, err
 	}
 
+	if err := applyWebService(ctx, c.Config, c.AuthTokens, pass); err != nil {
+		return wallet.Artifact{}, err
+	}
+
 	template, err := loadEmbeddedPassTemplate()
 	if err != nil {
 		return wallet.Artifact{}, fmt.Errorf("loading embedded template assets: %w", err)
@@ -115,6 +120,7 @@ func (c *embeddedApplePassCreator) buildPass(ticket tickets.TTIssuedTicket) (*pa
 	pass.Description = c.Config.Description
 	pass.LogoText = c.Config.LogoText
 	pass.SerialNumber = ticket.ID
+	pass.Voided = ticket.IsVoided()
 
 	// TODO: mutation
 	if err := updatePassBarcode(&pass, ticket.Barcode); err != nil {
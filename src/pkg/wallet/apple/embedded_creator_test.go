@@ -256,3 +256,36 @@ func TestEmbeddedCreatorRequiresBarcode(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestEmbeddedCreatorVoidsRefundedTickets(t *testing.T) {
+	logger.Init()
+
+	signer := &capturingSigner{}
+	creator := NewEmbeddedApplePassCreator(
+		AppleConfig{
+			PassTypeIdentifier:         "pass.com.hakuna.integration",
+			TeamIdentifier:             "TEAMHAKUNA",
+			SigningCertificatePath:     "/tmp/cert.p12",
+			SigningCertificatePassword: "integration-password",
+			AppleRootCertificatePath:   "/tmp/root.cer",
+		},
+	)
+	creator.Signer = signer
+	creator.SigningInfoLoader = func(_, _, _ string) (*passkit.SigningInformation, error) {
+		return &passkit.SigningInformation{}, nil
+	}
+
+	ticket := tickets.TTIssuedTicket{
+		ID:       "tt_embed_void",
+		Barcode:  "EMBED-QR-VOID",
+		FullName: "Pumbaa Warthog",
+		Status:   string(tickets.Void),
+	}
+
+	if _, err := creator.Create(context.Background(), ticket); err != nil {
+		t.Fatalf("generate embedded pass: %v", err)
+	}
+	if signer.pass == nil || !signer.pass.Voided {
+		t.Fatalf("expected voided pass, got %+v", signer.pass)
+	}
+}
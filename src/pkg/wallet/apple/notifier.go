@@ -0,0 +1,390 @@
+package apple
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"go.uber.org/zap"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// DefaultAPNsEndpoint is the production APNs host; Wallet passes always use production.
+	DefaultAPNsEndpoint = "https://api.push.apple.com"
+
+	apnsTokenLifetime   = 50 * time.Minute
+	defaultPushParallel = 8
+)
+
+// PushTarget is a device push token registered for a pass.
+type PushTarget struct {
+	RegistrationID     string
+	PassTypeIdentifier string
+	SerialNumber       string
+	PushToken          string
+}
+
+// PushResult captures the APNs response for a single push target.
+type PushResult struct {
+	Target       PushTarget
+	StatusCode   int
+	Reason       string
+	Unregistered bool
+	Err          error
+	SentAt       time.Time
+}
+
+// Delivered reports whether APNs accepted the push.
+func (r PushResult) Delivered() bool {
+	return r.Err == nil && r.StatusCode == http.StatusOK
+}
+
+// PushTokenStore resolves registered devices and records the outcome of pushes to them.
+type PushTokenStore interface {
+	PushTargets(ctx context.Context, serialNumbers []string) ([]PushTarget, error)
+	RecordPushResults(ctx context.Context, results []PushResult) error
+}
+
+// apnsAuth decorates requests (token auth) or the TLS client (certificate auth).
+type apnsAuth interface {
+	authorize(req *http.Request, now time.Time) error
+	tlsCertificates() []tls.Certificate
+}
+
+// Notifier tells devices that a pass changed by sending the empty-payload APNs push.
+type Notifier struct {
+	store     PushTokenStore
+	auth      apnsAuth
+	endpoint  string
+	transport http.RoundTripper
+	parallel  int
+	now       func() time.Time
+}
+
+// NotifierOption overrides a Notifier dependency.
+type NotifierOption func(*Notifier)
+
+// WithAPNsTransport injects the HTTP/2 transport used to reach APNs.
+func WithAPNsTransport(rt http.RoundTripper) NotifierOption {
+	return func(n *Notifier) {
+		n.transport = rt
+	}
+}
+
+// WithAPNsEndpoint points the notifier at a different APNs host (e.g. a local fake).
+func WithAPNsEndpoint(endpoint string) NotifierOption {
+	return func(n *Notifier) {
+		n.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+// WithNotifierClock swaps the time source used for JWTs and result timestamps.
+func WithNotifierClock(now func() time.Time) NotifierOption {
+	return func(n *Notifier) {
+		n.now = now
+	}
+}
+
+// NewNotifier builds a notifier from the Apple signing material. A configured APNs key selects token-based
+// auth; otherwise the pass type certificate is presented as a TLS client certificate.
+func NewNotifier(cfg AppleConfig, store PushTokenStore, opts ...NotifierOption) (*Notifier, error) {
+	if store == nil {
+		return nil, fmt.Errorf("push token store is required")
+	}
+
+	auth, err := newAPNsAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{
+		store:    store,
+		auth:     auth,
+		endpoint: DefaultAPNsEndpoint,
+		parallel: defaultPushParallel,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	if n.transport == nil {
+		n.transport = &http.Transport{
+			ForceAttemptHTTP2: true,
+			TLSClientConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: auth.tlsCertificates(),
+			},
+		}
+	}
+
+	return n, nil
+}
+
+// Notify pushes an update to every device registered for the given serial numbers, batched per pass type.
+func (n *Notifier) Notify(ctx context.Context, serialNumbers []string) ([]PushResult, error) {
+	if len(serialNumbers) == 0 {
+		return nil, nil
+	}
+
+	targets, err := n.store.PushTargets(ctx, serialNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("loading push targets: %w", err)
+	}
+	if len(targets) == 0 {
+		logger.Logger.Debug("no registered devices to notify", zap.Int("serial_count", len(serialNumbers)))
+		return nil, nil
+	}
+
+	byPassType := make(map[string][]PushTarget)
+	for _, target := range targets {
+		byPassType[target.PassTypeIdentifier] = append(byPassType[target.PassTypeIdentifier], target)
+	}
+
+	client := &http.Client{Transport: n.transport}
+	var results []PushResult
+	for passType, batch := range byPassType {
+		logger.Logger.Debug(
+			"sending apns batch",
+			zap.String("pass_type_identifier", passType),
+			zap.Int("count", len(batch)),
+		)
+		results = append(results, n.sendBatch(ctx, client, batch)...)
+	}
+
+	if err := n.store.RecordPushResults(ctx, results); err != nil {
+		return results, fmt.Errorf("recording push results: %w", err)
+	}
+
+	var failed, unregistered int
+	for _, r := range results {
+		switch {
+		case r.Unregistered:
+			unregistered++
+		case !r.Delivered():
+			failed++
+		}
+	}
+	logger.Logger.Info(
+		"sent apns pass updates",
+		zap.Int("targets", len(results)),
+		zap.Int("failed", failed),
+		zap.Int("unregistered", unregistered),
+	)
+	return results, nil
+}
+
+func (n *Notifier) sendBatch(ctx context.Context, client *http.Client, batch []PushTarget) []PushResult {
+	results := make([]PushResult, len(batch))
+	sem := make(chan struct{}, n.parallel)
+	var wg sync.WaitGroup
+	for i, target := range batch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target PushTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = n.push(ctx, client, target)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+type apnsErrorBody struct {
+	Reason string `json:"reason"`
+}
+
+func (n *Notifier) push(ctx context.Context, client *http.Client, target PushTarget) PushResult {
+	result := PushResult{Target: target, SentAt: n.now()}
+
+	url := fmt.Sprintf("%s/3/device/%s", n.endpoint, target.PushToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte("{}")))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	req.Header.Set("apns-topic", target.PassTypeIdentifier)
+	req.Header.Set("Content-Type", "application/json")
+	if err := n.auth.authorize(req, result.SentAt); err != nil {
+		result.Err = err
+		return result
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = fmt.Errorf("sending apns push: %w", err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		return result
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var apnsErr apnsErrorBody
+	_ = json.Unmarshal(body, &apnsErr)
+	result.Reason = apnsErr.Reason
+	result.Err = fmt.Errorf("apns rejected push: %d %s", resp.StatusCode, apnsErr.Reason)
+	result.Unregistered = resp.StatusCode == http.StatusGone || apnsErr.Reason == "BadDeviceToken"
+	return result
+}
+
+func newAPNsAuth(cfg AppleConfig) (apnsAuth, error) {
+	if cfg.APNsKeyPath != "" {
+		return newTokenAuth(cfg)
+	}
+	return newCertificateAuth(cfg)
+}
+
+// tokenAuth signs short-lived ES256 provider tokens with the APNs auth key (.p8).
+type tokenAuth struct {
+	keyID  string
+	teamID string
+	key    *ecdsa.PrivateKey
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func newTokenAuth(cfg AppleConfig) (*tokenAuth, error) {
+	if cfg.APNsKeyID == "" {
+		return nil, fmt.Errorf("apns key id is required for token auth")
+	}
+	if cfg.TeamIdentifier == "" {
+		return nil, fmt.Errorf("team identifier is required for token auth")
+	}
+
+	raw, err := os.ReadFile(cfg.APNsKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading apns key: %w", err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("apns key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing apns key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("apns key must be an ECDSA key, got %T", parsed)
+	}
+
+	return &tokenAuth{keyID: cfg.APNsKeyID, teamID: cfg.TeamIdentifier, key: key}, nil
+}
+
+func (a *tokenAuth) authorize(req *http.Request, now time.Time) error {
+	token, err := a.providerToken(now)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	return nil
+}
+
+func (a *tokenAuth) tlsCertificates() []tls.Certificate {
+	return nil
+}
+
+func (a *tokenAuth) providerToken(now time.Time) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && now.Sub(a.issuedAt) < apnsTokenLifetime {
+		return a.token, nil
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": a.keyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{"iss": a.teamID, "iat": now.Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing apns provider token: %w", err)
+	}
+
+	size := (a.key.Curve.Params().BitSize + 7) / 8
+	signature := append(padScalar(r, size), padScalar(s, size)...)
+
+	a.token = signingInput + "." + enc.EncodeToString(signature)
+	a.issuedAt = now
+	return a.token, nil
+}
+
+func padScalar(v *big.Int, size int) []byte {
+	out := make([]byte, size)
+	return v.FillBytes(out)
+}
+
+// certificateAuth presents the pass type certificate as the APNs TLS client certificate.
+type certificateAuth struct {
+	certificate tls.Certificate
+}
+
+func newCertificateAuth(cfg AppleConfig) (*certificateAuth, error) {
+	if cfg.SigningCertificatePath == "" {
+		return nil, fmt.Errorf("signing certificate path is required for certificate auth")
+	}
+
+	raw, err := os.ReadFile(cfg.SigningCertificatePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading signing certificate: %w", err)
+	}
+
+	key, cert, chain, err := pkcs12.DecodeChain(raw, cfg.SigningCertificatePassword)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing certificate: %w", err)
+	}
+	if key == nil || cert == nil {
+		return nil, errors.New("signing certificate is missing its key or leaf")
+	}
+
+	tlsCert := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	for _, c := range chain {
+		tlsCert.Certificate = append(tlsCert.Certificate, c.Raw)
+	}
+	return &certificateAuth{certificate: tlsCert}, nil
+}
+
+func (a *certificateAuth) authorize(*http.Request, time.Time) error {
+	return nil
+}
+
+func (a *certificateAuth) tlsCertificates() []tls.Certificate {
+	return []tls.Certificate{a.certificate}
+}
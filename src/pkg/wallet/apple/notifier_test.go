@@ -0,0 +1,232 @@
+package apple
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type fakePushStore struct {
+	targets  []PushTarget
+	recorded []PushResult
+}
+
+func (f *fakePushStore) PushTargets(_ context.Context, serialNumbers []string) ([]PushTarget, error) {
+	wanted := map[string]bool{}
+	for _, s := range serialNumbers {
+		wanted[s] = true
+	}
+	var out []PushTarget
+	for _, t := range f.targets {
+		if wanted[t.SerialNumber] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakePushStore) RecordPushResults(_ context.Context, results []PushResult) error {
+	f.recorded = append(f.recorded, results...)
+	return nil
+}
+
+type apnsRequest struct {
+	proto         int
+	path          string
+	topic         string
+	authorization string
+	body          string
+}
+
+func writeAPNsKey(t *testing.T) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "AuthKey.p8")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return path, key
+}
+
+func TestNotifierPushesToRegisteredDevices(t *testing.T) {
+	logger.Init()
+
+	var mu sync.Mutex
+	var requests []apnsRequest
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, apnsRequest{
+			proto:         r.ProtoMajor,
+			path:          r.URL.Path,
+			topic:         r.Header.Get("apns-topic"),
+			authorization: r.Header.Get("Authorization"),
+			body:          string(body),
+		})
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/3/device/gone-token":
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+		case "/3/device/busy-token":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	keyPath, key := writeAPNsKey(t)
+	store := &fakePushStore{targets: []PushTarget{
+		{RegistrationID: "r1", PassTypeIdentifier: "pass.a", SerialNumber: "tt_1", PushToken: "ok-token"},
+		{RegistrationID: "r2", PassTypeIdentifier: "pass.a", SerialNumber: "tt_1", PushToken: "gone-token"},
+		{RegistrationID: "r3", PassTypeIdentifier: "pass.b", SerialNumber: "tt_2", PushToken: "busy-token"},
+		{RegistrationID: "r4", PassTypeIdentifier: "pass.a", SerialNumber: "tt_3", PushToken: "untouched"},
+	}}
+
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	notifier, err := NewNotifier(
+		AppleConfig{TeamIdentifier: "TEAMHAKUNA", APNsKeyID: "KEY123", APNsKeyPath: keyPath},
+		store,
+		WithAPNsTransport(server.Client().Transport),
+		WithAPNsEndpoint(server.URL),
+		WithNotifierClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatalf("new notifier: %v", err)
+	}
+
+	results, err := notifier.Notify(context.Background(), []string{"tt_1", "tt_2"})
+	if err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if len(store.recorded) != 3 {
+		t.Fatalf("expected results recorded, got %d", len(store.recorded))
+	}
+
+	byRegistration := map[string]PushResult{}
+	for _, r := range store.recorded {
+		byRegistration[r.Target.RegistrationID] = r
+	}
+	if !byRegistration["r1"].Delivered() {
+		t.Fatalf("expected r1 delivered: %+v", byRegistration["r1"])
+	}
+	if !byRegistration["r2"].Unregistered {
+		t.Fatalf("expected r2 unregistered: %+v", byRegistration["r2"])
+	}
+	if r3 := byRegistration["r3"]; r3.Delivered() || r3.Unregistered || r3.Reason != "TooManyRequests" {
+		t.Fatalf("expected r3 failed with reason: %+v", r3)
+	}
+	if !byRegistration["r1"].SentAt.Equal(now) {
+		t.Fatalf("expected injected clock on results, got %v", byRegistration["r1"].SentAt)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 apns requests, got %d", len(requests))
+	}
+	for _, req := range requests {
+		if req.proto != 2 {
+			t.Fatalf("expected HTTP/2, got HTTP/%d", req.proto)
+		}
+		if req.body != "{}" {
+			t.Fatalf("expected empty payload, got %q", req.body)
+		}
+		if req.path == "/3/device/busy-token" && req.topic != "pass.b" {
+			t.Fatalf("unexpected topic %q for pass.b target", req.topic)
+		}
+		verifyProviderToken(t, req.authorization, &key.PublicKey)
+	}
+}
+
+func verifyProviderToken(t *testing.T, header string, pub *ecdsa.PublicKey) {
+	t.Helper()
+	token, ok := strings.CutPrefix(header, "bearer ")
+	if !ok {
+		t.Fatalf("missing bearer token: %q", header)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed jwt: %q", token)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		t.Fatalf("bad signature encoding: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		t.Fatalf("provider token signature does not verify")
+	}
+}
+
+func TestNotifierLoadsSigningCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Pass Type ID: pass.com.hakuna"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse cert: %v", err)
+	}
+	pfx, err := pkcs12.Modern.Encode(key, cert, nil, "secret")
+	if err != nil {
+		t.Fatalf("encode p12: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.p12")
+	if err := os.WriteFile(path, pfx, 0o600); err != nil {
+		t.Fatalf("write p12: %v", err)
+	}
+
+	auth, err := newAPNsAuth(AppleConfig{SigningCertificatePath: path, SigningCertificatePassword: "secret"})
+	if err != nil {
+		t.Fatalf("certificate auth: %v", err)
+	}
+	certs := auth.tlsCertificates()
+	if len(certs) != 1 || certs[0].Leaf.Subject.CommonName != "Pass Type ID: pass.com.hakuna" {
+		t.Fatalf("unexpected tls certificates: %+v", certs)
+	}
+}
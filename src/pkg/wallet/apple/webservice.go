@@ -0,0 +1,241 @@
+package apple
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"go.uber.org/zap"
+)
+
+const authorizationScheme = "ApplePass "
+
+// PassRegistry persists device registrations and per-pass auth tokens for the web service.
+type PassRegistry interface {
+	AuthTokenMatches(ctx context.Context, passTypeIdentifier, serialNumber, token string) (bool, error)
+	RegisterDevice(ctx context.Context, deviceLibraryIdentifier, pushToken, passTypeIdentifier, serialNumber string) (bool, error)
+	UnregisterDevice(ctx context.Context, deviceLibraryIdentifier, passTypeIdentifier, serialNumber string) error
+	UpdatedSerialNumbers(ctx context.Context, deviceLibraryIdentifier, passTypeIdentifier string, since *time.Time) ([]string, time.Time, error)
+	PassUpdatedAt(ctx context.Context, passTypeIdentifier, serialNumber string) (time.Time, bool, error)
+}
+
+// TicketLookup resolves the current Ticket Tailor ticket behind a pass serial number.
+type TicketLookup func(ctx context.Context, serialNumber string) (tickets.TTIssuedTicket, error)
+
+// WebService implements the Apple Wallet web service protocol so installed passes can be updated.
+type WebService struct {
+	PassTypeIdentifier string
+	Registry           PassRegistry
+	Creator            Creator
+	Tickets            TicketLookup
+}
+
+// NewWebService wires the web service for a single pass type identifier.
+func NewWebService(passTypeIdentifier string, registry PassRegistry, creator Creator, lookup TicketLookup) *WebService {
+	return &WebService{
+		PassTypeIdentifier: passTypeIdentifier,
+		Registry:           registry,
+		Creator:            creator,
+		Tickets:            lookup,
+	}
+}
+
+type registrationRequest struct {
+	PushToken string `json:"pushToken"`
+}
+
+type serialNumbersResponse struct {
+	SerialNumbers []string `json:"serialNumbers"`
+	LastUpdated   string   `json:"lastUpdated"`
+}
+
+type logRequest struct {
+	Logs []string `json:"logs"`
+}
+
+// Handler returns the protocol routes rooted at /v1, relative to the pass webServiceURL.
+func (s *WebService) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/devices/{device}/registrations/{passType}/{serial}", s.registerDevice)
+	mux.HandleFunc("DELETE /v1/devices/{device}/registrations/{passType}/{serial}", s.unregisterDevice)
+	mux.HandleFunc("GET /v1/devices/{device}/registrations/{passType}", s.listSerialNumbers)
+	mux.HandleFunc("GET /v1/passes/{passType}/{serial}", s.latestPass)
+	mux.HandleFunc("POST /v1/log", s.log)
+	return mux
+}
+
+func (s *WebService) registerDevice(w http.ResponseWriter, r *http.Request) {
+	device, passType, serial := r.PathValue("device"), r.PathValue("passType"), r.PathValue("serial")
+	if !s.knownPassType(w, passType) || !s.authorized(w, r, passType, serial) {
+		return
+	}
+
+	var body registrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.PushToken == "" {
+		http.Error(w, "pushToken is required", http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.Registry.RegisterDevice(r.Context(), device, body.PushToken, passType, serial)
+	if err != nil {
+		s.internalError(w, "registering device", err, zap.String("serial_number", serial))
+		return
+	}
+
+	logger.Logger.Info(
+		"registered apple wallet device",
+		zap.String("serial_number", serial),
+		zap.Bool("created", created),
+	)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *WebService) unregisterDevice(w http.ResponseWriter, r *http.Request) {
+	device, passType, serial := r.PathValue("device"), r.PathValue("passType"), r.PathValue("serial")
+	if !s.knownPassType(w, passType) || !s.authorized(w, r, passType, serial) {
+		return
+	}
+
+	if err := s.Registry.UnregisterDevice(r.Context(), device, passType, serial); err != nil {
+		s.internalError(w, "unregistering device", err, zap.String("serial_number", serial))
+		return
+	}
+
+	logger.Logger.Info("unregistered apple wallet device", zap.String("serial_number", serial))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *WebService) listSerialNumbers(w http.ResponseWriter, r *http.Request) {
+	device, passType := r.PathValue("device"), r.PathValue("passType")
+	if !s.knownPassType(w, passType) {
+		return
+	}
+
+	since := parseUpdateTag(r.URL.Query().Get("passesUpdatedSince"))
+	serials, lastUpdated, err := s.Registry.UpdatedSerialNumbers(r.Context(), device, passType, since)
+	if err != nil {
+		s.internalError(w, "listing updated serial numbers", err)
+		return
+	}
+	if len(serials) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(serialNumbersResponse{
+		SerialNumbers: serials,
+		LastUpdated:   formatUpdateTag(lastUpdated),
+	})
+}
+
+func (s *WebService) latestPass(w http.ResponseWriter, r *http.Request) {
+	passType, serial := r.PathValue("passType"), r.PathValue("serial")
+	if !s.knownPassType(w, passType) || !s.authorized(w, r, passType, serial) {
+		return
+	}
+
+	ctx := r.Context()
+	updatedAt, found, err := s.Registry.PassUpdatedAt(ctx, passType, serial)
+	if err != nil {
+		s.internalError(w, "fetching pass update time", err, zap.String("serial_number", serial))
+		return
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	lastModified := updatedAt.UTC().Truncate(time.Second)
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	ticket, err := s.Tickets(ctx, serial)
+	if err != nil {
+		s.internalError(w, "looking up ticket", err, zap.String("serial_number", serial))
+		return
+	}
+
+	artifact, err := s.Creator.Create(ctx, ticket)
+	if err != nil {
+		s.internalError(w, "creating pass", err, zap.String("serial_number", serial))
+		return
+	}
+
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(artifact.Data)
+}
+
+func (s *WebService) log(w http.ResponseWriter, r *http.Request) {
+	var body logRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid log payload", http.StatusBadRequest)
+		return
+	}
+	for _, entry := range body.Logs {
+		logger.Logger.Warn("apple wallet device log", zap.String("message", entry))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *WebService) knownPassType(w http.ResponseWriter, passType string) bool {
+	if passType != s.PassTypeIdentifier {
+		w.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (s *WebService) authorized(w http.ResponseWriter, r *http.Request, passType string, serial string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), authorizationScheme)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	valid, err := s.Registry.AuthTokenMatches(r.Context(), passType, serial, strings.TrimSpace(token))
+	if err != nil {
+		s.internalError(w, "verifying auth token", err, zap.String("serial_number", serial))
+		return false
+	}
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (s *WebService) internalError(w http.ResponseWriter, action string, err error, fields ...zap.Field) {
+	logger.Logger.Error("apple web service "+action, append(fields, zap.Error(err))...)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// The update tag is opaque to devices; microsecond precision matches Postgres timestamps.
+func formatUpdateTag(t time.Time) string {
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+func parseUpdateTag(tag string) *time.Time {
+	if tag == "" {
+		return nil
+	}
+	micros, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.UnixMicro(micros)
+	return &t
+}
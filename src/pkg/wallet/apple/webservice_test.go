@@ -0,0 +1,236 @@
+package apple
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alvinbaena/passkit"
+	"github.com/atunbetun/hakuna-wallet/pkg/logger"
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+)
+
+const (
+	testPassType  = "pass.com.hakuna.integration"
+	testAuthToken = "0123456789abcdef0123456789abcdef"
+)
+
+type registrationKey struct {
+	device string
+	serial string
+}
+
+type fakeRegistry struct {
+	tokens        map[string]string
+	registrations map[registrationKey]string
+	updatedAt     map[string]time.Time
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		tokens:        map[string]string{},
+		registrations: map[registrationKey]string{},
+		updatedAt:     map[string]time.Time{},
+	}
+}
+
+func (f *fakeRegistry) AuthTokenMatches(_ context.Context, _, serialNumber, token string) (bool, error) {
+	stored, ok := f.tokens[serialNumber]
+	return ok && stored == token, nil
+}
+
+func (f *fakeRegistry) RegisterDevice(_ context.Context, device, pushToken, _, serialNumber string) (bool, error) {
+	key := registrationKey{device: device, serial: serialNumber}
+	_, exists := f.registrations[key]
+	f.registrations[key] = pushToken
+	return !exists, nil
+}
+
+func (f *fakeRegistry) UnregisterDevice(_ context.Context, device, _, serialNumber string) error {
+	delete(f.registrations, registrationKey{device: device, serial: serialNumber})
+	return nil
+}
+
+func (f *fakeRegistry) UpdatedSerialNumbers(_ context.Context, device, _ string, since *time.Time) ([]string, time.Time, error) {
+	var serials []string
+	var last time.Time
+	for key := range f.registrations {
+		if key.device != device {
+			continue
+		}
+		updated := f.updatedAt[key.serial]
+		if since != nil && !updated.After(*since) {
+			continue
+		}
+		serials = append(serials, key.serial)
+		if updated.After(last) {
+			last = updated
+		}
+	}
+	return serials, last, nil
+}
+
+func (f *fakeRegistry) PassUpdatedAt(_ context.Context, _ string, serialNumber string) (time.Time, bool, error) {
+	updated, ok := f.updatedAt[serialNumber]
+	return updated, ok, nil
+}
+
+func newTestWebService(t *testing.T, registry *fakeRegistry) (*httptest.Server, *capturingSigner) {
+	t.Helper()
+	logger.Init()
+
+	signer := &capturingSigner{}
+	creator := NewEmbeddedApplePassCreator(AppleConfig{
+		PassTypeIdentifier:         testPassType,
+		TeamIdentifier:             "TEAMHAKUNA",
+		SigningCertificatePath:     "/tmp/cert.p12",
+		SigningCertificatePassword: "integration-password",
+		AppleRootCertificatePath:   "/tmp/root.cer",
+		WebServiceURL:              "https://wallet.example.com/apple",
+	})
+	creator.Signer = signer
+	creator.SigningInfoLoader = func(_, _, _ string) (*passkit.SigningInformation, error) {
+		return &passkit.SigningInformation{}, nil
+	}
+	creator.AuthTokens = func(_ context.Context, _ string, serialNumber string) (string, error) {
+		return registry.tokens[serialNumber], nil
+	}
+
+	lookup := func(_ context.Context, serialNumber string) (tickets.TTIssuedTicket, error) {
+		return tickets.TTIssuedTicket{ID: serialNumber, Barcode: "QR-" + serialNumber, FullName: "Nala Hakuna"}, nil
+	}
+
+	ws := NewWebService(testPassType, registry, creator, lookup)
+	server := httptest.NewServer(ws.Handler())
+	t.Cleanup(server.Close)
+	return server, signer
+}
+
+func doRequest(t *testing.T, method, url, token, body string, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "ApplePass "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestWebServiceRegistrationLifecycle(t *testing.T) {
+	registry := newFakeRegistry()
+	registry.tokens["tt_1"] = testAuthToken
+	registry.updatedAt["tt_1"] = time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	server, _ := newTestWebService(t, registry)
+
+	registerURL := fmt.Sprintf("%s/v1/devices/device-1/registrations/%s/tt_1", server.URL, testPassType)
+
+	resp := doRequest(t, http.MethodPost, registerURL, "wrong-token", `{"pushToken":"push-1"}`, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad token, got %d", resp.StatusCode)
+	}
+
+	resp = doRequest(t, http.MethodPost, registerURL, testAuthToken, `{"pushToken":"push-1"}`, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 on first registration, got %d", resp.StatusCode)
+	}
+
+	resp = doRequest(t, http.MethodPost, registerURL, testAuthToken, `{"pushToken":"push-1"}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 on repeat registration, got %d", resp.StatusCode)
+	}
+
+	listURL := fmt.Sprintf("%s/v1/devices/device-1/registrations/%s", server.URL, testPassType)
+	resp = doRequest(t, http.MethodGet, listURL, "", "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 listing serials, got %d", resp.StatusCode)
+	}
+	var listed serialNumbersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode serials: %v", err)
+	}
+	if len(listed.SerialNumbers) != 1 || listed.SerialNumbers[0] != "tt_1" {
+		t.Fatalf("unexpected serials: %+v", listed)
+	}
+
+	resp = doRequest(t, http.MethodGet, listURL+"?passesUpdatedSince="+listed.LastUpdated, "", "", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 when nothing changed, got %d", resp.StatusCode)
+	}
+
+	resp = doRequest(t, http.MethodDelete, registerURL, testAuthToken, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 on unregister, got %d", resp.StatusCode)
+	}
+	if len(registry.registrations) != 0 {
+		t.Fatalf("expected registration removed, got %+v", registry.registrations)
+	}
+}
+
+func TestWebServiceServesLatestPass(t *testing.T) {
+	registry := newFakeRegistry()
+	registry.tokens["tt_2"] = testAuthToken
+	updated := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	registry.updatedAt["tt_2"] = updated
+	server, signer := newTestWebService(t, registry)
+
+	passURL := fmt.Sprintf("%s/v1/passes/%s/tt_2", server.URL, testPassType)
+
+	resp := doRequest(t, http.MethodGet, passURL, "", "", nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	resp = doRequest(t, http.MethodGet, passURL, testAuthToken, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 fetching pass, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Type") != "application/vnd.apple.pkpass" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	if resp.Header.Get("Last-Modified") != updated.Format(http.TimeFormat) {
+		t.Fatalf("unexpected Last-Modified %q", resp.Header.Get("Last-Modified"))
+	}
+	if signer.pass.WebServiceURL != "https://wallet.example.com/apple" {
+		t.Fatalf("pass missing web service url: %q", signer.pass.WebServiceURL)
+	}
+	if signer.pass.AuthenticationToken != testAuthToken {
+		t.Fatalf("pass missing auth token: %q", signer.pass.AuthenticationToken)
+	}
+
+	resp = doRequest(t, http.MethodGet, passURL, testAuthToken, "", map[string]string{
+		"If-Modified-Since": updated.Format(http.TimeFormat),
+	})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 for unchanged pass, got %d", resp.StatusCode)
+	}
+
+	otherType := fmt.Sprintf("%s/v1/passes/pass.other/tt_2", server.URL)
+	resp = doRequest(t, http.MethodGet, otherType, testAuthToken, "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for foreign pass type, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebServiceAcceptsLogs(t *testing.T) {
+	server, _ := newTestWebService(t, newFakeRegistry())
+
+	resp := doRequest(t, http.MethodPost, server.URL+"/v1/log", "", `{"logs":["something happened"]}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for logs, got %d", resp.StatusCode)
+	}
+}
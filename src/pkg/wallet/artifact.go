@@ -6,4 +6,6 @@ type Artifact struct {
 	FileName    string
 	ContentType string
 	Data        []byte
+	// SaveURL is set for platforms that deliver passes as a link, such as "Save to Google Wallet".
+	SaveURL string
 }
@@ -0,0 +1,312 @@
+package google
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/http_logs"
+)
+
+const (
+	// DefaultBaseURL is the Google Wallet Objects REST API root.
+	DefaultBaseURL = "https://walletobjects.googleapis.com/walletobjects/v1"
+	// DefaultTokenURL is the OAuth2 endpoint that exchanges service account assertions for access tokens.
+	DefaultTokenURL = "https://oauth2.googleapis.com/token"
+
+	walletIssuerScope = "https://www.googleapis.com/auth/wallet_object.issuer"
+	jwtBearerGrant    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	assertionLifetime = time.Hour
+	tokenExpiryLeeway = time.Minute
+)
+
+var (
+	// ErrNotFound is matched by API errors for missing classes or objects.
+	ErrNotFound = errors.New("google wallet resource not found")
+	// ErrConflict is matched by API errors when inserting a class or object that already exists.
+	ErrConflict = errors.New("google wallet resource already exists")
+)
+
+// APIError is a non-2xx response from the Wallet Objects API.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("google wallet api: %d %s", e.StatusCode, e.Status)
+	}
+	return fmt.Sprintf("google wallet api: %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+// Is lets callers match API errors against ErrNotFound and ErrConflict.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+	return false
+}
+
+// Client manages EventTicket classes and objects through the Wallet Objects REST API.
+type Client struct {
+	cfg        Config
+	account    serviceAccount
+	key        *rsa.PrivateKey
+	httpClient *http.Client
+	baseURL    string
+	tokenURL   string
+	clock      Clock
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// ClientOption overrides a Client dependency.
+type ClientOption func(*Client)
+
+// WithBaseURL points the client at a different Wallet Objects API root (e.g. an httptest server).
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTokenURL points the OAuth2 token exchange at a different endpoint.
+func WithTokenURL(tokenURL string) ClientOption {
+	return func(c *Client) {
+		c.tokenURL = tokenURL
+	}
+}
+
+// WithHTTPClient injects the HTTP client used for both token and API requests.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithClientClock swaps the time source used for token assertions and expiry.
+func WithClientClock(clock Clock) ClientOption {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// NewClient parses the service account in cfg and prepares an API client. Tokens are fetched lazily.
+func NewClient(cfg Config, opts ...ClientOption) (*Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	account, key, err := parseServiceAccount(cfg.ServiceAccountJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		cfg:      cfg,
+		account:  account,
+		key:      key,
+		baseURL:  DefaultBaseURL,
+		tokenURL: DefaultTokenURL,
+		clock:    systemClock{},
+	}
+	if account.TokenURI != "" {
+		c.tokenURL = account.TokenURI
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = http_logs.NewLoggingClient()
+	}
+
+	return c, nil
+}
+
+// InsertClass creates an EventTicketClass.
+func (c *Client) InsertClass(ctx context.Context, class EventTicketClass) (EventTicketClass, error) {
+	var out EventTicketClass
+	err := c.do(ctx, http.MethodPost, "/eventTicketClass", class, &out)
+	return out, err
+}
+
+// PatchClass updates the fields set on class.
+func (c *Client) PatchClass(ctx context.Context, class EventTicketClass) (EventTicketClass, error) {
+	var out EventTicketClass
+	err := c.do(ctx, http.MethodPatch, "/eventTicketClass/"+url.PathEscape(class.ID), class, &out)
+	return out, err
+}
+
+// EnsureClass inserts the configured event class, patching it instead when it already exists.
+func (c *Client) EnsureClass(ctx context.Context) (EventTicketClass, error) {
+	class := c.cfg.Class()
+	out, err := c.InsertClass(ctx, class)
+	if errors.Is(err, ErrConflict) {
+		return c.PatchClass(ctx, class)
+	}
+	return out, err
+}
+
+// InsertObject creates an EventTicketObject.
+func (c *Client) InsertObject(ctx context.Context, object EventTicketObject) (EventTicketObject, error) {
+	var out EventTicketObject
+	err := c.do(ctx, http.MethodPost, "/eventTicketObject", object, &out)
+	return out, err
+}
+
+// PatchObject updates the fields set on object.
+func (c *Client) PatchObject(ctx context.Context, object EventTicketObject) (EventTicketObject, error) {
+	var out EventTicketObject
+	err := c.do(ctx, http.MethodPatch, "/eventTicketObject/"+url.PathEscape(object.ID), object, &out)
+	return out, err
+}
+
+// ExpireObject marks an object EXPIRED so the pass moves to the holder's expired passes.
+func (c *Client) ExpireObject(ctx context.Context, objectID string) (EventTicketObject, error) {
+	var out EventTicketObject
+	patch := map[string]string{"state": StateExpired}
+	err := c.do(ctx, http.MethodPatch, "/eventTicketObject/"+url.PathEscape(objectID), patch, &out)
+	return out, err
+}
+
+type apiErrorBody struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encoding google wallet request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("google wallet %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading google wallet response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Status: http.StatusText(resp.StatusCode)}
+		var errBody apiErrorBody
+		if json.Unmarshal(raw, &errBody) == nil && errBody.Error.Message != "" {
+			apiErr.Message = errBody.Error.Message
+			if errBody.Error.Status != "" {
+				apiErr.Status = errBody.Error.Status
+			}
+		}
+		return apiErr
+	}
+
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decoding google wallet response: %w", err)
+	}
+	return nil
+}
+
+type assertionClaims struct {
+	Issuer    string `json:"iss"`
+	Scope     string `json:"scope"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// token returns a cached access token, exchanging a fresh service account assertion when it is close to expiry.
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if c.accessToken != "" && now.Before(c.expiresAt.Add(-tokenExpiryLeeway)) {
+		return c.accessToken, nil
+	}
+
+	assertion, err := signJWT(c.key, c.account.PrivateKeyID, assertionClaims{
+		Issuer:    c.account.ClientEmail,
+		Scope:     walletIssuerScope,
+		Audience:  c.tokenURL,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(assertionLifetime).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("signing token assertion: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", jwtBearerGrant)
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting google access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("google token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tok tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("decoding google access token: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("google token endpoint returned an empty access token")
+	}
+
+	c.accessToken = tok.AccessToken
+	c.expiresAt = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return c.accessToken, nil
+}
@@ -0,0 +1,231 @@
+package google_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
+	"github.com/atunbetun/hakuna-wallet/pkg/wallet/google"
+)
+
+// fakeWalletAPI stands in for both the OAuth2 token endpoint and the Wallet Objects API.
+type fakeWalletAPI struct {
+	mu          sync.Mutex
+	tokenCalls  int
+	assertions  []string
+	classes     map[string]map[string]any
+	objects     map[string]map[string]any
+	authHeaders []string
+}
+
+func newFakeWalletAPI(t *testing.T) (*fakeWalletAPI, *httptest.Server) {
+	t.Helper()
+	api := &fakeWalletAPI{classes: map[string]map[string]any{}, objects: map[string]map[string]any{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		api.mu.Lock()
+		api.tokenCalls++
+		api.assertions = append(api.assertions, r.PostForm.Get("assertion"))
+		api.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access-1", "expires_in": 3600, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("POST /walletobjects/v1/{resource}", func(w http.ResponseWriter, r *http.Request) {
+		api.insert(w, r, r.PathValue("resource"))
+	})
+	mux.HandleFunc("PATCH /walletobjects/v1/{resource}/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.patch(w, r, r.PathValue("resource"), r.PathValue("id"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return api, server
+}
+
+func (f *fakeWalletAPI) store(resource string) map[string]map[string]any {
+	if resource == "eventTicketClass" {
+		return f.classes
+	}
+	return f.objects
+}
+
+func (f *fakeWalletAPI) insert(w http.ResponseWriter, r *http.Request, resource string) {
+	body := f.decode(w, r)
+	if body == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id, _ := body["id"].(string)
+	if _, exists := f.store(resource)[id]; exists {
+		writeAPIError(w, http.StatusConflict, "ALREADY_EXISTS", "resource already exists")
+		return
+	}
+	f.store(resource)[id] = body
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeWalletAPI) patch(w http.ResponseWriter, r *http.Request, resource, id string) {
+	body := f.decode(w, r)
+	if body == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	existing, ok := f.store(resource)[id]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "resource not found")
+		return
+	}
+	for k, v := range body {
+		existing[k] = v
+	}
+	_ = json.NewEncoder(w).Encode(existing)
+}
+
+func (f *fakeWalletAPI) decode(w http.ResponseWriter, r *http.Request) map[string]any {
+	f.mu.Lock()
+	f.authHeaders = append(f.authHeaders, r.Header.Get("Authorization"))
+	f.mu.Unlock()
+
+	raw, _ := io.ReadAll(r.Body)
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	return body
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": status, "message": message, "status": code},
+	})
+}
+
+func TestClientManagesClassAndObjectLifecycle(t *testing.T) {
+	api, server := newFakeWalletAPI(t)
+	saJSON, key := newServiceAccount(t)
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+
+	cfg := google.Config{
+		IssuerID:           "3388000000012345678",
+		ClassSuffix:        "hakuna_gala",
+		IssuerName:         "Hakuna Wallet",
+		EventName:          "Hakuna Integration Gala",
+		ServiceAccountJSON: saJSON,
+	}
+	client, err := google.NewClient(
+		cfg,
+		google.WithBaseURL(server.URL+"/walletobjects/v1"),
+		google.WithTokenURL(server.URL+"/token"),
+		google.WithHTTPClient(server.Client()),
+		google.WithClientClock(fakeClock{t: now}),
+	)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx := context.Background()
+
+	class, err := client.EnsureClass(ctx)
+	if err != nil {
+		t.Fatalf("ensure class: %v", err)
+	}
+	if class.ID != cfg.ClassID() {
+		t.Fatalf("unexpected class id %q", class.ID)
+	}
+	if _, err := client.EnsureClass(ctx); err != nil {
+		t.Fatalf("ensure existing class: %v", err)
+	}
+
+	object := cfg.Object(tickets.TTIssuedTicket{ID: "tt_123", Barcode: "BR-123", FullName: "Nala Hakuna"})
+	if _, err := client.InsertObject(ctx, object); err != nil {
+		t.Fatalf("insert object: %v", err)
+	}
+	if _, err := client.InsertObject(ctx, object); !errors.Is(err, google.ErrConflict) {
+		t.Fatalf("expected conflict on duplicate insert, got %v", err)
+	}
+
+	object.TicketHolderName = "Simba Hakuna"
+	patched, err := client.PatchObject(ctx, object)
+	if err != nil {
+		t.Fatalf("patch object: %v", err)
+	}
+	if patched.TicketHolderName != "Simba Hakuna" {
+		t.Fatalf("expected patched holder, got %q", patched.TicketHolderName)
+	}
+
+	expired, err := client.ExpireObject(ctx, object.ID)
+	if err != nil {
+		t.Fatalf("expire object: %v", err)
+	}
+	if expired.State != google.StateExpired {
+		t.Fatalf("expected expired state, got %q", expired.State)
+	}
+
+	_, err = client.ExpireObject(ctx, cfg.ObjectID("tt_missing"))
+	var apiErr *google.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, google.ErrNotFound) || apiErr.Status != "NOT_FOUND" {
+		t.Fatalf("expected not found api error, got %v", err)
+	}
+
+	if api.tokenCalls != 1 {
+		t.Fatalf("expected access token to be cached, got %d token calls", api.tokenCalls)
+	}
+	for _, header := range api.authHeaders {
+		if header != "Bearer access-1" {
+			t.Fatalf("unexpected authorization header %q", header)
+		}
+	}
+
+	claims := decodeJWT(t, api.assertions[0], &key.PublicKey)
+	if claims["scope"] != "https://www.googleapis.com/auth/wallet_object.issuer" {
+		t.Fatalf("unexpected scope %v", claims["scope"])
+	}
+	if claims["aud"] != server.URL+"/token" {
+		t.Fatalf("unexpected audience %v", claims["aud"])
+	}
+	if claims["exp"] != float64(now.Add(time.Hour).Unix()) {
+		t.Fatalf("unexpected exp %v", claims["exp"])
+	}
+}
+
+func TestClientReportsTokenFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	saJSON, _ := newServiceAccount(t)
+	client, err := google.NewClient(
+		google.Config{IssuerID: "338800", ClassSuffix: "gala", ServiceAccountJSON: saJSON},
+		google.WithBaseURL(server.URL),
+		google.WithTokenURL(server.URL+"/token"),
+		google.WithHTTPClient(server.Client()),
+	)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	_, err = client.ExpireObject(context.Background(), "338800.tt_1")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected token error, got %v", err)
+	}
+}
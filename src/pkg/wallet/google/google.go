@@ -2,23 +2,37 @@ package google
 
 import (
 	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/atunbetun/hakuna-wallet/pkg/tickets"
 	"github.com/atunbetun/hakuna-wallet/pkg/wallet"
 )
 
-// Config carries the minimum attributes required to describe a Google Wallet pass for tests.
+// Config describes the Google Wallet issuer, event class, and credentials used to sign save links.
 type Config struct {
-	IssuerEmail string
-	ClassID     string
-	BinRange    string
-	// ServiceAccountJSON holds the credential payload if required by a real integration.
+	// IssuerID is the numeric Google Wallet issuer ID that prefixes every class and object ID.
+	IssuerID string
+	// ClassSuffix identifies the EventTicketClass for this event under the issuer.
+	ClassSuffix string
+	IssuerName  string
+	EventName   string
+	// Language is the BCP 47 tag used for localized strings; defaults to en-US.
+	Language string
+	// Origins lists the web origins allowed to render the save button.
+	Origins []string
+	// ServiceAccountJSON is the key file of the service account that signs save links.
 	ServiceAccountJSON string
 }
 
+const (
+	defaultLanguage = "en-US"
+	saveURLPrefix   = "https://pay.google.com/gp/v/save/"
+)
+
 // Clock abstracts time retrieval to keep output deterministic in tests.
 type Clock interface {
 	Now() time.Time
@@ -30,27 +44,41 @@ func (systemClock) Now() time.Time {
 	return time.Now().UTC()
 }
 
-// Generator produces lightweight JSON representations of Google Wallet passes that the CLI can persist.
+// Generator produces Google Wallet EventTicket objects and the signed links that save them.
 type Generator struct {
-	cfg   Config
-	clock Clock
+	cfg     Config
+	account serviceAccount
+	key     *rsa.PrivateKey
+	clock   Clock
 }
 
 // Option returns a generator with the desired dependency overrides, keeping the original immutable.
 type Option func(Generator) Generator
 
-// NewGenerator builds a generator with the production clock.
-func NewGenerator(cfg Config, opts ...Option) Generator {
+// NewGenerator validates cfg and parses the service account once, so bad credentials fail at startup rather than
+// on every ticket. The generator uses the production clock unless overridden.
+func NewGenerator(cfg Config, opts ...Option) (Generator, error) {
+	if err := cfg.validate(); err != nil {
+		return Generator{}, err
+	}
+
+	account, key, err := parseServiceAccount(cfg.ServiceAccountJSON)
+	if err != nil {
+		return Generator{}, err
+	}
+
 	g := Generator{
-		cfg:   cfg,
-		clock: systemClock{},
+		cfg:     cfg,
+		account: account,
+		key:     key,
+		clock:   systemClock{},
 	}
 
 	for _, opt := range opts {
 		g = opt(g)
 	}
 
-	return g
+	return g, nil
 }
 
 // WithClock swaps the clock implementation (useful to freeze time in tests).
@@ -61,39 +89,117 @@ func WithClock(clock Clock) Option {
 	}
 }
 
-// Generate renders a JSON artifact that callers can upload to Google Wallet or store as a fixture.
+// ClassID returns the fully qualified EventTicketClass ID.
+func (c Config) ClassID() string {
+	return c.IssuerID + "." + c.ClassSuffix
+}
+
+// ObjectID returns the fully qualified EventTicketObject ID for a Ticket Tailor ticket.
+func (c Config) ObjectID(ticketID string) string {
+	return c.IssuerID + "." + sanitizeIdentifier(ticketID)
+}
+
+func (c Config) language() string {
+	if c.Language != "" {
+		return c.Language
+	}
+	return defaultLanguage
+}
+
+// Class renders the EventTicketClass shared by every ticket of the event.
+func (c Config) Class() EventTicketClass {
+	return EventTicketClass{
+		ID:           c.ClassID(),
+		IssuerName:   c.IssuerName,
+		EventName:    localized(c.language(), c.EventName),
+		ReviewStatus: reviewStatusUnderReview,
+	}
+}
+
+// Object renders the EventTicketObject for a ticket.
+func (c Config) Object(ticket tickets.TTIssuedTicket) EventTicketObject {
+	obj := EventTicketObject{
+		ID:               c.ObjectID(ticket.ID),
+		ClassID:          c.ClassID(),
+		State:            StateActive,
+		TicketHolderName: ticket.FullName,
+		TicketNumber:     ticket.ID,
+	}
+	if ticket.IsVoided() {
+		obj.State = StateExpired
+	}
+	if ticket.Barcode != "" {
+		obj.Barcode = &Barcode{
+			Type:          barcodeTypeQR,
+			Value:         ticket.Barcode,
+			AlternateText: ticket.Barcode,
+		}
+	}
+	if ticket.Description != "" {
+		ticketType := localized(c.language(), ticket.Description)
+		obj.TicketType = &ticketType
+	}
+	if ticket.OrderID != "" {
+		obj.ReservationInfo = &EventReservationInfo{ConfirmationCode: ticket.OrderID}
+	}
+	return obj
+}
+
+func (c Config) validate() error {
+	if c.IssuerID == "" {
+		return fmt.Errorf("issuer id is required")
+	}
+	if c.ClassSuffix == "" {
+		return fmt.Errorf("class suffix is required")
+	}
+	return nil
+}
+
+type savePayload struct {
+	EventTicketClasses []EventTicketClass  `json:"eventTicketClasses,omitempty"`
+	EventTicketObjects []EventTicketObject `json:"eventTicketObjects"`
+}
+
+type saveClaims struct {
+	Issuer   string      `json:"iss"`
+	Audience string      `json:"aud"`
+	Type     string      `json:"typ"`
+	IssuedAt int64       `json:"iat"`
+	Origins  []string    `json:"origins"`
+	Payload  savePayload `json:"payload"`
+}
+
+// Generate renders the EventTicketObject for a ticket and signs a "Save to Google Wallet" link for it.
 func (g Generator) Generate(_ context.Context, ticket tickets.TTIssuedTicket) (wallet.Artifact, error) {
 	if ticket.ID == "" {
 		return wallet.Artifact{}, fmt.Errorf("ticket id is required")
 	}
-	if g.cfg.IssuerEmail == "" {
-		return wallet.Artifact{}, fmt.Errorf("issuer email is required")
+	if g.key == nil {
+		return wallet.Artifact{}, fmt.Errorf("google generator must be created with NewGenerator")
 	}
 
-	payload := map[string]any{
-		"objectId": fmt.Sprintf("%s.%s", g.cfg.IssuerEmail, ticket.ID),
-		"classId":  g.cfg.ClassID,
-		"state":    "ACTIVE",
-		"barcode":  ticket.Barcode,
-		"description": map[string]string{
-			"text": ticket.Description,
-		},
-		"holder": map[string]string{
-			"name": ticket.FullName,
-		},
-		"event": map[string]string{
-			"id":      ticket.EventID,
-			"series":  ticket.EventSeriesID,
-			"typeId":  ticket.TicketTypeID,
-			"orderId": ticket.OrderID,
-		},
-		"meta": map[string]string{
-			"issuedAt": g.clock.Now().Format(time.RFC3339Nano),
-			"binRange": g.cfg.BinRange,
+	object := g.cfg.Object(ticket)
+	origins := g.cfg.Origins
+	if origins == nil {
+		origins = []string{}
+	}
+
+	token, err := signJWT(g.key, g.account.PrivateKeyID, saveClaims{
+		Issuer:   g.account.ClientEmail,
+		Audience: "google",
+		Type:     "savetowallet",
+		IssuedAt: g.clock.Now().Unix(),
+		Origins:  origins,
+		Payload: savePayload{
+			EventTicketClasses: []EventTicketClass{g.cfg.Class()},
+			EventTicketObjects: []EventTicketObject{object},
 		},
+	})
+	if err != nil {
+		return wallet.Artifact{}, fmt.Errorf("signing save to wallet jwt: %w", err)
 	}
 
-	data, err := json.MarshalIndent(payload, "", "  ")
+	data, err := json.MarshalIndent(object, "", "  ")
 	if err != nil {
 		return wallet.Artifact{}, fmt.Errorf("could not marshal google wallet payload: %w", err)
 	}
@@ -103,5 +209,18 @@ func (g Generator) Generate(_ context.Context, ticket tickets.TTIssuedTicket) (w
 		FileName:    fmt.Sprintf("%s.json", ticket.ID),
 		ContentType: "application/json",
 		Data:        data,
+		SaveURL:     saveURLPrefix + token,
 	}, nil
 }
+
+// sanitizeIdentifier keeps only the characters Google accepts in object IDs.
+func sanitizeIdentifier(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, id)
+}
@@ -2,7 +2,15 @@ package google_test
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,23 +26,76 @@ func (f fakeClock) Now() time.Time {
 	return f.t
 }
 
+func newServiceAccount(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	sa, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "wallet@hakuna.iam.gserviceaccount.com",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatalf("marshal service account: %v", err)
+	}
+	return string(sa), key
+}
+
+func decodeJWT(t *testing.T, token string, pub *rsa.PublicKey) map[string]any {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed jwt %q", token)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("jwt signature does not verify: %v", err)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+	return claims
+}
+
 func TestGeneratorProduceDeterministicArtifact(t *testing.T) {
 	t.Helper()
 
+	saJSON, key := newServiceAccount(t)
 	issuedAt := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
-	gen := google.NewGenerator(
+	gen, err := google.NewGenerator(
 		google.Config{
-			IssuerEmail:        "issuer@hakuna.dev",
-			ClassID:            "hakuna.pass.class",
-			BinRange:           "400000-499999",
-			ServiceAccountJSON: "{}",
+			IssuerID:           "3388000000012345678",
+			ClassSuffix:        "hakuna_gala",
+			IssuerName:         "Hakuna Wallet",
+			EventName:          "Hakuna Integration Gala",
+			Origins:            []string{"https://hakuna.dev"},
+			ServiceAccountJSON: saJSON,
 		},
 		google.WithClock(fakeClock{t: issuedAt}),
 	)
+	if err != nil {
+		t.Fatalf("new generator: %v", err)
+	}
 
 	ticket := tickets.TTIssuedTicket{
 		ID:            "tt_123",
-		Description:   "Hakuna Integration Gala",
+		Description:   "VIP",
 		FullName:      "Nala Hakuna",
 		Barcode:       "BR-123",
 		EventID:       "ev_123",
@@ -58,29 +119,97 @@ func TestGeneratorProduceDeterministicArtifact(t *testing.T) {
 		t.Fatalf("expected content type application/json, got %s", artifact.ContentType)
 	}
 
-	var payload map[string]any
-	if err := json.Unmarshal(artifact.Data, &payload); err != nil {
+	var object google.EventTicketObject
+	if err := json.Unmarshal(artifact.Data, &object); err != nil {
 		t.Fatalf("unmarshal payload: %v", err)
 	}
-
-	if payload["objectId"] != "issuer@hakuna.dev.tt_123" {
-		t.Errorf("unexpected objectId: %v", payload["objectId"])
+	if object.ID != "3388000000012345678.tt_123" {
+		t.Errorf("unexpected object id: %v", object.ID)
+	}
+	if object.ClassID != "3388000000012345678.hakuna_gala" {
+		t.Errorf("unexpected class id: %v", object.ClassID)
 	}
-	if payload["classId"] != "hakuna.pass.class" {
-		t.Errorf("unexpected classId: %v", payload["classId"])
+	if object.State != google.StateActive {
+		t.Errorf("unexpected state: %v", object.State)
 	}
-	if payload["state"] != "ACTIVE" {
-		t.Errorf("unexpected state: %v", payload["state"])
+	if object.Barcode == nil || object.Barcode.Value != "BR-123" || object.Barcode.Type != "QR_CODE" {
+		t.Errorf("unexpected barcode: %+v", object.Barcode)
+	}
+	if object.TicketHolderName != "Nala Hakuna" {
+		t.Errorf("unexpected holder: %v", object.TicketHolderName)
+	}
+	if object.ReservationInfo == nil || object.ReservationInfo.ConfirmationCode != "order_123" {
+		t.Errorf("unexpected reservation info: %+v", object.ReservationInfo)
 	}
 
-	meta, ok := payload["meta"].(map[string]any)
+	token, ok := strings.CutPrefix(artifact.SaveURL, "https://pay.google.com/gp/v/save/")
 	if !ok {
-		t.Fatalf("meta field missing or not an object: %v", payload["meta"])
+		t.Fatalf("unexpected save url: %s", artifact.SaveURL)
+	}
+	claims := decodeJWT(t, token, &key.PublicKey)
+	if claims["iss"] != "wallet@hakuna.iam.gserviceaccount.com" {
+		t.Errorf("unexpected iss: %v", claims["iss"])
 	}
-	if meta["issuedAt"] != issuedAt.Format(time.RFC3339Nano) {
-		t.Errorf("unexpected issuedAt: %v", meta["issuedAt"])
+	if claims["aud"] != "google" || claims["typ"] != "savetowallet" {
+		t.Errorf("unexpected aud/typ: %v %v", claims["aud"], claims["typ"])
+	}
+	if claims["iat"] != float64(issuedAt.Unix()) {
+		t.Errorf("unexpected iat: %v", claims["iat"])
+	}
+
+	payload := claims["payload"].(map[string]any)
+	objects := payload["eventTicketObjects"].([]any)
+	if len(objects) != 1 || objects[0].(map[string]any)["id"] != "3388000000012345678.tt_123" {
+		t.Errorf("unexpected jwt objects: %v", objects)
+	}
+	classes := payload["eventTicketClasses"].([]any)
+	if len(classes) != 1 || classes[0].(map[string]any)["id"] != "3388000000012345678.hakuna_gala" {
+		t.Errorf("unexpected jwt classes: %v", classes)
+	}
+
+	again, err := gen.Generate(context.Background(), ticket)
+	if err != nil {
+		t.Fatalf("generate again: %v", err)
+	}
+	if again.SaveURL != artifact.SaveURL {
+		t.Errorf("expected deterministic save url with frozen clock")
+	}
+}
+
+func TestGeneratorRequiresServiceAccount(t *testing.T) {
+	_, err := google.NewGenerator(google.Config{IssuerID: "338800", ClassSuffix: "gala"})
+	if err == nil || !strings.Contains(err.Error(), "service account") {
+		t.Fatalf("expected service account error, got %v", err)
+	}
+
+	_, err = google.NewGenerator(google.Config{IssuerID: "338800", ClassSuffix: "gala", ServiceAccountJSON: `{"client_email":"wallet@hakuna.iam.gserviceaccount.com","private_key":"not a key"}`})
+	if err == nil {
+		t.Fatal("expected an error for an unparsable private key")
+	}
+
+	saJSON, _ := newServiceAccount(t)
+	if _, err := google.NewGenerator(google.Config{ClassSuffix: "gala", ServiceAccountJSON: saJSON}); err == nil {
+		t.Fatal("expected an error without an issuer id")
+	}
+}
+
+func TestGeneratorExpiresVoidedTickets(t *testing.T) {
+	saJSON, _ := newServiceAccount(t)
+	gen, err := google.NewGenerator(google.Config{IssuerID: "338800", ClassSuffix: "gala", ServiceAccountJSON: saJSON})
+	if err != nil {
+		t.Fatalf("new generator: %v", err)
+	}
+
+	artifact, err := gen.Generate(context.Background(), tickets.TTIssuedTicket{ID: "tt_1", Status: string(tickets.Void)})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	var object google.EventTicketObject
+	if err := json.Unmarshal(artifact.Data, &object); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
 	}
-	if meta["binRange"] != "400000-499999" {
-		t.Errorf("unexpected binRange: %v", meta["binRange"])
+	if object.State != google.StateExpired {
+		t.Fatalf("expected expired object for voided ticket, got %q", object.State)
 	}
 }
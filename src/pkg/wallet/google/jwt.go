@@ -0,0 +1,83 @@
+package google
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+)
+
+// serviceAccount is the subset of a Google service account key file needed to sign JWTs.
+type serviceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+func parseServiceAccount(raw string) (serviceAccount, *rsa.PrivateKey, error) {
+	if raw == "" {
+		return serviceAccount{}, nil, fmt.Errorf("service account json is required")
+	}
+
+	var sa serviceAccount
+	if err := json.Unmarshal([]byte(raw), &sa); err != nil {
+		return serviceAccount{}, nil, fmt.Errorf("decoding service account json: %w", err)
+	}
+	if sa.ClientEmail == "" {
+		return serviceAccount{}, nil, fmt.Errorf("service account client_email is required")
+	}
+
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return serviceAccount{}, nil, fmt.Errorf("service account private_key is not PEM encoded")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		pkcs1, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if pkcs1Err != nil {
+			return serviceAccount{}, nil, fmt.Errorf("parsing service account private key: %w", err)
+		}
+		parsed = pkcs1
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return serviceAccount{}, nil, fmt.Errorf("service account private key must be RSA, got %T", parsed)
+	}
+	return sa, key, nil
+}
+
+// signJWT produces a compact RS256 JWT over claims.
+func signJWT(key *rsa.PrivateKey, keyID string, claims any) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("encoding jwt header: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding jwt claims: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(headerJSON) + "." + enc.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing jwt: %w", err)
+	}
+
+	return signingInput + "." + enc.EncodeToString(signature), nil
+}
@@ -0,0 +1,59 @@
+package google
+
+// The types below mirror the subset of the Google Wallet REST resources this project populates.
+// See https://developers.google.com/wallet/reference/rest/v1/eventticketobject.
+
+const (
+	StateActive  = "ACTIVE"
+	StateExpired = "EXPIRED"
+
+	reviewStatusUnderReview = "UNDER_REVIEW"
+	barcodeTypeQR           = "QR_CODE"
+)
+
+// TranslatedString is a single localized value.
+type TranslatedString struct {
+	Language string `json:"language"`
+	Value    string `json:"value"`
+}
+
+// LocalizedString carries the default translation of a user-visible string.
+type LocalizedString struct {
+	DefaultValue TranslatedString `json:"defaultValue"`
+}
+
+// Barcode is the scannable code rendered on the pass.
+type Barcode struct {
+	Type          string `json:"type"`
+	Value         string `json:"value"`
+	AlternateText string `json:"alternateText,omitempty"`
+}
+
+// EventReservationInfo links the pass back to the purchase.
+type EventReservationInfo struct {
+	ConfirmationCode string `json:"confirmationCode,omitempty"`
+}
+
+// EventTicketClass holds the event-wide fields shared by every ticket object.
+type EventTicketClass struct {
+	ID           string          `json:"id"`
+	IssuerName   string          `json:"issuerName"`
+	EventName    LocalizedString `json:"eventName"`
+	ReviewStatus string          `json:"reviewStatus,omitempty"`
+}
+
+// EventTicketObject is a single attendee's ticket.
+type EventTicketObject struct {
+	ID               string                `json:"id"`
+	ClassID          string                `json:"classId"`
+	State            string                `json:"state"`
+	Barcode          *Barcode              `json:"barcode,omitempty"`
+	TicketHolderName string                `json:"ticketHolderName,omitempty"`
+	TicketNumber     string                `json:"ticketNumber,omitempty"`
+	TicketType       *LocalizedString      `json:"ticketType,omitempty"`
+	ReservationInfo  *EventReservationInfo `json:"reservationInfo,omitempty"`
+}
+
+func localized(language string, value string) LocalizedString {
+	return LocalizedString{DefaultValue: TranslatedString{Language: language, Value: value}}
+}